@@ -0,0 +1,115 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	requestid "github.com/elt0nxale/meme-tunnel-streamer/internal/requestid"
+)
+
+// Error is a failure with the HTTP status and machine-readable code reported to clients
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error // Underlying cause, logged but not exposed
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error with a status, code and client-facing message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap creates an error with a generic message for its status, keeping the
+// cause for the log since it may name hosts, paths or upstream responses
+func Wrap(status int, code string, err error) *Error {
+	message := strings.ToLower(http.StatusText(status))
+	if message == "" {
+		message = "request failed"
+	}
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+// BadRequest reports an invalid parameter or body
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, "bad_request", message)
+}
+
+// NotFound reports a missing resource such as an ended stream
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, "not_found", message)
+}
+
+// Unauthorized reports missing or invalid credentials
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, "unauthorized", message)
+}
+
+// Forbidden reports credentials lacking access
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, "forbidden", message)
+}
+
+// envelope is the JSON body of every error response
+type envelope struct {
+	Error body `json:"error"`
+}
+
+// body describes the failure
+type body struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write maps err to a status and code and writes the JSON error envelope.
+// Errors that are not *Error become 500 internal_error, except cancelled or
+// timed-out request contexts.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err)
+	if apiErr.Status >= http.StatusInternalServerError || apiErr.Err != nil {
+		log.Printf("%s %s failed (request %s): %v", r.Method, r.URL.Path, requestid.FromContext(r.Context()), err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(envelope{Error: body{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		RequestID: requestid.FromContext(r.Context()),
+	}})
+}
+
+// From converts any error into an *Error
+func From(err error) *Error {
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(http.StatusGatewayTimeout, "timeout", err)
+	case errors.Is(err, context.Canceled):
+		return Wrap(http.StatusServiceUnavailable, "canceled", err)
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: "internal_error", Message: "internal server error", Err: err}
+	}
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Entry is a single recorded admin action
+type Entry struct {
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	ClientIP string            `json:"client_ip,omitempty"`
+	Action   string            `json:"action"`
+	Params   map[string]string `json:"params,omitempty"`
+	Error    string            `json:"error,omitempty"` // Set when the action failed
+}
+
+// Log is a bounded, in-memory audit trail of admin actions
+type Log struct {
+	mu         sync.RWMutex
+	entries    []Entry
+	maxEntries int
+}
+
+// NewLog creates an audit log keeping the most recent maxEntries actions
+func NewLog(maxEntries int) *Log {
+	return &Log{maxEntries: maxEntries}
+}
+
+// Record appends an entry, stamping it with the current time if unset
+func (l *Log) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	log.Printf("Audit: %s by %s %v", entry.Action, entry.Actor, entry.Params)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.maxEntries {
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-l.maxEntries:]...)
+	}
+}
+
+// Query filters entries by action and actor (empty matches all), newest first,
+// returning at most limit entries (0 means no limit)
+func (l *Log) Query(action, actor string, since time.Time, limit int) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := []Entry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if action != "" && entry.Action != action {
+			continue
+		}
+		if actor != "" && entry.Actor != actor {
+			continue
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
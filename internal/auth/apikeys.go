@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// APIKey is a credential handed out to a client along with its quotas
+type APIKey struct {
+	Name         string `yaml:"name" json:"name"`
+	Key          string `yaml:"key" json:"-"`
+	MaxStreams   int    `yaml:"max_streams" json:"max_streams"`       // 0 means unlimited
+	EventsPerDay int    `yaml:"events_per_day" json:"events_per_day"` // 0 means unlimited
+	Role         Role   `yaml:"role" json:"role"`
+}
+
+// ParseAPIKey parses a "name:key[:max_streams[:events_per_day[:role]]]" specification;
+// keys are viewers unless a role is given
+func ParseAPIKey(spec string) (APIKey, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 5 || parts[0] == "" || parts[1] == "" {
+		return APIKey{}, fmt.Errorf("invalid api key %q, expected name:key[:max_streams[:events_per_day[:role]]]", spec)
+	}
+
+	key := APIKey{Name: parts[0], Key: parts[1], Role: RoleViewer}
+	if len(parts) == 5 {
+		role, err := ParseRole(parts[4])
+		if err != nil {
+			return APIKey{}, fmt.Errorf("invalid api key %s: %v", key.Name, err)
+		}
+		key.Role = role
+		parts = parts[:4]
+	}
+
+	limits := []*int{&key.MaxStreams, &key.EventsPerDay}
+	for i, raw := range parts[2:] {
+		if raw == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return APIKey{}, fmt.Errorf("invalid limit %q in api key %s", raw, key.Name)
+		}
+		*limits[i] = limit
+	}
+	return key, nil
+}
+
+// contextKey stores the authenticated key on the request context
+type contextKey struct{}
+
+// KeyStore holds the configured API keys; a store that never had any disables
+// key auth
+type KeyStore struct {
+	mu       sync.RWMutex
+	keys     []APIKey
+	required bool // Keys were configured at some point, so none left means no access
+}
+
+// NewKeyStore creates a store with the given keys
+func NewKeyStore(keys []APIKey) *KeyStore {
+	return &KeyStore{keys: keys, required: len(keys) > 0}
+}
+
+// Set replaces the configured keys, e.g. after a config reload. Revoking every
+// key locks keyed routes rather than opening them
+func (ks *KeyStore) Set(keys []APIKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = keys
+	ks.required = ks.required || len(keys) > 0
+}
+
+// Enabled reports whether requests must present a key: any are configured, or
+// all of them were revoked
+func (ks *KeyStore) Enabled() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.required
+}
+
+// Keys returns a copy of the configured keys
+func (ks *KeyStore) Keys() []APIKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return append([]APIKey(nil), ks.keys...)
+}
+
+// Lookup finds the key presented by a request as a bearer token or ?api_key=
+// (EventSource cannot set headers)
+func (ks *KeyStore) Lookup(r *http.Request) (APIKey, bool) {
+	presented, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		presented = r.URL.Query().Get("api_key")
+	}
+	if presented == "" {
+		return APIKey{}, false
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key.Key)) == 1 {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// NewContext returns a copy of ctx carrying the authenticated key
+func NewContext(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the authenticated key, if any
+func FromContext(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(APIKey)
+	return key, ok
+}
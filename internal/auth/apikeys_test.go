@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestParseAPIKey checks key specifications, their limits and roles
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    APIKey
+		wantErr bool
+	}{
+		{spec: "partner:s3cret", want: APIKey{Name: "partner", Key: "s3cret", Role: RoleViewer}},
+		{spec: "partner:s3cret:2:5000", want: APIKey{Name: "partner", Key: "s3cret", MaxStreams: 2, EventsPerDay: 5000, Role: RoleViewer}},
+		{spec: "partner:s3cret::5000", want: APIKey{Name: "partner", Key: "s3cret", EventsPerDay: 5000, Role: RoleViewer}},
+		{spec: "ops:s3cret:::admin", want: APIKey{Name: "ops", Key: "s3cret", Role: RoleAdmin}},
+		{spec: "ops:s3cret:1:10:debugger", want: APIKey{Name: "ops", Key: "s3cret", MaxStreams: 1, EventsPerDay: 10, Role: RoleDebugger}},
+		{spec: "partner", wantErr: true},
+		{spec: ":s3cret", wantErr: true},
+		{spec: "partner:", wantErr: true},
+		{spec: "partner:s3cret:-1", wantErr: true},
+		{spec: "partner:s3cret:two", wantErr: true},
+		{spec: "partner:s3cret:1:1:root", wantErr: true},
+		{spec: "partner:s3cret:1:1:admin:extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAPIKey(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAPIKey(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAPIKey(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestKeyStoreLookup checks keys are found by bearer token or ?api_key=
+func TestKeyStoreLookup(t *testing.T) {
+	ks := NewKeyStore([]APIKey{
+		{Name: "partner", Key: "s3cret"},
+		{Name: "ops", Key: "0ps", Role: RoleAdmin},
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   string // Name of the key found, empty for none
+	}{
+		{name: "bearer", target: "/memes", header: "Bearer s3cret", want: "partner"},
+		{name: "query", target: "/memes?api_key=0ps", want: "ops"},
+		{name: "bearer wins over query", target: "/memes?api_key=0ps", header: "Bearer s3cret", want: "partner"},
+		{name: "unknown key", target: "/memes?api_key=guess"},
+		{name: "prefix of a key", target: "/memes?api_key=s3c"},
+		{name: "other scheme", target: "/memes", header: "Basic s3cret"},
+		{name: "missing", target: "/memes"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+
+		key, ok := ks.Lookup(r)
+		if ok != (tt.want != "") || key.Name != tt.want {
+			t.Errorf("%s: Lookup = %q, %v, want %q", tt.name, key.Name, ok, tt.want)
+		}
+	}
+}
+
+// TestKeyStoreRevokeAll checks revoking every key locks keyed routes rather
+// than opening them
+func TestKeyStoreRevokeAll(t *testing.T) {
+	if NewKeyStore(nil).Enabled() {
+		t.Fatal("store without keys requires keys")
+	}
+
+	ks := NewKeyStore([]APIKey{{Name: "partner", Key: "s3cret"}})
+	ks.Set(nil)
+	if !ks.Enabled() {
+		t.Fatal("store stopped requiring keys after they were revoked")
+	}
+	if _, ok := ks.Lookup(httptest.NewRequest("GET", "/memes?api_key=s3cret", nil)); ok {
+		t.Fatal("revoked key still accepted")
+	}
+}
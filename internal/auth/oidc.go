@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+const (
+	// sessionCookie holds the signed login session
+	sessionCookie = "meme_fetcher_session"
+
+	// stateCookie holds the OAuth state, nonce, PKCE verifier and return path during login
+	stateCookie = "meme_fetcher_oidc_state"
+
+	// sessionTTL is how long a login lasts
+	sessionTTL = 12 * time.Hour
+)
+
+// OIDCOptions configures OpenID Connect login
+type OIDCOptions struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string   // Callback URL, derived from the request when empty
+	AllowedEmails []string // Verified emails allowed to log in, everyone when empty
+	RoleClaim     string   // ID token claim holding a role name, viewer when missing; allowed emails are admins when unset
+	SessionSecret []byte   // Signs session cookies, random when empty
+}
+
+// Session is a logged-in user
+type Session struct {
+	Subject string    `json:"sub"`
+	Email   string    `json:"email"`
+	Role    Role      `json:"role"`
+	Expires time.Time `json:"exp"`
+}
+
+// sessionContextKey stores the session on the request context
+type sessionContextKey struct{}
+
+// OIDC implements the authorization code flow with signed session cookies
+type OIDC struct {
+	opts     OIDCOptions
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDC discovers the issuer's endpoints and prepares the login flow
+func NewOIDC(ctx context.Context, opts OIDCOptions) (*OIDC, error) {
+	// Without either, any account at the issuer would log in with some role
+	if len(opts.AllowedEmails) == 0 && opts.RoleClaim == "" {
+		return nil, fmt.Errorf("OIDC login needs allowed emails or a role claim")
+	}
+
+	provider, err := oidc.NewProvider(ctx, opts.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %v", opts.Issuer, err)
+	}
+
+	if len(opts.SessionSecret) == 0 {
+		opts.SessionSecret = make([]byte, 32)
+		if _, err := rand.Read(opts.SessionSecret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %v", err)
+		}
+	}
+
+	return &OIDC{
+		opts: opts,
+		config: oauth2.Config{
+			ClientID:     opts.ClientID,
+			ClientSecret: opts.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: opts.ClientID}),
+	}, nil
+}
+
+// HandleLogin redirects to the identity provider, returning to ?next= afterwards
+func (o *OIDC) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomToken()
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state + "|" + nonce + "|" + verifier + "|" + next,
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	config := o.oauthConfig(r)
+	http.Redirect(w, r, config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// HandleCallback exchanges the authorization code and starts a session
+func (o *OIDC) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "login_expired", "login expired, try again"))
+		return
+	}
+	// The return path goes last as it may contain the separator itself
+	parts := strings.SplitN(cookie.Value, "|", 4)
+	if len(parts) != 4 || parts[0] == "" || r.URL.Query().Get("state") != parts[0] {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "invalid_state", "invalid login state"))
+		return
+	}
+	nonce, verifier, next := parts[1], parts[2], parts[3]
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+	config := o.oauthConfig(r)
+	token, err := config.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", fmt.Errorf("code exchange failed: %v", err)))
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusBadGateway, "upstream_error", "identity provider returned no id_token"))
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthorized(fmt.Sprintf("invalid id_token: %v", err)))
+		return
+	}
+	// Reject tokens issued for another login attempt
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		apierror.Write(w, r, apierror.Unauthorized("invalid id_token: nonce mismatch"))
+		return
+	}
+
+	session, err := o.sessionFromToken(idToken)
+	if err != nil {
+		apierror.Write(w, r, apierror.Forbidden(err.Error()))
+		return
+	}
+
+	value, err := o.sign(session)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// HandleLogout ends the session
+func (o *OIDC) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SessionFromRequest returns the valid session carried by a request's cookie
+func (o *OIDC) SessionFromRequest(r *http.Request) (Session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return Session{}, false
+	}
+
+	session, err := o.verify(cookie.Value)
+	if err != nil || time.Now().After(session.Expires) {
+		return Session{}, false
+	}
+	return session, true
+}
+
+// sessionFromToken applies the email allowlist and role claim to a verified ID token
+func (o *OIDC) sessionFromToken(idToken *oidc.IDToken) (Session, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Session{}, fmt.Errorf("failed to read claims: %v", err)
+	}
+
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	if len(o.opts.AllowedEmails) > 0 && (!verified || !slices.Contains(o.opts.AllowedEmails, email)) {
+		return Session{}, fmt.Errorf("%s is not allowed to log in", email)
+	}
+
+	// Allowed emails are admins unless roles come from a claim, where a
+	// missing claim grants the least
+	session := Session{
+		Subject: idToken.Subject,
+		Email:   email,
+		Role:    RoleAdmin,
+		Expires: time.Now().Add(sessionTTL),
+	}
+	if o.opts.RoleClaim != "" {
+		session.Role = RoleViewer
+		if name, ok := claims[o.opts.RoleClaim].(string); ok {
+			role, err := ParseRole(name)
+			if err != nil {
+				return Session{}, err
+			}
+			session.Role = role
+		}
+	}
+	return session, nil
+}
+
+// oauthConfig returns the OAuth config with the callback URL for this request
+func (o *OIDC) oauthConfig(r *http.Request) oauth2.Config {
+	config := o.config
+	config.RedirectURL = o.opts.RedirectURL
+	if config.RedirectURL == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		// The callback sits next to the login route, under any base path
+		callback := strings.TrimSuffix(r.URL.Path, "/login") + "/callback"
+		if prefix, ok := strings.CutSuffix(r.RequestURI, r.URL.RequestURI()); ok {
+			callback = prefix + callback
+		}
+		config.RedirectURL = scheme + "://" + r.Host + callback
+	}
+	return config
+}
+
+// sign encodes a session as payload.signature
+func (o *OIDC) sign(session Session) (string, error) {
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %v", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + o.mac(encoded), nil
+}
+
+// verify checks a cookie's signature and decodes its session
+func (o *OIDC) verify(value string) (Session, error) {
+	encoded, signature, found := strings.Cut(value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(o.mac(encoded))) {
+		return Session{}, errors.New("invalid session signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Session{}, fmt.Errorf("invalid session encoding: %v", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return Session{}, fmt.Errorf("invalid session: %v", err)
+	}
+	return session, nil
+}
+
+// mac returns the base64 HMAC-SHA256 of a payload
+func (o *OIDC) mac(payload string) string {
+	h := hmac.New(sha256.New, o.opts.SessionSecret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// randomToken returns an unguessable URL-safe token
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewSessionContext returns a copy of ctx carrying a session
+func NewSessionContext(ctx context.Context, session Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// SessionFromContext returns the logged-in session, if any
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(Session)
+	return session, ok
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessionCookie checks sessions only survive the round trip through a
+// cookie when signed with the same secret and not expired
+func TestSessionCookie(t *testing.T) {
+	o := &OIDC{opts: OIDCOptions{SessionSecret: []byte("session secret")}}
+	other := &OIDC{opts: OIDCOptions{SessionSecret: []byte("another secret")}}
+
+	valid := Session{Subject: "1234", Email: "ana@example.com", Role: RoleAdmin, Expires: time.Now().Add(time.Hour)}
+	expired := valid
+	expired.Expires = time.Now().Add(-time.Minute)
+
+	sign := func(o *OIDC, session Session) string {
+		value, err := o.sign(session)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return value
+	}
+	signed := sign(o, valid)
+	payload, signature, _ := strings.Cut(signed, ".")
+	forged := sign(o, Session{Subject: "1234", Email: "ana@example.com", Role: RoleViewer, Expires: valid.Expires})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   bool
+	}{
+		{name: "valid", cookie: signed, want: true},
+		{name: "expired", cookie: sign(o, expired)},
+		{name: "other secret", cookie: sign(other, valid)},
+		{name: "payload swapped", cookie: forgedPayload + "." + signature},
+		{name: "signature missing", cookie: payload},
+		{name: "signature truncated", cookie: payload + "." + signature[:len(signature)-1]},
+		{name: "garbage", cookie: "not-a-session"},
+		{name: "empty", cookie: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/debug", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+
+		session, ok := o.SessionFromRequest(r)
+		if ok != tt.want {
+			t.Errorf("%s: SessionFromRequest ok = %v, want %v", tt.name, ok, tt.want)
+			continue
+		}
+		if ok && (session.Subject != valid.Subject || session.Email != valid.Email || session.Role != valid.Role) {
+			t.Errorf("%s: SessionFromRequest = %+v, want %+v", tt.name, session, valid)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import "fmt"
+
+// Role grants access to a group of routes; each role includes the ones below it
+type Role int
+
+const (
+	RoleViewer   Role = iota // Stream, client API and feed
+	RoleDebugger             // Connection logs and statistics
+	RoleAdmin                // Reload, kick, refresh, audit and key management
+)
+
+// ParseRole converts a role name into a Role
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "viewer":
+		return RoleViewer, nil
+	case "debugger":
+		return RoleDebugger, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return 0, fmt.Errorf("unknown role %q, expected viewer, debugger or admin", name)
+	}
+}
+
+// Allows reports whether r grants access to routes requiring required
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// String returns the role name
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleDebugger:
+		return "debugger"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+}
+
+// MarshalText encodes the role by name
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a role name
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import "testing"
+
+// TestRoleAllows checks each role includes the ones below it
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleDebugger, false},
+		{RoleViewer, RoleAdmin, false},
+		{RoleDebugger, RoleViewer, true},
+		{RoleDebugger, RoleDebugger, true},
+		{RoleDebugger, RoleAdmin, false},
+		{RoleAdmin, RoleViewer, true},
+		{RoleAdmin, RoleDebugger, true},
+		{RoleAdmin, RoleAdmin, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+// TestParseRole checks role names round-trip and unknown names are refused
+func TestParseRole(t *testing.T) {
+	for _, role := range []Role{RoleViewer, RoleDebugger, RoleAdmin} {
+		got, err := ParseRole(role.String())
+		if err != nil || got != role {
+			t.Errorf("ParseRole(%q) = %v, %v, want %v", role.String(), got, err, role)
+		}
+	}
+
+	for _, name := range []string{"", "Admin", "root", "Role(3)"} {
+		if _, err := ParseRole(name); err == nil {
+			t.Errorf("ParseRole(%q) succeeded, want error", name)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package bench measures the streaming path in-process: memes drawn from an
+// in-memory pool are broadcast to synthetic subscribers that frame every event
+// as SSE, so throughput and allocation regressions show up without a network.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	server "github.com/elt0nxale/meme-tunnel-streamer/internal/server"
+)
+
+// bufferSize matches the per-subscriber buffer of the server's broadcaster
+const bufferSize = 16
+
+// Options configures a benchmark run
+type Options struct {
+	Subscribers int           // Number of synthetic subscribers
+	Duration    time.Duration // How long to keep broadcasting
+	PoolSize    int           // Number of memes in the in-memory pool
+}
+
+// Report summarises a benchmark run
+type Report struct {
+	Subscribers    int           `json:"subscribers"`
+	Broadcasts     int           `json:"broadcasts"`
+	Events         int           `json:"events"` // Delivered to and framed by a subscriber
+	EventsPerSec   float64       `json:"events_per_sec"`
+	BytesPerSec    float64       `json:"bytes_per_sec"`
+	AllocsPerEvent float64       `json:"allocs_per_event"`
+	BytesPerEvent  float64       `json:"alloc_bytes_per_event"`
+	GCCycles       uint32        `json:"gc_cycles"`
+	GCPause        time.Duration `json:"gc_pause"`
+	Elapsed        time.Duration `json:"elapsed"`
+}
+
+// countingWriter discards writes, counting their bytes
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Run broadcasts memes to the configured subscribers until the duration ends.
+// Memes go out in batches no larger than a subscriber's buffer and each batch
+// is drained before the next, so nothing is dropped and the rate reflects how
+// fast events are encoded, fanned out and framed
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Subscribers <= 0 {
+		return Report{}, fmt.Errorf("subscribers must be positive")
+	}
+	if opts.Duration <= 0 {
+		return Report{}, fmt.Errorf("duration must be positive")
+	}
+	if opts.PoolSize <= 0 {
+		return Report{}, fmt.Errorf("pool size must be positive")
+	}
+
+	pool := memeservice.NewService(nil, nil, nil)
+	pool.AddMemes(syntheticMemes(opts.PoolSize)...)
+	rng := rand.New(rand.NewSource(1))
+
+	b := broadcaster.NewBroadcaster(bufferSize)
+	writers := make([]*countingWriter, opts.Subscribers)
+	var batch sync.WaitGroup
+	var done sync.WaitGroup
+	for i := range writers {
+		writers[i] = &countingWriter{}
+		events := b.Subscribe(strconv.Itoa(i))
+		send := server.NewSSEWriter(writers[i])
+
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			for event := range events {
+				event.ID = strconv.FormatUint(event.Seq, 10)
+				send(event)
+				if event.Name == "batch" {
+					batch.Done()
+				}
+			}
+		}()
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	report := Report{Subscribers: opts.Subscribers}
+	for time.Since(start) < opts.Duration && ctx.Err() == nil {
+		batch.Add(opts.Subscribers)
+		for i := 0; i < bufferSize; i++ {
+			meme := pool.GetRandomMeme(rng, nil, memeservice.Filter{})
+			data, err := json.Marshal(meme)
+			if err != nil {
+				return Report{}, fmt.Errorf("failed to encode meme: %v", err)
+			}
+
+			// The last event of a batch tells subscribers it is complete
+			event := broadcaster.Event{Data: data}
+			if i == bufferSize-1 {
+				event.Name = "batch"
+			}
+			b.Broadcast(event)
+			report.Broadcasts++
+		}
+		batch.Wait()
+	}
+
+	report.Elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	for i := range writers {
+		b.Unsubscribe(strconv.Itoa(i))
+	}
+	done.Wait()
+
+	var written int64
+	for _, w := range writers {
+		written += w.n
+	}
+	report.Events = report.Broadcasts * opts.Subscribers
+	if report.Events > 0 {
+		report.AllocsPerEvent = float64(after.Mallocs-before.Mallocs) / float64(report.Events)
+		report.BytesPerEvent = float64(after.TotalAlloc-before.TotalAlloc) / float64(report.Events)
+	}
+	if report.Elapsed > 0 {
+		report.EventsPerSec = float64(report.Events) / report.Elapsed.Seconds()
+		report.BytesPerSec = float64(written) / report.Elapsed.Seconds()
+	}
+	report.GCCycles = after.NumGC - before.NumGC
+	report.GCPause = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+	return report, nil
+}
+
+// syntheticMemes builds a pool of n memes shaped like typical Reddit posts
+func syntheticMemes(n int) []memeservice.Meme {
+	memes := make([]memeservice.Meme, n)
+	for i := range memes {
+		memes[i] = memeservice.Meme{
+			ID:         fmt.Sprintf("bench%d", i),
+			Title:      fmt.Sprintf("When the benchmark finally runs %d", i),
+			URL:        fmt.Sprintf("https://i.redd.it/bench%d.jpg", i),
+			Width:      1080,
+			Height:     1350,
+			Size:       184320,
+			Score:      1000 + i,
+			Subreddit:  "memes",
+			Permalink:  fmt.Sprintf("/r/memes/comments/bench%d/", i),
+			CreatedUTC: 1700000000 + int64(i),
+			Tags:       []string{"meme", "benchmark"},
+			MediaType:  memeservice.MediaImage,
+		}
+	}
+	return memes
+}
+
+// String renders the report for the terminal
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subscribers:       %d\n", r.Subscribers)
+	fmt.Fprintf(&b, "Broadcasts:        %d\n", r.Broadcasts)
+	fmt.Fprintf(&b, "Events:            %d (%.0f/s)\n", r.Events, r.EventsPerSec)
+	fmt.Fprintf(&b, "Throughput:        %.1f MB/s\n", r.BytesPerSec/(1<<20))
+	fmt.Fprintf(&b, "Allocs per event:  %.2f\n", r.AllocsPerEvent)
+	fmt.Fprintf(&b, "Bytes per event:   %.0f\n", r.BytesPerEvent)
+	fmt.Fprintf(&b, "GC cycles:         %d\n", r.GCCycles)
+	fmt.Fprintf(&b, "GC pause:          %s\n", r.GCPause)
+	fmt.Fprintf(&b, "Elapsed:           %s\n", r.Elapsed.Round(time.Millisecond))
+	return b.String()
+}
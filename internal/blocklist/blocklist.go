@@ -0,0 +1,260 @@
+// Package blocklist matches images against a file of known-bad content
+// hashes: exact SHA-256 digests and perceptual dHashes that survive resizing
+// and recompression
+package blocklist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	caption "github.com/elt0nxale/meme-tunnel-streamer/internal/caption"
+)
+
+// reloadDebounce waits out editors writing the file in several steps
+const reloadDebounce = 500 * time.Millisecond
+
+// Fingerprint identifies an image's content
+type Fingerprint struct {
+	SHA256   [32]byte
+	DHash    uint64 // Perceptual hash of the first frame
+	HasDHash bool   // False when the data could not be decoded as an image
+}
+
+// String formats a fingerprint as blocklist lines
+func (fp Fingerprint) String() string {
+	s := "sha256:" + hex.EncodeToString(fp.SHA256[:])
+	if fp.HasDHash {
+		s += fmt.Sprintf("\ndhash:%016x", fp.DHash)
+	}
+	return s
+}
+
+// Compute fingerprints image data, decoding PNG, JPEG, GIF and WebP images
+// of at most caption.MaxPixels for the perceptual hash
+func Compute(data []byte) Fingerprint {
+	fp := Fingerprint{SHA256: sha256.Sum256(data)}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > caption.MaxPixels {
+		return fp
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fp
+	}
+	fp.DHash, fp.HasDHash = dHash(img), true
+	return fp
+}
+
+// dHash shrinks an image to 9x8 grayscale pixels and sets a bit for each
+// pixel brighter than its right neighbour
+func dHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	xdraw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Match is a blocklist entry an image matched
+type Match struct {
+	Entry string // As written in the file, e.g. "dhash:8f3c..."
+	Label string // Text after the hash on its line, if any
+}
+
+// entry is a perceptual hash line of the file
+type entry struct {
+	hash  uint64
+	match Match
+}
+
+// List is a reloadable set of blocked hashes
+type List struct {
+	path     string
+	distance int // Differing dHash bits still counted as a match
+
+	mu      sync.RWMutex
+	exact   map[[32]byte]Match
+	dhashes []entry
+
+	hits atomic.Int64
+}
+
+// Load reads a blocklist file of "sha256:<hex>" and "dhash:<hex>" lines,
+// each optionally followed by a label; blank lines and # comments are
+// skipped. dHashes match images within distance bits of them
+func Load(path string, distance int) (*List, error) {
+	if distance < 0 || distance > 64 {
+		return nil, fmt.Errorf("invalid blocklist distance %d, expected 0 to 64", distance)
+	}
+	l := &List{path: path, distance: distance}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload rereads the file, keeping the current entries if it is invalid
+func (l *List) Reload() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist: %v", err)
+	}
+	defer f.Close()
+
+	exact := make(map[[32]byte]Match)
+	var dhashes []entry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spec, label, _ := strings.Cut(line, " ")
+		match := Match{Entry: spec, Label: strings.TrimSpace(label)}
+
+		kind, value, _ := strings.Cut(spec, ":")
+		switch strings.ToLower(kind) {
+		case "sha256":
+			digest, err := hex.DecodeString(value)
+			if err != nil || len(digest) != sha256.Size {
+				return fmt.Errorf("blocklist %s line %d: invalid sha256 %q", l.path, n, value)
+			}
+			exact[[32]byte(digest)] = match
+		case "dhash":
+			hash, err := strconv.ParseUint(value, 16, 64)
+			if err != nil {
+				return fmt.Errorf("blocklist %s line %d: invalid dhash %q", l.path, n, value)
+			}
+			dhashes = append(dhashes, entry{hash: hash, match: match})
+		default:
+			return fmt.Errorf("blocklist %s line %d: expected sha256:<hex> or dhash:<hex>", l.path, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocklist: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.exact = exact
+	l.dhashes = dhashes
+	return nil
+}
+
+// Watch reloads the file when it changes until ctx is done, watching its
+// directory so files replaced by editors or deploys are picked up
+func (l *List) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	if err := watcher.Add(filepath.Dir(l.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", l.path, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(l.path) {
+					debounce = time.After(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Blocklist watcher error: %v", err)
+			case <-debounce:
+				debounce = nil
+				if err := l.Reload(); err != nil {
+					log.Printf("Blocklist reload failed: %v", err)
+					continue
+				}
+				log.Printf("Reloaded blocklist with %d entries", l.Len())
+			}
+		}
+	}()
+	return nil
+}
+
+// Len returns the number of hashes in the list
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.exact) + len(l.dhashes)
+}
+
+// Hits returns how many images have matched since the list was loaded
+func (l *List) Hits() int64 {
+	return l.hits.Load()
+}
+
+// Check reports whether a fingerprinted image is blocked, counting and
+// logging the hit; source names where the image came from
+func (l *List) Check(fp Fingerprint, source string) (Match, bool) {
+	match, ok := l.lookup(fp)
+	if ok {
+		l.hits.Add(1)
+		log.Printf("Blocklist hit: %s matches %s", source, strings.TrimSpace(match.Entry+" "+match.Label))
+	}
+	return match, ok
+}
+
+// lookup finds the entry matching a fingerprint, exact digests first
+func (l *List) lookup(fp Fingerprint) (Match, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if match, ok := l.exact[fp.SHA256]; ok {
+		return match, true
+	}
+	if !fp.HasDHash {
+		return Match{}, false
+	}
+	for _, e := range l.dhashes {
+		if bits.OnesCount64(e.hash^fp.DHash) <= l.distance {
+			return e.match, true
+		}
+	}
+	return Match{}, false
+}
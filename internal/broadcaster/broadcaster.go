@@ -0,0 +1,168 @@
+package broadcaster
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event represents a single named SSE event sent to subscribers
+type Event struct {
+	ID    string // SSE event ID, empty to leave the client's last ID unchanged
+	Name  string // SSE event name, empty for the default "message" event
+	Data  []byte
+	Retry time.Duration // SSE reconnection delay sent with the event, zero to omit
+	Seq   uint64        // Global sequence number assigned by Broadcast, zero for direct sends
+
+	// Encodings shared by every subscriber of a broadcast, nil for direct sends.
+	// Only valid while Name and Data are those that were broadcast
+	Rendered *Rendered
+}
+
+// Journal records broadcast events for later replay
+type Journal interface {
+	Append(seq uint64, name string, data []byte) error
+	LastSeq() uint64
+}
+
+// Sink receives a copy of every broadcast event; Publish is called with the
+// broadcaster locked, so it must not block
+type Sink interface {
+	Publish(event Event)
+}
+
+// Broadcaster fans out events to all subscribed streams
+type Broadcaster struct {
+	mu          sync.RWMutex
+	subscribers map[string]chan Event
+	bufferSize  int
+	journal     Journal
+	sinks       []Sink
+	seq         uint64 // Last assigned sequence number, guarded by mu
+}
+
+// NewBroadcaster creates a new broadcaster with the given per-subscriber buffer
+func NewBroadcaster(bufferSize int) *Broadcaster {
+	return &Broadcaster{
+		subscribers: make(map[string]chan Event),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe registers a stream and returns its event channel
+func (b *Broadcaster) Subscribe(id string) <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.bufferSize)
+	b.subscribers[id] = ch
+	return ch
+}
+
+// Unsubscribe removes a stream and closes its event channel
+func (b *Broadcaster) Unsubscribe(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch, exists := b.subscribers[id]; exists {
+		close(ch)
+		delete(b.subscribers, id)
+	}
+}
+
+// SetJournal records every broadcast event in j, continuing its sequence numbers
+func (b *Broadcaster) SetJournal(j Journal) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.journal = j
+	b.seq = max(b.seq, j.LastSeq())
+}
+
+// AddSink mirrors every later broadcast event to sink, in sequence order
+func (b *Broadcaster) AddSink(sink Sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sinks = append(b.sinks, sink)
+}
+
+// Broadcast numbers an event, sends it to every subscriber and drops it for slow
+// ones, who can spot the gap in the sequence
+func (b *Broadcaster) Broadcast(event Event) {
+	// Exclusive so sequence numbers reach every channel in order
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	event.Data = withSeq(event.Data, event.Seq)
+	event.Rendered = &Rendered{}
+
+	if b.journal != nil {
+		if err := b.journal.Append(event.Seq, event.Name, event.Data); err != nil {
+			log.Printf("Failed to journal %q event: %v", event.Name, err)
+		}
+	}
+	for _, sink := range b.sinks {
+		sink.Publish(event)
+	}
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// withSeq adds a "seq" field to JSON object payloads; other payloads carry it in the SSE id only
+func withSeq(data []byte, seq uint64) []byte {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return data
+	}
+
+	rest := bytes.TrimSpace(trimmed[1:])
+	field := fmt.Sprintf(`{"seq":%d`, seq)
+	if rest[0] != '}' {
+		field += ","
+	}
+	return append([]byte(field), rest...)
+}
+
+// LastSeq returns the sequence number of the latest broadcast event
+func (b *Broadcaster) LastSeq() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.seq
+}
+
+// Send delivers an event to a single subscriber, returning false if it is unknown or full
+func (b *Broadcaster) Send(id string, event Event) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ch, exists := b.subscribers[id]
+	if !exists {
+		return false
+	}
+
+	select {
+	case ch <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Count returns the number of active subscribers
+func (b *Broadcaster) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers)
+}
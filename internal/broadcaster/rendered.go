@@ -0,0 +1,31 @@
+package broadcaster
+
+import "sync"
+
+// Rendered memoizes the wire encodings of one broadcast event, so subscribers
+// writing it the same way share a single copy instead of each formatting it
+type Rendered struct {
+	mu    sync.Mutex
+	byKey map[string][]byte
+}
+
+// Get returns the encoding stored under key, rendering it on first use. A nil
+// Rendered, as on direct sends, renders every time
+func (r *Rendered) Get(key string, render func() []byte) []byte {
+	if r == nil {
+		return render()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if encoded, ok := r.byKey[key]; ok {
+		return encoded
+	}
+	if r.byKey == nil {
+		r.byKey = make(map[string][]byte)
+	}
+	encoded := render()
+	r.byKey[key] = encoded
+	return encoded
+}
@@ -0,0 +1,21 @@
+package broadcaster
+
+// Serializer controls the wire format of events: it receives each event with
+// Data holding the default JSON payload and returns the event to write, free to
+// rename fields, add metadata, wrap an envelope or change the name and ID
+type Serializer interface {
+	Serialize(event Event) (Event, error)
+}
+
+// SerializerFunc adapts a function to a Serializer
+type SerializerFunc func(Event) (Event, error)
+
+// Serialize calls f
+func (f SerializerFunc) Serialize(event Event) (Event, error) {
+	return f(event)
+}
+
+// DefaultSerializer writes events unchanged
+var DefaultSerializer Serializer = SerializerFunc(func(event Event) (Event, error) {
+	return event, nil
+})
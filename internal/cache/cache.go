@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores opaque byte values with a per-entry TTL
+type Cache interface {
+	// Get returns the cached value and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores a value for the given duration; a zero TTL never expires
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes a value
+	Delete(ctx context.Context, key string) error
+}
+
+// memoryEntry is a single cached value with its expiry
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// sweepInterval is how often Set drops expired entries that were never read again
+const sweepInterval = time.Minute
+
+// MemoryCache is an in-process Cache backed by a map
+type MemoryCache struct {
+	mu        sync.RWMutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryCache creates a new in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// Get returns the cached value if present and not expired
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.RLock()
+	entry, exists := c.entries[key]
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, false, nil
+	}
+
+	// Lazily evict expired entries
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+// Set stores a value with the given TTL
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		c.sweep(now)
+	}
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	c.entries[key] = entry
+	return nil
+}
+
+// sweep drops every expired entry, so keys that are never read again do not
+// pile up; callers hold the write lock
+func (c *MemoryCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// Delete removes a value from the cache
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	return nil
+}
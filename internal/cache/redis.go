@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCache is a Cache shared between instances through Redis
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisCache connects to the Redis server at the given URL
+func NewRedisCache(url string) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+
+	return &RedisCache{client: client, prefix: "meme-fetcher:"}, nil
+}
+
+// Get returns the cached value if present
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis get failed: %v", err)
+	}
+	return value, true, nil
+}
+
+// Set stores a value with the given TTL
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.client.Set(ctx, c.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set failed: %v", err)
+	}
+	return nil
+}
+
+// Delete removes a value from the cache
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	if err := c.client.Del(ctx, c.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis delete failed: %v", err)
+	}
+	return nil
+}
+
+// Close releases the underlying connection pool
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
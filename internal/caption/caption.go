@@ -0,0 +1,231 @@
+// Package caption renders classic meme captions, outlined capitals at the top
+// and bottom of an image, and keeps recent renders for serving
+package caption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxPixels bounds the source images accepted, so a small file cannot
+	// decode into gigabytes
+	MaxPixels = 16 << 20
+
+	// maxLines is how many lines a caption may wrap to before the text shrinks
+	maxLines = 3
+
+	// minFontSize is the smallest text drawn, in pixels
+	minFontSize = 12
+)
+
+// boldFont is the typeface captions are set in
+var boldFont = func() *opentype.Font {
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		panic(fmt.Sprintf("caption: failed to parse font: %v", err))
+	}
+	return f
+}()
+
+// Decode reads a PNG, JPEG, GIF (its first frame) or WebP image, rejecting
+// images over MaxPixels before decoding them
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("image is %dx%d, over the %d pixel limit", config.Width, config.Height, MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return img, nil
+}
+
+// Render draws top and bottom text over a copy of src in white capitals with
+// a black outline, shrinking the text until each fits in three lines
+func Render(src image.Image, top, bottom string) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	width, height := dst.Bounds().Dx(), dst.Bounds().Dy()
+	margin := max(4, width/40)
+	for i, text := range []string{top, bottom} {
+		text = strings.ToUpper(strings.Join(strings.Fields(text), " "))
+		if text == "" {
+			continue
+		}
+
+		face, lines, err := fit(text, width-2*margin, float64(max(minFontSize, width/9)))
+		if err != nil {
+			return nil, err
+		}
+		metrics := face.Metrics()
+		lineHeight := metrics.Height.Ceil()
+
+		y := margin + metrics.Ascent.Ceil()
+		if i == 1 {
+			y = height - margin - metrics.Descent.Ceil() - lineHeight*(len(lines)-1)
+		}
+		for _, line := range lines {
+			x := (width - font.MeasureString(face, line).Ceil()) / 2
+			drawOutlined(dst, face, line, x, y)
+			y += lineHeight
+		}
+		face.Close()
+	}
+	return dst, nil
+}
+
+// fit picks the largest font size, starting from size, at which text wraps
+// into at most maxLines lines of maxWidth pixels
+func fit(text string, maxWidth int, size float64) (font.Face, []string, error) {
+	for {
+		face, err := opentype.NewFace(boldFont, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load font: %v", err)
+		}
+		lines := wrap(face, text, maxWidth)
+		if len(lines) <= maxLines || size <= minFontSize {
+			return face, lines, nil
+		}
+		face.Close()
+		size = max(minFontSize, size*0.85)
+	}
+}
+
+// wrap breaks text into lines no wider than maxWidth, except for single
+// words that are wider on their own
+func wrap(face font.Face, text string, maxWidth int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line != "" && font.MeasureString(face, candidate).Ceil() > maxWidth {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawOutlined draws a line of text with its baseline at y, stroking it in
+// black around the white fill
+func drawOutlined(dst draw.Image, face font.Face, text string, x, y int) {
+	d := font.Drawer{Dst: dst, Src: image.Black, Face: face}
+	radius := max(1, face.Metrics().Height.Ceil()/18)
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			d.Dot = fixed.P(x+dx, y+dy)
+			d.DrawString(text)
+		}
+	}
+	d.Src = image.White
+	d.Dot = fixed.P(x, y)
+	d.DrawString(text)
+}
+
+// Label renders a single line of text in white with a black outline on a
+// transparent image just large enough to hold it, size pixels tall, e.g. for
+// watermarks
+func Label(text string, size float64) (*image.RGBA, error) {
+	face, err := opentype.NewFace(boldFont, &opentype.FaceOptions{Size: max(minFontSize, size), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	pad := max(1, metrics.Height.Ceil()/18)
+	width := font.MeasureString(face, text).Ceil() + 2*pad
+	height := metrics.Ascent.Ceil() + metrics.Descent.Ceil() + 2*pad
+	label := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawOutlined(label, face, text, pad, pad+metrics.Ascent.Ceil())
+	return label, nil
+}
+
+// Rendered is a captioned image encoded as PNG
+type Rendered struct {
+	PNG    []byte
+	Width  int
+	Height int
+}
+
+// Encode encodes a rendered caption as PNG
+func Encode(img *image.RGBA) (Rendered, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Rendered{}, fmt.Errorf("failed to encode caption: %v", err)
+	}
+	return Rendered{PNG: buf.Bytes(), Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+// Store keeps the most recent rendered captions in memory by name
+type Store struct {
+	mu    sync.Mutex
+	max   int
+	order []string // Names, oldest first
+	items map[string]Rendered
+}
+
+// NewStore creates a store holding at most max captions
+func NewStore(max int) *Store {
+	return &Store{max: max, items: make(map[string]Rendered)}
+}
+
+// Add stores a caption under a new random name such as "3f9a...c1.png", and
+// returns the names of the captions it evicted
+func (st *Store) Add(r Rendered) (string, []string) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	name := hex.EncodeToString(b) + ".png"
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.items[name] = r
+	st.order = append(st.order, name)
+
+	var evicted []string
+	for len(st.order) > st.max {
+		evicted = append(evicted, st.order[0])
+		delete(st.items, st.order[0])
+		st.order = st.order[1:]
+	}
+	return name, evicted
+}
+
+// Get returns a stored caption by name
+func (st *Store) Get(name string) (Rendered, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	r, ok := st.items[name]
+	return r, ok
+}
@@ -0,0 +1,175 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// contextKey stores the resolved client IP on the request context
+type contextKey struct{}
+
+// Resolver determines the real client IP behind trusted reverse proxies
+type Resolver struct {
+	trusted  []*net.IPNet
+	trustAll bool
+	hops     int // Proxies in front of every request, zero to go by the trusted list
+}
+
+// NewResolver creates a resolver trusting the given CIDRs or bare IPs ("*" trusts every peer)
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	resolver := &Resolver{}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		switch {
+		case proxy == "":
+			continue
+		case proxy == "*":
+			resolver.trustAll = true
+			continue
+		case !strings.Contains(proxy, "/"):
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// TrustHops returns a resolver for listeners only reachable through a fixed
+// number of proxies, such as the ngrok tunnel: the peer and the nearest
+// hops-1 forwarded addresses are the proxies, and the address before them is
+// the client. Entries further left are whatever the client sent and ignored
+func TrustHops(hops int) *Resolver {
+	return &Resolver{hops: max(hops, 1)}
+}
+
+// Middleware resolves the client IP once and stores it on the request context
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := res.Resolve(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, ip)))
+	})
+}
+
+// Resolve walks the forwarding chain from the nearest hop outwards, returning
+// the first address that is not a trusted proxy
+func (res *Resolver) Resolve(r *http.Request) string {
+	peer := hostOnly(r.RemoteAddr)
+	if res.hops > 0 {
+		// Only the header the proxies append to counts; any other is the client's own
+		chain := forwardedFor(r)
+		if len(chain) == 0 {
+			return peer
+		}
+		return chain[max(len(chain)-res.hops, 0)]
+	}
+	if !res.isTrusted(peer) {
+		return peer
+	}
+
+	chain := forwardedChain(r)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !res.isTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+
+	// Every hop is trusted, so the originating address is the best we have
+	if len(chain) > 0 {
+		return chain[0]
+	}
+	return peer
+}
+
+// isTrusted reports whether an address belongs to a trusted proxy
+func (res *Resolver) isTrusted(addr string) bool {
+	if res.trustAll {
+		return true
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range res.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedChain extracts client addresses from Forwarded, X-Forwarded-For and
+// X-Real-IP, ordered from the originating client to the nearest proxy
+func forwardedChain(r *http.Request) []string {
+	var chain []string
+
+	// RFC 7239 Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+	for _, header := range r.Header.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					if ip := hostOnly(strings.Trim(value, `"`)); net.ParseIP(ip) != nil {
+						chain = append(chain, ip)
+					}
+				}
+			}
+		}
+	}
+	if len(chain) > 0 {
+		return chain
+	}
+
+	// X-Forwarded-For as set by ngrok and most reverse proxies
+	chain = forwardedFor(r)
+	if len(chain) > 0 {
+		return chain
+	}
+
+	if ip := hostOnly(strings.TrimSpace(r.Header.Get("X-Real-IP"))); net.ParseIP(ip) != nil {
+		chain = append(chain, ip)
+	}
+	return chain
+}
+
+// forwardedFor extracts the X-Forwarded-For addresses, ordered from the
+// originating client to the nearest proxy
+func forwardedFor(r *http.Request) []string {
+	var chain []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if ip := hostOnly(strings.TrimSpace(hop)); net.ParseIP(ip) != nil {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+// hostOnly strips any port and IPv6 brackets from an address
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// FromRequest returns the resolved client IP, falling back to the peer address
+func FromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return hostOnly(r.RemoteAddr)
+}
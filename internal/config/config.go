@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings that can be changed at runtime by reloading the file.
+// Lists are pointers so a key that is present but empty, e.g. "api_keys: []",
+// clears the setting while an absent key leaves it alone
+type Config struct {
+	// Sources are subreddit specifications as accepted by --source
+	Sources *[]string `yaml:"sources"`
+
+	// Mastodon are hashtag URLs as accepted by --mastodon
+	Mastodon *[]string `yaml:"mastodon"`
+
+	// Custom are JSON APIs mapped onto memes
+	Custom *[]CustomProvider `yaml:"custom"`
+
+	// MemeInterval is the delay between memes pushed to each stream
+	MemeInterval time.Duration `yaml:"meme_interval"`
+
+	// RecentSize and RecentWindow control recently-sent suppression
+	RecentSize   int           `yaml:"recent_size"`
+	RecentWindow time.Duration `yaml:"recent_window"`
+
+	// APIKeys are key specifications as accepted by --api-key
+	APIKeys *[]string `yaml:"api_keys"`
+
+	// Cohorts are experiment cohorts as accepted by --cohort
+	Cohorts *[]string `yaml:"cohorts"`
+
+	// Media, Tags and MaxContentSize make up the server-wide filter, as
+	// accepted by --media, ?tags= and --max-content-size
+	Media          *[]string `yaml:"media"`
+	Tags           *[]string `yaml:"tags"`
+	MaxContentSize *string   `yaml:"max_content_size"`
+}
+
+// CustomProvider maps an arbitrary JSON API onto memes with JSONPath-style
+// paths such as "$.data.memes[*]" or "images[0].url"
+type CustomProvider struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // Values may reference environment variables as ${NAME}
+	TTL     time.Duration     `yaml:"ttl"`
+
+	// Items locates the list of memes; empty when the response is the list
+	Items string `yaml:"items"`
+
+	// Paths within each item; title and image are required
+	ID        string `yaml:"id"`
+	Title     string `yaml:"title"`
+	Image     string `yaml:"image"`
+	Permalink string `yaml:"permalink"`
+	Score     string `yaml:"score"`
+	NSFW      string `yaml:"nsfw"`
+}
+
+// Load reads and validates a YAML config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	if cfg.MemeInterval < 0 || cfg.RecentWindow < 0 || cfg.RecentSize < 0 {
+		return nil, fmt.Errorf("invalid config %s: durations and sizes must not be negative", path)
+	}
+	if cfg.Custom != nil {
+		for _, custom := range *cfg.Custom {
+			for name, value := range custom.Headers {
+				custom.Headers[name] = os.ExpandEnv(value)
+			}
+		}
+	}
+	return &cfg, nil
+}
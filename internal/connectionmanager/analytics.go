@@ -0,0 +1,178 @@
+package connectionmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// Analytics aggregates the retained connection logs
+type Analytics struct {
+	Bucket            string         `json:"bucket"`
+	Buckets           []Bucket       `json:"buckets"`
+	Connections       int            `json:"connections"`
+	Active            int            `json:"active"`
+	AvgDurationMs     float64        `json:"avg_duration_ms"` // Over ended connections
+	DisconnectReasons map[string]int `json:"disconnect_reasons"`
+	TopUserAgents     []AgentCount   `json:"top_user_agents"`
+	Browsers          map[string]int `json:"browsers"`
+	OperatingSystems  map[string]int `json:"operating_systems"`
+	Devices           map[string]int `json:"devices"`
+}
+
+// Bucket counts the connections opened in one time bucket
+type Bucket struct {
+	Start         time.Time `json:"start"`
+	Connections   int       `json:"connections"`
+	Ended         int       `json:"ended"`
+	AvgDurationMs float64   `json:"avg_duration_ms"` // Over the bucket's ended connections
+}
+
+// AgentCount is the number of connections made with one user agent
+type AgentCount struct {
+	UserAgent   string `json:"user_agent"`
+	Connections int    `json:"connections"`
+}
+
+// disconnectReasons classifies connection events, strongest evidence first
+var disconnectReasons = []struct {
+	reason string
+	match  func(event string) bool
+}{
+	{"kicked", func(e string) bool { return e == "Kicked by admin" }},
+	{"quota", func(e string) bool { return strings.Contains(e, "quota") }},
+	{"send_error", func(e string) bool { return strings.HasPrefix(e, "Event Send Error") }},
+	{"upstream_error", func(e string) bool { return strings.HasPrefix(e, "Meme Fetch Error") }},
+	{"client_closed", func(e string) bool { return e == "Client connection closed" }},
+}
+
+// disconnectReason explains why an ended connection ended
+func disconnectReason(events []string) string {
+	for _, candidate := range disconnectReasons {
+		for _, event := range events {
+			if candidate.match(event) {
+				return candidate.reason
+			}
+		}
+	}
+	return "other"
+}
+
+// Analytics buckets the retained connections by opening time and summarises
+// their durations, disconnect reasons and top n user agents
+func (cm *Manager) Analytics(bucket time.Duration, n int) Analytics {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	result := Analytics{
+		Bucket:            bucket.String(),
+		Buckets:           []Bucket{},
+		Connections:       len(cm.connections),
+		DisconnectReasons: make(map[string]int),
+		TopUserAgents:     []AgentCount{},
+		Browsers:          make(map[string]int),
+		OperatingSystems:  make(map[string]int),
+		Devices:           make(map[string]int),
+	}
+
+	buckets := make(map[time.Time]*Bucket)
+	agents := make(map[string]int)
+	var totalMs float64
+	var ended int
+	for _, conn := range cm.connections {
+		start := conn.Timestamp.Truncate(bucket)
+		b, exists := buckets[start]
+		if !exists {
+			b = &Bucket{Start: start}
+			buckets[start] = b
+		}
+		b.Connections++
+
+		if agent := conn.RequestHeaders.Get("User-Agent"); agent != "" {
+			agents[agent]++
+		}
+		if conn.Agent != nil {
+			result.Browsers[conn.Agent.Browser]++
+			if conn.Agent.OS != "" {
+				result.OperatingSystems[conn.Agent.OS]++
+			}
+			result.Devices[conn.Agent.Device]++
+		}
+
+		if conn.ClosedAt == nil {
+			result.Active++
+			continue
+		}
+
+		ms := float64(conn.ClosedAt.Sub(conn.Timestamp)) / float64(time.Millisecond)
+		b.AvgDurationMs += ms // Summed here, divided below
+		b.Ended++
+		totalMs += ms
+		ended++
+		result.DisconnectReasons[disconnectReason(conn.Events)]++
+	}
+
+	for _, b := range buckets {
+		if b.Ended > 0 {
+			b.AvgDurationMs /= float64(b.Ended)
+		}
+		result.Buckets = append(result.Buckets, *b)
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Start.Before(result.Buckets[j].Start)
+	})
+	if ended > 0 {
+		result.AvgDurationMs = totalMs / float64(ended)
+	}
+
+	for agent, count := range agents {
+		result.TopUserAgents = append(result.TopUserAgents, AgentCount{UserAgent: agent, Connections: count})
+	}
+	sort.Slice(result.TopUserAgents, func(i, j int) bool {
+		a, b := result.TopUserAgents[i], result.TopUserAgents[j]
+		if a.Connections != b.Connections {
+			return a.Connections > b.Connections
+		}
+		return a.UserAgent < b.UserAgent
+	})
+	if len(result.TopUserAgents) > n {
+		result.TopUserAgents = result.TopUserAgents[:n]
+	}
+
+	return result
+}
+
+// AnalyticsHandler serves connection analytics bucketed by ?bucket= (default
+// 1h) with the ?top= (default 10) most common user agents
+func (cm *Manager) AnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket := time.Hour
+	if raw := r.URL.Query().Get("bucket"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < time.Minute {
+			apierror.Write(w, r, apierror.BadRequest("invalid bucket, expected a duration of at least 1m"))
+			return
+		}
+		bucket = parsed
+	}
+
+	top := 10
+	if raw := r.URL.Query().Get("top"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid top"))
+			return
+		}
+		top = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cm.Analytics(bucket, top)); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,131 @@
+package connectionmanager
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ClientSummary groups the connections made by one client token
+type ClientSummary struct {
+	Client      string    `json:"client"`
+	Connections []string  `json:"connections"`
+	Active      int       `json:"active"`
+	Reconnects  int       `json:"reconnects"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Gaps        *Gaps     `json:"gaps,omitempty"`
+}
+
+// Gaps summarises the time a client spent disconnected between connections
+type Gaps struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"last_ms"`
+	MinMs  float64 `json:"min_ms"`
+	MaxMs  float64 `json:"max_ms"`
+	AvgMs  float64 `json:"avg_ms"`
+}
+
+// SetClient records the stable client token behind a connection
+func (cm *Manager) SetClient(connID, client string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if conn, exists := cm.connections[connID]; exists {
+		conn.Client = client
+	}
+}
+
+// CloseConnection records when a connection ended, so reconnect gaps can be measured
+func (cm *Manager) CloseConnection(connID string) {
+	cm.mu.Lock()
+	conn, exists := cm.connections[connID]
+	if !exists {
+		delete(cm.unsampled, connID)
+		cm.mu.Unlock()
+		return
+	}
+	closed := time.Now()
+	conn.ClosedAt = &closed
+
+	var line []byte
+	if cm.archive != nil {
+		line, _ = json.Marshal(conn)
+	}
+	archive := cm.archive
+	cm.mu.Unlock()
+
+	if line != nil {
+		archive.Write(append(line, '\n'))
+	}
+}
+
+// ClientSummaries groups retained connections by client token, most reconnects first
+func (cm *Manager) ClientSummaries() []ClientSummary {
+	cm.mu.RLock()
+	byClient := make(map[string][]ConnectionLog)
+	for _, conn := range cm.connections {
+		if conn.Client != "" {
+			byClient[conn.Client] = append(byClient[conn.Client], *conn)
+		}
+	}
+	cm.mu.RUnlock()
+
+	summaries := make([]ClientSummary, 0, len(byClient))
+	for client, conns := range byClient {
+		sort.Slice(conns, func(i, j int) bool {
+			return conns[i].Timestamp.Before(conns[j].Timestamp)
+		})
+
+		summary := ClientSummary{
+			Client:     client,
+			Reconnects: len(conns) - 1,
+			FirstSeen:  conns[0].Timestamp,
+			LastSeen:   conns[len(conns)-1].Timestamp,
+		}
+		for i, conn := range conns {
+			summary.Connections = append(summary.Connections, conn.ID)
+			if conn.ClosedAt == nil {
+				summary.Active++
+			} else if conn.ClosedAt.After(summary.LastSeen) {
+				summary.LastSeen = *conn.ClosedAt
+			}
+
+			// A gap runs from one connection closing to the next opening
+			if i == 0 || conns[i-1].ClosedAt == nil {
+				continue
+			}
+			gap := conn.Timestamp.Sub(*conns[i-1].ClosedAt)
+			if gap < 0 {
+				continue // Overlapping connections, e.g. several tabs
+			}
+			summary.Gaps = summary.Gaps.add(float64(gap) / float64(time.Millisecond))
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Reconnects != summaries[j].Reconnects {
+			return summaries[i].Reconnects > summaries[j].Reconnects
+		}
+		return summaries[i].Client < summaries[j].Client
+	})
+	return summaries
+}
+
+// add folds a gap sample into the statistics, allocating them on first use
+func (g *Gaps) add(ms float64) *Gaps {
+	if g == nil {
+		g = &Gaps{MinMs: ms, MaxMs: ms}
+	}
+	g.AvgMs = (g.AvgMs*float64(g.Count) + ms) / float64(g.Count+1)
+	g.Count++
+	g.LastMs = ms
+	if ms < g.MinMs {
+		g.MinMs = ms
+	}
+	if ms > g.MaxMs {
+		g.MaxMs = ms
+	}
+	return g
+}
@@ -1,21 +1,48 @@
 package connectionmanager
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	clientip "github.com/elt0nxale/meme-tunnel-streamer/internal/clientip"
+	useragent "github.com/elt0nxale/meme-tunnel-streamer/internal/useragent"
 )
 
 // ConnectionLog represents a detailed log of a single connection
 type ConnectionLog struct {
-	ID             string      `json:"id"`
-	Timestamp      time.Time   `json:"timestamp"`
-	RemoteAddr     string      `json:"remote_addr"`
-	RequestHeaders http.Header `json:"request_headers"`
-	RequestPath    string      `json:"request_path"` // New field for request path
-	Events         []string    `json:"events"`
+	ID             string           `json:"id"`
+	Timestamp      time.Time        `json:"timestamp"`
+	RemoteAddr     string           `json:"remote_addr"`
+	ClientIP       string           `json:"client_ip"` // Real client behind trusted proxies
+	RequestHeaders http.Header      `json:"request_headers"`
+	RequestPath    string           `json:"request_path"` // New field for request path
+	Method         string           `json:"method"`
+	URL            string           `json:"url"`   // Full URL as the client sent it, with redacted query parameters
+	Proto          string           `json:"proto"` // HTTP version, e.g. HTTP/1.1
+	Events         []string         `json:"events"`
+	Latency        *Latency         `json:"latency,omitempty"`
+	APIKey         string           `json:"api_key,omitempty"` // Name of the authenticating key
+	Client         string           `json:"client,omitempty"`  // Stable token shared by a client's reconnects
+	Agent          *useragent.Agent `json:"agent,omitempty"`   // Parsed User-Agent
+	ClosedAt       *time.Time       `json:"closed_at,omitempty"`
+}
+
+// Latency summarises round-trip times measured with ping/pong events
+type Latency struct {
+	Samples int     `json:"samples"`
+	LastMs  float64 `json:"last_ms"`
+	MinMs   float64 `json:"min_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	AvgMs   float64 `json:"avg_ms"`
+	totalMs float64
 }
 
 // Manager handles multiple SSE connections and their logs
@@ -23,36 +50,68 @@ type Manager struct {
 	mu             sync.RWMutex
 	connections    map[string]*ConnectionLog
 	maxConnections int
+	keyUsage       map[string]*keyUsage
+	redact         map[string]bool            // Canonical names of headers masked in logs
+	archive        io.Writer                  // Receives each ended connection's log as a JSON line, nil when disabled
+	byIP           map[string]map[string]bool // Connection IDs by client and remote IP, for search
+	frames         map[string]*frameBuffer    // Captured writes by connection ID
+	captureFrames  int                        // Frames kept per connection, 0 when capture is disabled
+	captureBytes   int64                      // Bytes kept per connection, 0 for no limit
+	sampleRate     float64                    // Fraction of connections logged in full
+	sampling       SamplingStats              // Counts of sampled and unsampled connections
+	unsampled      map[string]bool            // IDs of open connections only counted
 }
 
 // NewManager creates a new connection manager
 func NewManager(maxConnections int) *Manager {
-	return &Manager{
+	cm := &Manager{
 		connections:    make(map[string]*ConnectionLog),
 		maxConnections: maxConnections,
+		keyUsage:       make(map[string]*keyUsage),
+		redact:         make(map[string]bool),
+		byIP:           make(map[string]map[string]bool),
+		frames:         make(map[string]*frameBuffer),
+		sampleRate:     1,
+		unsampled:      make(map[string]bool),
 	}
+	cm.RedactHeaders(DefaultRedactedHeaders...)
+	return cm
 }
 
 // AddConnection registers a new connection and returns its ID
 func (cm *Manager) AddConnection(r *http.Request) string {
+	headers := cm.Redacted(r.Header)
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	// Generate unique connection ID
-	connID := fmt.Sprintf("conn_%d", len(cm.connections)+1)
+	connID := newConnID()
+	if !cm.sample(connID) {
+		return connID
+	}
 
 	// Create connection log
 	connLog := &ConnectionLog{
 		ID:             connID,
 		Timestamp:      time.Now(),
 		RemoteAddr:     r.RemoteAddr,
-		RequestHeaders: r.Header,
+		ClientIP:       clientip.FromRequest(r),
+		APIKey:         keyName(r),
+		RequestHeaders: headers,
 		RequestPath:    r.URL.Path, // Capture the request path
+		Method:         r.Method,
+		URL:            requestURL(r),
+		Proto:          r.Proto,
 		Events:         []string{},
 	}
+	if ua := r.UserAgent(); ua != "" {
+		agent := useragent.Parse(ua)
+		connLog.Agent = &agent
+	}
 
 	// Add log entry
 	cm.connections[connID] = connLog
+	cm.index(connLog)
 
 	// Trim connections if exceeding max
 	if len(cm.connections) > cm.maxConnections {
@@ -66,12 +125,40 @@ func (cm *Manager) AddConnection(r *http.Request) string {
 			}
 		}
 
-		delete(cm.connections, oldestKey)
+		cm.remove(oldestKey)
 	}
 
 	return connID
 }
 
+// newConnID returns a random connection ID. Stream controls are addressed by
+// ID, so one ID must not reveal any other
+func newConnID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// A guessable fallback would hand out control of other streams
+		panic(fmt.Sprintf("failed to generate connection ID: %v", err))
+	}
+	return "conn_" + hex.EncodeToString(b)
+}
+
+// SetArchive writes the log of every connection that ends to w, one JSON
+// object per line, so it outlives eviction and restarts
+func (cm *Manager) SetArchive(w io.Writer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.archive = w
+}
+
+// keyName returns the name of the API key used by a request, if any
+func keyName(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return key.Name
+	}
+	return ""
+}
+
 // AddConnectionEvent logs an event for a specific connection
 func (cm *Manager) AddConnectionEvent(connID, event string) {
 	cm.mu.Lock()
@@ -79,28 +166,118 @@ func (cm *Manager) AddConnectionEvent(connID, event string) {
 
 	if conn, exists := cm.connections[connID]; exists {
 		conn.Events = append(conn.Events, event)
+	} else if cm.unsampled[connID] {
+		cm.sampling.UnsampledEvents++
+	}
+}
+
+// RecordLatency adds a round-trip time sample for a specific connection
+func (cm *Manager) RecordLatency(connID string, rtt time.Duration) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	conn, exists := cm.connections[connID]
+	if !exists {
+		return
+	}
+
+	ms := float64(rtt) / float64(time.Millisecond)
+	if conn.Latency == nil {
+		conn.Latency = &Latency{MinMs: ms, MaxMs: ms}
+	}
+
+	latency := conn.Latency
+	latency.Samples++
+	latency.LastMs = ms
+	latency.totalMs += ms
+	latency.AvgMs = latency.totalMs / float64(latency.Samples)
+	if ms < latency.MinMs {
+		latency.MinMs = ms
+	}
+	if ms > latency.MaxMs {
+		latency.MaxMs = ms
 	}
 }
 
-// GetConnectionLogs retrieves all connection logs
-func (cm *Manager) GetConnectionLogs() []*ConnectionLog {
+// GetConnectionLogs retrieves snapshots of all connection logs, which later
+// events on the connections leave untouched
+func (cm *Manager) GetConnectionLogs() []ConnectionLog {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	logs := make([]*ConnectionLog, 0, len(cm.connections))
+	logs := make([]ConnectionLog, 0, len(cm.connections))
 	for _, log := range cm.connections {
-		logs = append(logs, log)
+		logs = append(logs, log.snapshot())
 	}
 	return logs
 }
 
-// DebugHandler provides an endpoint to retrieve connection logs
+// snapshot deep-copies a connection log so it can be read without the lock;
+// callers hold at least a read lock
+func (conn *ConnectionLog) snapshot() ConnectionLog {
+	result := *conn
+	result.RequestHeaders = conn.RequestHeaders.Clone()
+	result.Events = append([]string(nil), conn.Events...)
+	if conn.Latency != nil {
+		latency := *conn.Latency
+		result.Latency = &latency
+	}
+	if conn.Agent != nil {
+		agent := *conn.Agent
+		result.Agent = &agent
+	}
+	if conn.ClosedAt != nil {
+		closedAt := *conn.ClosedAt
+		result.ClosedAt = &closedAt
+	}
+	return result
+}
+
+// PurgeClosed removes the logs of connections that ended before cutoff, or of
+// every ended connection when cutoff is zero, returning how many were removed
+func (cm *Manager) PurgeClosed(cutoff time.Time) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	purged := 0
+	for id, conn := range cm.connections {
+		if conn.ClosedAt == nil || (!cutoff.IsZero() && !conn.ClosedAt.Before(cutoff)) {
+			continue
+		}
+		cm.remove(id)
+		purged++
+	}
+	return purged
+}
+
+// EventTails returns the last n events of every connection
+func (cm *Manager) EventTails(n int) map[string][]string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	tails := make(map[string][]string, len(cm.connections))
+	for id, conn := range cm.connections {
+		start := len(conn.Events) - n
+		if start < 0 {
+			start = 0
+		}
+		tails[id] = append([]string(nil), conn.Events[start:]...)
+	}
+	return tails
+}
+
+// DebugHandler provides an endpoint to retrieve connection logs, or with
+// ?group=client the connections grouped by client token
 func (cm *Manager) DebugHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	logs := cm.GetConnectionLogs()
 
-	if err := json.NewEncoder(w).Encode(logs); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var body any = cm.GetConnectionLogs()
+	if r.URL.Query().Get("group") == "client" {
+		body = cm.ClientSummaries()
+	}
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 }
@@ -0,0 +1,139 @@
+package connectionmanager
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestConnection registers a connection the way a stream handler does
+func newTestConnection(t *testing.T, cm *Manager, i int) string {
+	t.Helper()
+
+	r := httptest.NewRequest("GET", "/memes?api_key=secret", nil)
+	r.RemoteAddr = fmt.Sprintf("192.0.2.%d:4000", i%250+1)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Safari/604.1")
+	return cm.AddConnection(r)
+}
+
+// mutate scribbles over every field of a snapshot that shares memory with the
+// manager unless it was deep-copied
+func mutate(conn ConnectionLog) {
+	conn.RequestHeaders.Set("Authorization", "leaked")
+	conn.RequestHeaders.Add("X-Mutated", "1")
+	if len(conn.Events) > 0 {
+		conn.Events[0] = "mutated"
+	}
+	_ = append(conn.Events, "appended")
+	if conn.Latency != nil {
+		conn.Latency.Samples = -1
+	}
+	if conn.Agent != nil {
+		conn.Agent.Browser = "mutated"
+	}
+	if conn.ClosedAt != nil {
+		*conn.ClosedAt = time.Time{}
+	}
+}
+
+// TestConcurrentAccess reads and mutates snapshots while connections gain
+// events and end; run with -race
+func TestConcurrentAccess(t *testing.T) {
+	const (
+		connections = 20
+		writes      = 200
+	)
+
+	cm := NewManager(connections * 2)
+	ids := make([]string, connections)
+	for i := range ids {
+		ids[i] = newTestConnection(t, cm, i)
+	}
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cm.SetClient(id, fmt.Sprintf("client_%d", i))
+			for n := range writes {
+				cm.AddConnectionEvent(id, fmt.Sprintf("event %d", n))
+				cm.RecordLatency(id, time.Duration(n)*time.Millisecond)
+			}
+			cm.CloseConnection(id)
+		}()
+	}
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for n := range writes {
+				for _, conn := range cm.GetConnectionLogs() {
+					mutate(conn)
+				}
+				for _, conn := range cm.Search(Query{EventContains: "event", Agent: "safari"}) {
+					mutate(conn)
+				}
+				if conn, ok := cm.Connection(ids[n%connections]); ok {
+					mutate(conn)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		conn, ok := cm.Connection(id)
+		if !ok {
+			t.Fatalf("connection %s missing", id)
+		}
+		if got := len(conn.Events); got != writes {
+			t.Errorf("connection %s has %d events, want %d", id, got, writes)
+		}
+		if conn.Events[0] != "event 0" {
+			t.Errorf("connection %s first event is %q, a snapshot leaked a write", id, conn.Events[0])
+		}
+		if got := conn.RequestHeaders.Get("Authorization"); got != redacted {
+			t.Errorf("connection %s Authorization is %q, want %q", id, got, redacted)
+		}
+		if conn.RequestHeaders.Get("X-Mutated") != "" {
+			t.Errorf("connection %s headers were changed through a snapshot", id)
+		}
+		if conn.Latency == nil || conn.Latency.Samples != writes {
+			t.Errorf("connection %s latency is %+v, want %d samples", id, conn.Latency, writes)
+		}
+		if conn.Agent == nil || conn.Agent.Browser == "mutated" {
+			t.Errorf("connection %s agent is %+v", id, conn.Agent)
+		}
+		if conn.ClosedAt == nil || conn.ClosedAt.IsZero() {
+			t.Errorf("connection %s close time is %v", id, conn.ClosedAt)
+		}
+	}
+}
+
+// TestConnectionIDsUnique checks IDs are random and stay unique across purges and managers
+func TestConnectionIDsUnique(t *testing.T) {
+	first, second := NewManager(5), NewManager(5)
+
+	seen := make(map[string]bool)
+	for i := range 20 {
+		for _, cm := range []*Manager{first, second} {
+			id := newTestConnection(t, cm, i)
+			if len(id) != len("conn_")+32 {
+				t.Fatalf("connection ID %s is not 16 random bytes", id)
+			}
+			if seen[id] {
+				t.Fatalf("connection ID %s issued twice", id)
+			}
+			seen[id] = true
+			cm.CloseConnection(id)
+		}
+		first.PurgeClosed(time.Time{})
+	}
+}
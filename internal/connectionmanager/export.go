@@ -0,0 +1,167 @@
+package connectionmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// exportSkipped are headers curl derives itself or that break replaying a stream
+var exportSkipped = map[string]bool{
+	"Accept-Encoding": true, // Replaced by --compressed
+	"Connection":      true,
+	"Content-Length":  true,
+}
+
+// Connection returns a copy of one connection's log
+func (cm *Manager) Connection(connID string) (ConnectionLog, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	conn, exists := cm.connections[connID]
+	if !exists {
+		return ConnectionLog{}, false
+	}
+
+	return conn.snapshot(), true
+}
+
+// ExportHandler reconstructs the request that opened the {connID} path value
+// as a curl command (?format=curl, the default) or a HAR document (?format=har)
+func (cm *Manager) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	connID := r.PathValue("connID")
+	conn, ok := cm.Connection(connID)
+	if !ok {
+		apierror.Write(w, r, apierror.NotFound("connection "+connID+" not found"))
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "curl":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, curlCommand(conn))
+	case "har":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", connID+".har"))
+		if err := json.NewEncoder(w).Encode(harDocument(conn)); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	default:
+		apierror.Write(w, r, apierror.BadRequest("invalid format "+format+", expected curl or har"))
+	}
+}
+
+// sortedHeaderNames lists header names in a stable order
+func sortedHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// curlCommand builds a shell command repeating a connection's request;
+// -N streams events as they arrive
+func curlCommand(conn ConnectionLog) string {
+	args := []string{"curl", "-N"}
+	if conn.Method != "" && conn.Method != http.MethodGet {
+		args = append(args, "-X", conn.Method)
+	}
+	if conn.Proto == "HTTP/2.0" {
+		args = append(args, "--http2")
+	}
+	if conn.RequestHeaders.Get("Accept-Encoding") != "" {
+		args = append(args, "--compressed")
+	}
+
+	for _, name := range sortedHeaderNames(conn.RequestHeaders) {
+		if exportSkipped[name] {
+			continue
+		}
+		for _, value := range conn.RequestHeaders[name] {
+			args = append(args, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	return strings.Join(append(args, shellQuote(conn.URL)), " ")
+}
+
+// shellQuote single-quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// harNameValue is a HAR header or query parameter
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harDocument wraps a connection's request in a single-entry HAR 1.2 log;
+// the response body was streamed and is not recorded, so only its timing is
+func harDocument(conn ConnectionLog) map[string]any {
+	headers := []harNameValue{}
+	for _, name := range sortedHeaderNames(conn.RequestHeaders) {
+		for _, value := range conn.RequestHeaders[name] {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	queryString := []harNameValue{}
+	if u, err := url.Parse(conn.URL); err == nil {
+		query := u.Query()
+		for _, name := range sortedHeaderNames(http.Header(query)) {
+			for _, value := range query[name] {
+				queryString = append(queryString, harNameValue{Name: name, Value: value})
+			}
+		}
+	}
+
+	var durationMs float64 = -1
+	if conn.ClosedAt != nil {
+		durationMs = float64(conn.ClosedAt.Sub(conn.Timestamp)) / float64(time.Millisecond)
+	}
+
+	return map[string]any{
+		"log": map[string]any{
+			"version": "1.2",
+			"creator": map[string]string{"name": "meme-fetcher", "version": "1"},
+			"entries": []map[string]any{{
+				"startedDateTime": conn.Timestamp.Format(time.RFC3339Nano),
+				"time":            durationMs,
+				"comment":         conn.ID,
+				"request": map[string]any{
+					"method":      conn.Method,
+					"url":         conn.URL,
+					"httpVersion": conn.Proto,
+					"headers":     headers,
+					"queryString": queryString,
+					"cookies":     []any{},
+					"headersSize": -1,
+					"bodySize":    0,
+				},
+				"response": map[string]any{
+					"status":      0,
+					"statusText":  "",
+					"httpVersion": conn.Proto,
+					"headers":     []any{},
+					"cookies":     []any{},
+					"content":     map[string]any{"size": -1, "mimeType": "text/event-stream"},
+					"redirectURL": "",
+					"headersSize": -1,
+					"bodySize":    -1,
+				},
+				"cache":   map[string]any{},
+				"timings": map[string]any{"send": 0, "wait": 0, "receive": durationMs},
+			}},
+		},
+	}
+}
@@ -0,0 +1,131 @@
+package connectionmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// Frame is one write to a connection, captured byte for byte
+type Frame struct {
+	Time time.Time `json:"time"`
+	Data string    `json:"data"`
+}
+
+// Transports frames are captured from
+const (
+	TransportSSE       = "sse"
+	TransportWebSocket = "websocket" // Frames are message payloads
+)
+
+// frameBuffer keeps a connection's most recent frames within the capture limits
+type frameBuffer struct {
+	transport string
+	frames    []Frame
+	bytes     int64
+	dropped   int // Older frames discarded to stay within the limits
+}
+
+// framesResponse is the body of the frames endpoint
+type framesResponse struct {
+	ConnID    string  `json:"conn_id"`
+	Transport string  `json:"transport,omitempty"`
+	Frames    []Frame `json:"frames"`
+	Dropped   int     `json:"dropped"`
+}
+
+// SetFrameCapture records the last maxFrames writes to every new connection,
+// up to maxBytes per connection; zero maxFrames disables capture
+func (cm *Manager) SetFrameCapture(maxFrames int, maxBytes int64) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.captureFrames = maxFrames
+	cm.captureBytes = maxBytes
+}
+
+// CapturingFrames reports whether frame capture is enabled
+func (cm *Manager) CapturingFrames() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.captureFrames > 0
+}
+
+// CaptureFrame records bytes written to a connection over a transport,
+// discarding its oldest frames once over the limits
+func (cm *Manager) CaptureFrame(connID, transport string, data []byte) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.captureFrames <= 0 {
+		return
+	}
+	if _, exists := cm.connections[connID]; !exists {
+		return
+	}
+
+	buf, exists := cm.frames[connID]
+	if !exists {
+		buf = &frameBuffer{transport: transport}
+		cm.frames[connID] = buf
+	}
+
+	buf.frames = append(buf.frames, Frame{Time: time.Now(), Data: string(data)})
+	buf.bytes += int64(len(data))
+	for len(buf.frames) > 1 && (len(buf.frames) > cm.captureFrames || (cm.captureBytes > 0 && buf.bytes > cm.captureBytes)) {
+		buf.bytes -= int64(len(buf.frames[0].Data))
+		buf.frames = buf.frames[1:]
+		buf.dropped++
+	}
+}
+
+// Frames returns a copy of the frames captured for a connection
+func (cm *Manager) Frames(connID string) (framesResponse, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if _, exists := cm.connections[connID]; !exists {
+		return framesResponse{}, false
+	}
+
+	result := framesResponse{ConnID: connID, Frames: []Frame{}}
+	if buf, exists := cm.frames[connID]; exists {
+		result.Transport = buf.transport
+		result.Frames = append(result.Frames, buf.frames...)
+		result.Dropped = buf.dropped
+	}
+	return result, true
+}
+
+// FramesHandler serves the frames captured for the {connID} path value, as
+// JSON or with ?format=raw as the bytes exactly as written
+func (cm *Manager) FramesHandler(w http.ResponseWriter, r *http.Request) {
+	connID := r.PathValue("connID")
+	if !cm.CapturingFrames() {
+		apierror.Write(w, r, apierror.NotFound("frame capture is disabled"))
+		return
+	}
+
+	captured, ok := cm.Frames(connID)
+	if !ok {
+		apierror.Write(w, r, apierror.NotFound("connection "+connID+" not found"))
+		return
+	}
+
+	if r.URL.Query().Get("format") == "raw" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		for _, frame := range captured.Frames {
+			w.Write([]byte(frame.Data))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(captured); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
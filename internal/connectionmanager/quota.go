@@ -0,0 +1,126 @@
+package connectionmanager
+
+import (
+	"sort"
+	"time"
+)
+
+// KeyUsage reports how much of its quota an API key has consumed
+type KeyUsage struct {
+	Name          string `json:"name"`
+	ActiveStreams int    `json:"active_streams"`
+	MaxStreams    int    `json:"max_streams"`
+	EventsToday   int    `json:"events_today"`
+	EventsPerDay  int    `json:"events_per_day"`
+	Day           string `json:"day"`
+}
+
+// keyUsage is the mutable per-key counter state
+type keyUsage struct {
+	activeStreams int
+	eventsToday   int
+	day           string
+}
+
+// usageFor returns the counters for a key, resetting daily counts at UTC midnight
+func (cm *Manager) usageFor(name string) *keyUsage {
+	today := time.Now().UTC().Format("2006-01-02")
+
+	usage, exists := cm.keyUsage[name]
+	if !exists {
+		usage = &keyUsage{day: today}
+		cm.keyUsage[name] = usage
+	}
+	if usage.day != today {
+		usage.day = today
+		usage.eventsToday = 0
+	}
+	return usage
+}
+
+// AcquireStream reserves a concurrent stream slot for a key, returning false
+// when the key is already at maxStreams (0 means unlimited)
+func (cm *Manager) AcquireStream(name string, maxStreams int) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	usage := cm.usageFor(name)
+	if maxStreams > 0 && usage.activeStreams >= maxStreams {
+		return false
+	}
+	usage.activeStreams++
+	return true
+}
+
+// ReleaseStream frees a stream slot reserved by AcquireStream
+func (cm *Manager) ReleaseStream(name string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if usage := cm.usageFor(name); usage.activeStreams > 0 {
+		usage.activeStreams--
+	}
+}
+
+// KeyStreams returns how many stream slots a key currently holds
+func (cm *Manager) KeyStreams(name string) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.usageFor(name).activeStreams
+}
+
+// AddKeyEvent counts an event against a key's daily quota, returning false
+// once eventsPerDay is exhausted (0 means unlimited)
+func (cm *Manager) AddKeyEvent(name string, eventsPerDay int) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	usage := cm.usageFor(name)
+	if eventsPerDay > 0 && usage.eventsToday >= eventsPerDay {
+		return false
+	}
+	usage.eventsToday++
+	return true
+}
+
+// KeyEventsRemaining returns how many daily events a key has left, or -1 when unlimited
+func (cm *Manager) KeyEventsRemaining(name string, eventsPerDay int) int {
+	if eventsPerDay <= 0 {
+		return -1
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return max(eventsPerDay-cm.usageFor(name).eventsToday, 0)
+}
+
+// QuotaReset returns when daily quotas next reset (UTC midnight)
+func QuotaReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// KeyUsageReport returns usage for every key that has been seen, with limits
+// filled in from the supplied lookup
+func (cm *Manager) KeyUsageReport(limits func(name string) (maxStreams, eventsPerDay int)) []KeyUsage {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	report := make([]KeyUsage, 0, len(cm.keyUsage))
+	for name := range cm.keyUsage {
+		usage := cm.usageFor(name)
+		maxStreams, eventsPerDay := limits(name)
+		report = append(report, KeyUsage{
+			Name:          name,
+			ActiveStreams: usage.activeStreams,
+			MaxStreams:    maxStreams,
+			EventsToday:   usage.eventsToday,
+			EventsPerDay:  eventsPerDay,
+			Day:           usage.day,
+		})
+	}
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
@@ -0,0 +1,91 @@
+package connectionmanager
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// DefaultRedactedHeaders are request headers whose values never reach connection logs
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Auth-Token"}
+
+// redactedParams are query parameters whose values never reach connection logs
+var redactedParams = []string{"api_key"}
+
+// redacted replaces each value of a redacted header
+const redacted = "[REDACTED]"
+
+// RedactHeaders adds header names, beyond the defaults, whose values are
+// replaced before connection logs are stored
+func (cm *Manager) RedactHeaders(names ...string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for _, name := range names {
+		cm.redact[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// Redacted copies headers with the values of sensitive ones replaced,
+// leaving the request's own headers untouched
+func (cm *Manager) Redacted(header http.Header) http.Header {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	clean := header.Clone()
+	for name, values := range clean {
+		if !cm.redact[name] {
+			// The page an EventSource was opened from may carry ?api_key= too
+			if name == "Referer" {
+				for i, value := range values {
+					values[i] = redactQuery(value)
+				}
+			}
+			continue
+		}
+		masked := make([]string, len(values))
+		for i := range masked {
+			masked[i] = redacted
+		}
+		clean[name] = masked
+	}
+	return clean
+}
+
+// requestURL reconstructs the URL a client requested, before any base path
+// was stripped, with credentials in the query masked
+func requestURL(r *http.Request) string {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		u = &url.URL{Path: r.URL.Path}
+	}
+
+	u.Scheme = "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	maskParams(u)
+	return u.String()
+}
+
+// redactQuery masks credentials in the query of a URL header value, leaving
+// values that do not parse as they are
+func redactQuery(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	maskParams(u)
+	return u.String()
+}
+
+// maskParams replaces the values of redacted query parameters
+func maskParams(u *url.URL) {
+	query := u.Query()
+	for _, name := range redactedParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+			u.RawQuery = query.Encode()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package connectionmanager
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// maxReplayGap caps a single pause in a replay, so a long-idle capture does
+// not stall the new client indefinitely
+const maxReplayGap = time.Minute
+
+// ReplayHandler streams the frames captured for the {connID} path value to a
+// new client as SSE, spacing them as originally sent divided by ?speed=
+// (default 1, 0 for no pauses). WebSocket messages are replayed as data lines
+func (cm *Manager) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	connID := r.PathValue("connID")
+	if !cm.CapturingFrames() {
+		apierror.Write(w, r, apierror.NotFound("frame capture is disabled"))
+		return
+	}
+
+	speed := 1.0
+	if raw := r.URL.Query().Get("speed"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid speed"))
+			return
+		}
+		speed = parsed
+	}
+
+	captured, ok := cm.Frames(connID)
+	if !ok {
+		apierror.Write(w, r, apierror.NotFound("connection "+connID+" not found"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "streaming_unsupported", "streaming unsupported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Replay-Of", connID)
+	flusher.Flush()
+
+	for i, frame := range captured.Frames {
+		if i > 0 && speed > 0 {
+			gap := time.Duration(float64(frame.Time.Sub(captured.Frames[i-1].Time)) / speed)
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(min(gap, maxReplayGap)):
+			}
+		}
+
+		data := frame.Data
+		if captured.Transport == TransportWebSocket {
+			data = fmt.Sprintf("data: %s\n\n", frame.Data)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
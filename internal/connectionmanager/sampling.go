@@ -0,0 +1,62 @@
+package connectionmanager
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// SamplingStats counts the connections left out of the logs by sampling
+type SamplingStats struct {
+	Rate            float64 `json:"rate"`             // Fraction of connections logged in full
+	Sampled         int64   `json:"sampled"`          // Connections logged in full
+	Unsampled       int64   `json:"unsampled"`        // Connections only counted
+	UnsampledActive int     `json:"unsampled_active"` // Unsampled connections still open
+	UnsampledEvents int64   `json:"unsampled_events"` // Events of unsampled connections not logged
+}
+
+// SetSampleRate logs only a random fraction of new connections, from 0 for
+// none to 1 for all; the others are only counted, keeping the logs and
+// their lock cheap when thousands of clients connect
+func (cm *Manager) SetSampleRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid sample rate %v, expected 0 to 1", rate)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.sampleRate = rate
+	return nil
+}
+
+// sample decides whether a new connection is logged in full, counting it;
+// callers hold the lock
+func (cm *Manager) sample(connID string) bool {
+	if cm.sampleRate >= 1 || rand.Float64() < cm.sampleRate {
+		cm.sampling.Sampled++
+		return true
+	}
+	cm.sampling.Unsampled++
+	cm.unsampled[connID] = true
+	return false
+}
+
+// Sampled reports whether a connection is logged in full, so callers can skip
+// building log lines for the others
+func (cm *Manager) Sampled(connID string) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return !cm.unsampled[connID]
+}
+
+// SamplingStats returns the sampling counters
+func (cm *Manager) SamplingStats() SamplingStats {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	stats := cm.sampling
+	stats.Rate = cm.sampleRate
+	stats.UnsampledActive = len(cm.unsampled)
+	return stats
+}
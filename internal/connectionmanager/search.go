@@ -0,0 +1,207 @@
+package connectionmanager
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// Query selects connection logs; zero fields match everything
+type Query struct {
+	Remote        string    // Client or remote IP, or a CIDR range
+	Header        string    // Header name, or name:value where the value is a case-insensitive substring
+	EventContains string    // Case-insensitive substring of any event
+	Agent         string    // Words all found in the parsed agent, ignoring case, e.g. "safari ios mobile"
+	From, To      time.Time // Connections open at some point in this range
+	Limit         int       // Most results returned, 0 for all
+}
+
+// index records a connection under its client and remote IPs; callers hold the lock
+func (cm *Manager) index(conn *ConnectionLog) {
+	for _, ip := range connectionIPs(conn) {
+		if cm.byIP[ip] == nil {
+			cm.byIP[ip] = make(map[string]bool)
+		}
+		cm.byIP[ip][conn.ID] = true
+	}
+}
+
+// remove deletes a connection and its index entries; callers hold the lock
+func (cm *Manager) remove(connID string) {
+	conn, exists := cm.connections[connID]
+	if !exists {
+		return
+	}
+
+	for _, ip := range connectionIPs(conn) {
+		delete(cm.byIP[ip], connID)
+		if len(cm.byIP[ip]) == 0 {
+			delete(cm.byIP, ip)
+		}
+	}
+	delete(cm.connections, connID)
+	delete(cm.frames, connID)
+}
+
+// connectionIPs returns the distinct IPs a connection can be found by
+func connectionIPs(conn *ConnectionLog) []string {
+	ips := []string{conn.ClientIP}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr)
+	if err == nil && host != conn.ClientIP {
+		ips = append(ips, host)
+	}
+	return ips
+}
+
+// Search returns copies of the connection logs matching q, newest first
+func (cm *Manager) Search(q Query) []ConnectionLog {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	results := []ConnectionLog{}
+	for _, id := range cm.candidates(q.Remote) {
+		conn := cm.connections[id]
+		if conn == nil || !q.matches(conn) {
+			continue
+		}
+
+		results = append(results, conn.snapshot())
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+// candidates narrows the search to connections from the queried IP or range
+// using the index, or returns every connection when no remote is given
+func (cm *Manager) candidates(remote string) []string {
+	var ids []string
+	if remote == "" {
+		for id := range cm.connections {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	prefix, err := netip.ParsePrefix(remote)
+	if err != nil {
+		for id := range cm.byIP[remote] {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	seen := make(map[string]bool)
+	for ip, conns := range cm.byIP {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !prefix.Contains(addr.Unmap()) {
+			continue
+		}
+		for id := range conns {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// matches applies the non-indexed criteria to a connection
+func (q Query) matches(conn *ConnectionLog) bool {
+	if !q.To.IsZero() && conn.Timestamp.After(q.To) {
+		return false
+	}
+	if !q.From.IsZero() && conn.ClosedAt != nil && conn.ClosedAt.Before(q.From) {
+		return false
+	}
+
+	if q.Header != "" {
+		name, value, _ := strings.Cut(q.Header, ":")
+		values, exists := conn.RequestHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))]
+		if !exists || !containsFold(values, strings.TrimSpace(value)) {
+			return false
+		}
+	}
+
+	if q.EventContains != "" && !containsFold(conn.Events, q.EventContains) {
+		return false
+	}
+	if q.Agent != "" && (conn.Agent == nil || !allWordsFold(conn.Agent.String(), q.Agent)) {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether any value contains substr, ignoring case
+func containsFold(values []string, substr string) bool {
+	substr = strings.ToLower(substr)
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allWordsFold reports whether s contains every word of words, ignoring case
+func allWordsFold(s, words string) bool {
+	for _, word := range strings.Fields(words) {
+		if !containsFold([]string{s}, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchHandler serves connection logs matching ?remote=, ?header=,
+// ?event_contains=, ?agent=, ?from=, ?to= (RFC 3339) and ?limit=
+func (cm *Manager) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := Query{
+		Remote:        query.Get("remote"),
+		Header:        query.Get("header"),
+		EventContains: query.Get("event_contains"),
+		Agent:         query.Get("agent"),
+		Limit:         100,
+	}
+
+	for name, field := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				apierror.Write(w, r, apierror.BadRequest("invalid "+name+", expected RFC 3339"))
+				return
+			}
+			*field = parsed
+		}
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid limit"))
+			return
+		}
+		q.Limit = parsed
+	}
+
+	results := cm.Search(q)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
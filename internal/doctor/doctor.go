@@ -0,0 +1,158 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.ngrok.com/ngrok"
+)
+
+// maxClockSkew is the largest tolerated difference from upstream server time
+const maxClockSkew = time.Minute
+
+// Check is a single environment validation
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// Run executes every check in order, each with its own timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		detail, err := check.Run(checkCtx)
+		cancel()
+
+		result := Result{Name: check.Name, OK: err == nil, Detail: detail}
+		if err != nil {
+			result.Detail = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Print writes a pass/fail line per result and reports whether all passed
+func Print(w io.Writer, results []Result) bool {
+	allOK := true
+	for _, result := range results {
+		status := "PASS"
+		if !result.OK {
+			status = "FAIL"
+			allOK = false
+		}
+		fmt.Fprintf(w, "[%s] %-12s %s\n", status, result.Name, result.Detail)
+	}
+	return allOK
+}
+
+// NgrokCheck verifies an auth token is present and accepted by ngrok
+func NgrokCheck() Check {
+	return Check{Name: "ngrok", Run: func(ctx context.Context) (string, error) {
+		if os.Getenv("NGROK_AUTHTOKEN") == "" {
+			return "", fmt.Errorf("NGROK_AUTHTOKEN is not set; add it to .env to use --tunnel")
+		}
+
+		session, err := ngrok.Connect(ctx, ngrok.WithAuthtokenFromEnv())
+		if err != nil {
+			return "", fmt.Errorf("ngrok rejected the auth token or is unreachable: %v", err)
+		}
+		session.Close()
+		return "auth token accepted", nil
+	}}
+}
+
+// RedditCheck verifies the listing endpoint is reachable and not rate limited
+func RedditCheck(client *http.Client, url string) Check {
+	return Check{Name: "reddit", Run: func(ctx context.Context) (string, error) {
+		resp, err := get(ctx, client, url)
+		if err != nil {
+			return "", fmt.Errorf("cannot reach %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+
+		remaining := resp.Header.Get("X-Ratelimit-Remaining")
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return "", fmt.Errorf("rate limited (reset in %ss); wait before starting", resp.Header.Get("X-Ratelimit-Reset"))
+		case resp.StatusCode == http.StatusForbidden:
+			return "", fmt.Errorf("blocked with 403; try another network or a --mock-upstream demo")
+		case resp.StatusCode != http.StatusOK:
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		case remaining != "":
+			return fmt.Sprintf("reachable, %s requests remaining in window", remaining), nil
+		default:
+			return "reachable", nil
+		}
+	}}
+}
+
+// PortCheck verifies the local port can be bound
+func PortCheck(addr string) Check {
+	return Check{Name: "port", Run: func(ctx context.Context) (string, error) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return "", fmt.Errorf("%s is not free: %v; pick another with --port", addr, err)
+		}
+		ln.Close()
+		return addr + " is free", nil
+	}}
+}
+
+// TemplateCheck verifies the client page templates parse
+func TemplateCheck(fsys fs.FS, patterns ...string) Check {
+	return Check{Name: "templates", Run: func(ctx context.Context) (string, error) {
+		if _, err := template.ParseFS(fsys, patterns...); err != nil {
+			return "", fmt.Errorf("templates do not parse: %v", err)
+		}
+		return strings.Join(patterns, ", ") + " parse", nil
+	}}
+}
+
+// ClockCheck compares the local clock with an upstream server's Date header
+func ClockCheck(client *http.Client, url string) Check {
+	return Check{Name: "clock", Run: func(ctx context.Context) (string, error) {
+		resp, err := get(ctx, client, url)
+		if err != nil {
+			return "", fmt.Errorf("cannot reach %s to compare clocks: %v", url, err)
+		}
+		resp.Body.Close()
+
+		remote, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return "", fmt.Errorf("no usable Date header from %s", url)
+		}
+
+		skew := time.Since(remote).Round(time.Second)
+		if skew > maxClockSkew || skew < -maxClockSkew {
+			return "", fmt.Errorf("local clock is off by %s; sync it (e.g. with NTP)", skew)
+		}
+		return fmt.Sprintf("skew %s", skew), nil
+	}}
+}
+
+// get issues a GET request with the service's User-Agent
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+	return client.Do(req)
+}
@@ -0,0 +1,137 @@
+// Package imagemeta removes metadata such as EXIF (camera, location and
+// timestamps) from images without re-encoding them
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+	// pngMetadata are the PNG chunks carrying text, EXIF and timestamps
+	pngMetadata = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+)
+
+// Supported reports whether Strip understands images of a content type
+func Supported(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png" || contentType == "image/webp"
+}
+
+// Strip returns a JPEG, PNG or WebP image without its metadata blocks, keeping
+// color profiles; other data is returned unchanged
+func Strip(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+// stripJPEG drops the APP segments other than JFIF (APP0), ICC profiles
+// (APP2) and Adobe color transforms (APP14), and comments, up to the image data
+func stripJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at byte %d", pos)
+		}
+		// Markers may be preceded by fill bytes
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+1 >= len(data) {
+			return nil, fmt.Errorf("truncated JPEG")
+		}
+
+		marker := data[pos+1]
+		switch {
+		case marker == 0xDA || marker == 0xD9:
+			// Start of scan: the rest is image data
+			return append(out, data[pos:]...), nil
+		case marker >= 0xD0 && marker <= 0xD7 || marker == 0x01:
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated JPEG")
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment")
+		}
+		isMetadata := marker >= 0xE1 && marker <= 0xEF && marker != 0xE2 && marker != 0xEE
+		if !isMetadata && marker != 0xFE {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// stripPNG drops text, EXIF and timestamp chunks
+func stripPNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated PNG")
+		}
+		// Length, type, data and CRC
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) || end < pos {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		if !pngMetadata[string(data[pos+4:pos+8])] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears their flags in the
+// extended header
+func stripWebP(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated WebP")
+		}
+		// Chunks are padded to an even size
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > len(data) || end < pos {
+			return nil, fmt.Errorf("truncated WebP chunk")
+		}
+
+		switch fourCC := string(data[pos : pos+4]); fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
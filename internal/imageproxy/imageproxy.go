@@ -0,0 +1,278 @@
+package imageproxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	cache "github.com/elt0nxale/meme-tunnel-streamer/internal/cache"
+	imagemeta "github.com/elt0nxale/meme-tunnel-streamer/internal/imagemeta"
+)
+
+const (
+	// maxImageBytes caps how much of an upstream image is relayed
+	maxImageBytes = 20 << 20
+
+	// maxCachedBytes caps the images kept in the cache, so a few large GIFs
+	// cannot crowd out everything else
+	maxCachedBytes = 2 << 20
+
+	// cacheTTL is how long a relayed image is served from the cache
+	cacheTTL = time.Hour
+)
+
+// relayedTypes are the content types the proxy relays
+var relayedTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"video/mp4":  true,
+	"video/webm": true,
+}
+
+// Proxy serves upstream images through this host, accepting only URLs it signed
+type Proxy struct {
+	secret    []byte
+	ttl       time.Duration
+	client    *http.Client
+	watermark *Watermark  // Overlaid on PNG and JPEG images, nil for none
+	strip     bool        // Remove EXIF and other metadata from relayed images
+	cache     cache.Cache // Relayed images by upstream URL, nil for none
+}
+
+// New creates a proxy signing URLs valid for ttl; an empty secret is replaced
+// by a random one, invalidating outstanding URLs on restart
+func New(secret []byte, ttl time.Duration, client *http.Client) (*Proxy, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate proxy secret: %v", err)
+		}
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Proxy{secret: secret, ttl: ttl, client: client}, nil
+}
+
+// SetStripMetadata removes EXIF, XMP and text metadata from the JPEG, PNG
+// and WebP images relayed, such as camera details and locations
+func (p *Proxy) SetStripMetadata(strip bool) {
+	p.strip = strip
+}
+
+// SetCache keeps relayed images of up to 2MB in c, after watermarking and
+// metadata stripping, so popular memes are fetched upstream once per hour
+// rather than once per viewer
+func (p *Proxy) SetCache(c cache.Cache) {
+	p.cache = c
+}
+
+// cacheKey names an upstream image as relayed with the current settings
+func (p *Proxy) cacheKey(upstream string) string {
+	return fmt.Sprintf("image:%t:%t:%s", p.watermark != nil, p.strip, upstream)
+}
+
+// cached returns a relayed image and its content type from the cache
+func (p *Proxy) cached(r *http.Request, upstream string) ([]byte, string, bool) {
+	if p.cache == nil {
+		return nil, "", false
+	}
+	value, found, err := p.cache.Get(r.Context(), p.cacheKey(upstream))
+	if err != nil {
+		log.Printf("Image cache lookup failed: %v", err)
+		return nil, "", false
+	}
+	contentType, data, ok := bytes.Cut(value, []byte("\n"))
+	if !found || !ok {
+		return nil, "", false
+	}
+	return data, string(contentType), true
+}
+
+// respond writes a whole relayed image, caching it when it is small enough
+func (p *Proxy) respond(w http.ResponseWriter, r *http.Request, upstream, contentType string, data []byte) {
+	if p.cache != nil && len(data) <= maxCachedBytes {
+		value := append([]byte(contentType+"\n"), data...)
+		if err := p.cache.Set(r.Context(), p.cacheKey(upstream), value, cacheTTL); err != nil {
+			log.Printf("Image cache store failed: %v", err)
+		}
+	}
+
+	p.setHeaders(w, contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// setHeaders sets the headers of a relayed image
+func (p *Proxy) setHeaders(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(p.ttl.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Opened directly, a relayed file must not run anything as this origin
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+}
+
+// Sign returns the query string of a proxy URL for an upstream image, e.g.
+// "u=...&e=...&s=..."; callers prefix it with the route path
+func (p *Proxy) Sign(upstream string) string {
+	expires := strconv.FormatInt(time.Now().Add(p.ttl).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("u", upstream)
+	query.Set("e", expires)
+	query.Set("s", p.signature(upstream, expires))
+	return query.Encode()
+}
+
+// verify checks a request's signature and expiry, returning the upstream URL
+func (p *Proxy) verify(query url.Values) (string, error) {
+	upstream, expires, signature := query.Get("u"), query.Get("e"), query.Get("s")
+	if upstream == "" || expires == "" || signature == "" {
+		return "", fmt.Errorf("missing signature parameters")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(p.signature(upstream, expires))) {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return "", fmt.Errorf("url expired")
+	}
+	return upstream, nil
+}
+
+// signature is the base64 HMAC-SHA256 over the upstream URL and expiry
+func (p *Proxy) signature(upstream, expires string) string {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(upstream + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ServeHTTP relays a signed upstream image
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.verify(r.URL.Query())
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, "invalid_signature", err.Error()))
+		return
+	}
+
+	target, err := url.Parse(upstream)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		apierror.Write(w, r, apierror.BadRequest("unsupported upstream url"))
+		return
+	}
+
+	if data, contentType, ok := p.cached(r, upstream); ok {
+		p.setHeaders(w, contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "GET", target.String(), nil)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", fmt.Errorf("failed to fetch image: %v", err)))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apierror.Write(w, r, apierror.New(http.StatusBadGateway, "upstream_error", fmt.Sprintf("upstream returned %s", resp.Status)))
+		return
+	}
+
+	// Only relay formats browsers render as plain media, never pages or SVG,
+	// which can carry script
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !relayedTypes[contentType] {
+		apierror.Write(w, r, apierror.New(http.StatusBadGateway, "not_an_image", "upstream is not an image"))
+		return
+	}
+	if resp.ContentLength > maxImageBytes {
+		apierror.Write(w, r, apierror.New(http.StatusBadGateway, "image_too_large", "image too large"))
+		return
+	}
+
+	// Watermarked images are decoded and re-encoded whole, which drops their
+	// metadata too; GIFs and videos are relayed untouched
+	if p.watermark != nil && (contentType == "image/png" || contentType == "image/jpeg") {
+		p.serveWatermarked(w, r, upstream, resp.Body, contentType)
+		return
+	}
+	if p.strip && imagemeta.Supported(contentType) {
+		p.serveStripped(w, r, upstream, resp.Body, contentType)
+		return
+	}
+	if p.cache != nil && resp.ContentLength >= 0 && resp.ContentLength <= maxCachedBytes {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBytes))
+		if err != nil {
+			apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", fmt.Errorf("failed to read image: %v", err)))
+			return
+		}
+		p.respond(w, r, upstream, contentType, data)
+		return
+	}
+
+	p.setHeaders(w, contentType)
+	if resp.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+
+	if _, err := io.Copy(w, io.LimitReader(resp.Body, maxImageBytes)); err != nil {
+		log.Printf("Image proxy copy failed for %s: %v", upstream, err)
+	}
+}
+
+// serveWatermarked relays an upstream image with the watermark drawn over it
+func (p *Proxy) serveWatermarked(w http.ResponseWriter, r *http.Request, upstream string, body io.Reader, contentType string) {
+	data, err := io.ReadAll(io.LimitReader(body, maxImageBytes))
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", fmt.Errorf("failed to read image: %v", err)))
+		return
+	}
+
+	marked, err := p.watermark.apply(data, contentType)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "not_an_image", fmt.Errorf("failed to watermark image: %v", err)))
+		return
+	}
+	p.respond(w, r, upstream, contentType, marked)
+}
+
+// serveStripped relays an upstream image without its metadata
+func (p *Proxy) serveStripped(w http.ResponseWriter, r *http.Request, upstream string, body io.Reader, contentType string) {
+	data, err := io.ReadAll(io.LimitReader(body, maxImageBytes))
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", fmt.Errorf("failed to read image: %v", err)))
+		return
+	}
+
+	stripped, err := imagemeta.Strip(data)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "not_an_image", fmt.Errorf("failed to strip image metadata: %v", err)))
+		return
+	}
+	p.respond(w, r, upstream, contentType, stripped)
+}
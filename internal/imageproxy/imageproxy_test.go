@@ -0,0 +1,70 @@
+package imageproxy
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestVerify checks only unexpired URLs signed with the proxy's secret are relayed
+func TestVerify(t *testing.T) {
+	p, err := New([]byte("proxy secret"), time.Hour, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	other, err := New([]byte("another secret"), time.Hour, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const upstream = "https://i.redd.it/cat.png"
+	signed := func(p *Proxy, upstream string, expires time.Time) url.Values {
+		e := strconv.FormatInt(expires.Unix(), 10)
+		return url.Values{"u": {upstream}, "e": {e}, "s": {p.signature(upstream, e)}}
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  bool
+	}{
+		{name: "valid", query: signed(p, upstream, later), want: true},
+		{name: "expired", query: signed(p, upstream, time.Now().Add(-time.Second))},
+		{name: "other secret", query: signed(other, upstream, later)},
+		{name: "url changed", query: func() url.Values {
+			q := signed(p, upstream, later)
+			q.Set("u", "http://169.254.169.254/latest/meta-data/")
+			return q
+		}()},
+		{name: "expiry extended", query: func() url.Values {
+			q := signed(p, upstream, later)
+			q.Set("e", strconv.FormatInt(later.Add(24*time.Hour).Unix(), 10))
+			return q
+		}()},
+		{name: "signature missing", query: url.Values{"u": {upstream}, "e": {"9999999999"}}},
+		{name: "expiry not a number", query: url.Values{"u": {upstream}, "e": {"soon"}, "s": {p.signature(upstream, "soon")}}},
+		{name: "empty", query: url.Values{}},
+	}
+
+	for _, tt := range tests {
+		got, err := p.verify(tt.query)
+		if (err == nil) != tt.want {
+			t.Errorf("%s: verify error = %v, want ok %v", tt.name, err, tt.want)
+			continue
+		}
+		if tt.want && got != upstream {
+			t.Errorf("%s: verify = %q, want %q", tt.name, got, upstream)
+		}
+	}
+
+	// Sign produces URLs verify accepts
+	query, err := url.ParseQuery(p.Sign(upstream))
+	if err != nil {
+		t.Fatalf("Sign produced an invalid query: %v", err)
+	}
+	if got, err := p.verify(query); err != nil || got != upstream {
+		t.Errorf("verify(Sign(%q)) = %q, %v", upstream, got, err)
+	}
+}
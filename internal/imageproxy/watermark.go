@@ -0,0 +1,124 @@
+package imageproxy
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"slices"
+
+	xdraw "golang.org/x/image/draw"
+
+	caption "github.com/elt0nxale/meme-tunnel-streamer/internal/caption"
+)
+
+// maxWatermarkShare is the largest fraction of an image's width a watermark covers
+const maxWatermarkShare = 5
+
+// Corners are the positions a watermark can take
+var Corners = []string{"top-left", "top-right", "bottom-left", "bottom-right"}
+
+// Watermark is overlaid on the PNG and JPEG images the proxy relays, so
+// screenshots of the stream carry attribution
+type Watermark struct {
+	Text    string      // Drawn in outlined white, scaled to the image
+	Image   image.Image // Drawn instead of Text when set, e.g. a logo
+	Corner  string      // One of Corners
+	Opacity float64     // 0 (invisible) to 1 (opaque)
+}
+
+// LoadWatermarkImage reads a PNG or JPEG logo for Watermark.Image
+func LoadWatermarkImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark image: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode watermark image %s: %v", path, err)
+	}
+	return img, nil
+}
+
+// SetWatermark overlays wm on relayed PNG and JPEG images; nil turns it off
+func (p *Proxy) SetWatermark(wm *Watermark) error {
+	if wm != nil {
+		if wm.Text == "" && wm.Image == nil {
+			return fmt.Errorf("watermark needs text or an image")
+		}
+		if !slices.Contains(Corners, wm.Corner) {
+			return fmt.Errorf("invalid watermark corner %q, expected top-left, top-right, bottom-left or bottom-right", wm.Corner)
+		}
+		if wm.Opacity <= 0 || wm.Opacity > 1 {
+			return fmt.Errorf("invalid watermark opacity %v, expected more than 0 and at most 1", wm.Opacity)
+		}
+	}
+	p.watermark = wm
+	return nil
+}
+
+// apply decodes an image, draws the watermark over it and re-encodes it in
+// its original format
+func (wm *Watermark) apply(data []byte, contentType string) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > caption.MaxPixels {
+		return nil, fmt.Errorf("image is %dx%d, too large to watermark", config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	mark, err := wm.render(dst.Bounds().Dx())
+	if err != nil {
+		return nil, err
+	}
+	margin := max(4, dst.Bounds().Dx()/50)
+	at := image.Pt(margin, margin)
+	if wm.Corner == "top-right" || wm.Corner == "bottom-right" {
+		at.X = dst.Bounds().Dx() - mark.Bounds().Dx() - margin
+	}
+	if wm.Corner == "bottom-left" || wm.Corner == "bottom-right" {
+		at.Y = dst.Bounds().Dy() - mark.Bounds().Dy() - margin
+	}
+	opacity := image.NewUniform(color.Alpha16{A: uint16(wm.Opacity * 0xffff)})
+	draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(mark.Bounds().Size())}, mark, mark.Bounds().Min, opacity, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// render draws the watermark for an image width pixels wide: text at a
+// thirtieth of it, or the image scaled down to a fifth of it at most
+func (wm *Watermark) render(width int) (image.Image, error) {
+	if wm.Image == nil {
+		return caption.Label(wm.Text, float64(width)/30)
+	}
+
+	bounds := wm.Image.Bounds()
+	if bounds.Dx() <= width/maxWatermarkShare || bounds.Dx() == 0 {
+		return wm.Image, nil
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width/maxWatermarkShare, max(1, bounds.Dy()*width/maxWatermarkShare/bounds.Dx())))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), wm.Image, bounds, draw.Src, nil)
+	return scaled, nil
+}
@@ -0,0 +1,203 @@
+// Package journal keeps a bounded on-disk log of broadcast events so clients
+// can replay what was on the wire after the fact.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a single journaled event
+type Entry struct {
+	Seq  uint64          `json:"seq"`
+	Time time.Time       `json:"time"`
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Journal appends events to a JSON-lines file, keeping the newest max in memory
+// and compacting the file once it holds twice that many
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	max     int
+	entries []Entry // Oldest first
+	lines   int     // Entries currently in the file
+	seq     uint64  // Highest sequence number seen
+}
+
+// Open loads an existing journal or creates a new one at path
+func Open(path string, max int) (*Journal, error) {
+	if max <= 0 {
+		return nil, fmt.Errorf("journal size must be positive")
+	}
+
+	j := &Journal{path: path, max: max}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	j.file = file
+	return j, nil
+}
+
+// load reads the entries and last sequence number of an existing file
+func (j *Journal) load() error {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn final write is expected after a crash; skip it
+			continue
+		}
+		j.lines++
+		j.seq = max(j.seq, entry.Seq)
+		j.entries = append(j.entries, entry)
+		if len(j.entries) > j.max {
+			j.entries = j.entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+	return nil
+}
+
+// Append records an event under the sequence number assigned by the broadcaster
+func (j *Journal) Append(seq uint64, name string, data []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq = max(j.seq, seq)
+	entry := Entry{Seq: seq, Time: time.Now().UTC(), Name: name, Data: rawJSON(data)}
+
+	j.entries = append(j.entries, entry)
+	if len(j.entries) > j.max {
+		j.entries = j.entries[len(j.entries)-j.max:]
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %v", err)
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	j.lines++
+
+	if j.lines >= 2*j.max {
+		return j.compact()
+	}
+	return nil
+}
+
+// rawJSON keeps JSON payloads as they are and quotes anything else
+func rawJSON(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return append(json.RawMessage(nil), data...)
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+// compact rewrites the file with only the retained entries
+func (j *Journal) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range j.entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact journal: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen journal: %v", err)
+	}
+	j.file.Close()
+	j.file = file
+	j.lines = len(j.entries)
+	return nil
+}
+
+// Since returns up to limit entries with a sequence number above seq, oldest first
+func (j *Journal) Since(seq uint64, limit int) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range j.entries {
+		if entry.Seq <= seq {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+	}
+	return entries
+}
+
+// Bounds returns the oldest retained and the newest sequence numbers
+func (j *Journal) Bounds() (oldest, last uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) > 0 {
+		oldest = j.entries[0].Seq
+	}
+	return oldest, j.seq
+}
+
+// LastSeq returns the highest sequence number journaled, so numbering survives restarts
+func (j *Journal) LastSeq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.seq
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
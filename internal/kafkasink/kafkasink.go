@@ -0,0 +1,208 @@
+// Package kafkasink mirrors broadcast events to a Kafka topic, so data
+// pipelines can consume the meme stream durably instead of holding an SSE
+// connection open
+package kafkasink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// queueSize bounds the events waiting for the producer; more are dropped
+	// rather than stalling the broadcaster while Kafka is slow or down
+	queueSize = 1024
+
+	// batchSize caps the events written in one produce request
+	batchSize = 100
+
+	// writeTimeout bounds one produce request, retries included
+	writeTimeout = 30 * time.Second
+)
+
+// Stats counts the events mirrored to Kafka
+type Stats struct {
+	Topic   string `json:"topic"`
+	Sent    int64  `json:"sent"`
+	Failed  int64  `json:"failed"`  // Rejected by the brokers after retries
+	Dropped int64  `json:"dropped"` // Discarded because the queue was full
+}
+
+// Sink produces every event it is given to a topic, keyed by meme ID so a
+// meme's events land in the same partition
+type Sink struct {
+	writer  *kafka.Writer
+	topic   string
+	mu      sync.RWMutex // Guards closing the queue against Publish
+	queue   chan kafka.Message
+	closed  bool
+	done    chan struct{}
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+// ParseBrokers splits a comma-separated list of host:port broker addresses
+func ParseBrokers(raw string) ([]string, error) {
+	var brokers []string
+	for _, broker := range strings.Split(raw, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			continue
+		}
+		if !strings.Contains(broker, ":") {
+			return nil, fmt.Errorf("invalid broker %q, expected host:port", broker)
+		}
+		brokers = append(brokers, broker)
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("no brokers given")
+	}
+	return brokers, nil
+}
+
+// New starts a producer writing to topic on brokers; writes wait for every
+// in-sync replica so acknowledged events survive a broker failure
+func New(brokers []string, topic string) (*Sink, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers given")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("no Kafka topic given")
+	}
+
+	s := &Sink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchSize:    batchSize,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		topic: topic,
+		queue: make(chan kafka.Message, queueSize),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Publish queues an event for the topic without blocking
+func (s *Sink) Publish(event broadcaster.Event) {
+	msg := kafka.Message{
+		Key:   memeID(event.Data),
+		Value: event.Data,
+		Time:  time.Now(),
+		Headers: []kafka.Header{
+			{Key: "event", Value: []byte(eventName(event.Name))},
+			{Key: "seq", Value: []byte(strconv.FormatUint(event.Seq, 10))},
+		},
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("Kafka queue full, dropping events for topic %s", s.topic)
+		}
+	}
+}
+
+// eventName is the SSE event name a client would see
+func eventName(name string) string {
+	if name == "" {
+		return "message"
+	}
+	return name
+}
+
+// memeID returns the "id" of an event's JSON object payload, nil for
+// payloads not about a single meme, which Kafka spreads across partitions
+func memeID(data []byte) []byte {
+	var payload struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.ID == "" {
+		return nil
+	}
+	return []byte(payload.ID)
+}
+
+// run writes queued events in batches until the queue is closed
+func (s *Sink) run() {
+	defer close(s.done)
+
+	batch := make([]kafka.Message, 0, batchSize)
+	for msg := range s.queue {
+		batch = append(batch[:0], msg)
+	fill:
+		for len(batch) < batchSize {
+			select {
+			case next, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		s.write(batch)
+	}
+}
+
+// write produces a batch, counting the outcome
+func (s *Sink) write(batch []kafka.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if err := s.writer.WriteMessages(ctx, batch...); err != nil {
+		s.failed.Add(int64(len(batch)))
+		log.Printf("Failed to write %d events to Kafka topic %s: %v", len(batch), s.topic, err)
+		return
+	}
+	s.sent.Add(int64(len(batch)))
+}
+
+// Stats returns the sink's counters
+func (s *Sink) Stats() Stats {
+	return Stats{
+		Topic:   s.topic,
+		Sent:    s.sent.Load(),
+		Failed:  s.failed.Load(),
+		Dropped: s.dropped.Load(),
+	}
+}
+
+// Close writes the events still queued and stops the producer; events
+// published afterwards are lost
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return s.writer.Close()
+}
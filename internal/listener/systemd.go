@@ -0,0 +1,62 @@
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd
+const listenFdsStart = 3
+
+// Socket is a listener passed in by the service manager along with its name
+type Socket struct {
+	net.Listener
+	Name string // FileDescriptorName from the socket unit
+}
+
+// Activated returns the sockets handed over by systemd socket activation
+// (LISTEN_PID/LISTEN_FDS), or nil when the process was started normally
+func Activated() ([]Socket, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// Prevent child processes from believing they were activated too
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	sockets := make([]Socket, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// FileListener duplicates the descriptor, so the original can be closed
+		file := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range sockets {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to use activated socket %s: %v", name, err)
+		}
+
+		sockets = append(sockets, Socket{Listener: ln, Name: name})
+	}
+
+	return sockets, nil
+}
@@ -0,0 +1,196 @@
+package loadtest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a load test run
+type Options struct {
+	URL      string        // SSE endpoint to connect to
+	Clients  int           // Number of concurrent clients
+	Duration time.Duration // How long each client stays connected
+	RampUp   time.Duration // Time over which clients are started
+}
+
+// Report summarises a load test run
+type Report struct {
+	Clients       int           `json:"clients"`
+	Connected     int           `json:"connected"`
+	Failed        int           `json:"failed"`
+	Events        int           `json:"events"`
+	EventsPerSec  float64       `json:"events_per_sec"`
+	GapP50        time.Duration `json:"gap_p50"`
+	GapP99        time.Duration `json:"gap_p99"`
+	GapMax        time.Duration `json:"gap_max"`
+	ConnectErrors []string      `json:"connect_errors,omitempty"`
+	Elapsed       time.Duration `json:"elapsed"`
+}
+
+// clientResult is what a single simulated client observed
+type clientResult struct {
+	err    error
+	events int
+	gaps   []time.Duration
+}
+
+// Run spawns the configured SSE clients and aggregates their results
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Clients <= 0 {
+		return Report{}, fmt.Errorf("clients must be positive")
+	}
+	if opts.Duration <= 0 {
+		return Report{}, fmt.Errorf("duration must be positive")
+	}
+
+	client := &http.Client{}
+	results := make([]clientResult, opts.Clients)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Clients; i++ {
+		// Spread client starts evenly across the ramp-up period
+		delay := time.Duration(0)
+		if opts.RampUp > 0 {
+			delay = opts.RampUp * time.Duration(i) / time.Duration(opts.Clients)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			case <-time.After(delay):
+			}
+
+			clientCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+			defer cancel()
+			results[i] = runClient(clientCtx, client, opts.URL)
+		}(i)
+	}
+	wg.Wait()
+
+	return summarise(opts, results, time.Since(start)), nil
+}
+
+// runClient connects to the stream and records the gap between events
+func runClient(ctx context.Context, client *http.Client, url string) clientResult {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return clientResult{err: err}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return clientResult{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return clientResult{err: fmt.Errorf("unexpected status %s", resp.Status)}
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return clientResult{err: fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))}
+	}
+
+	var result clientResult
+	var last time.Time
+	hasData := false
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// The deadline ending the run is expected
+			if err != io.EOF && ctx.Err() == nil {
+				result.err = err
+			}
+			return result
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			hasData = true
+		case line == "" && hasData:
+			// A blank line dispatches the event
+			now := time.Now()
+			if !last.IsZero() {
+				result.gaps = append(result.gaps, now.Sub(last))
+			}
+			last = now
+			result.events++
+			hasData = false
+		}
+	}
+}
+
+// summarise aggregates client results into a report
+func summarise(opts Options, results []clientResult, elapsed time.Duration) Report {
+	report := Report{Clients: opts.Clients, Elapsed: elapsed}
+	errorCounts := make(map[string]int)
+
+	var gaps []time.Duration
+	for _, result := range results {
+		if result.err != nil && result.events == 0 {
+			report.Failed++
+			errorCounts[result.err.Error()]++
+			continue
+		}
+		report.Connected++
+		report.Events += result.events
+		gaps = append(gaps, result.gaps...)
+	}
+
+	for msg, count := range errorCounts {
+		report.ConnectErrors = append(report.ConnectErrors, fmt.Sprintf("%dx %s", count, msg))
+	}
+	sort.Strings(report.ConnectErrors)
+
+	if elapsed > 0 {
+		report.EventsPerSec = float64(report.Events) / elapsed.Seconds()
+	}
+
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		report.GapP50 = percentile(gaps, 0.50)
+		report.GapP99 = percentile(gaps, 0.99)
+		report.GapMax = gaps[len(gaps)-1]
+	}
+
+	return report
+}
+
+// percentile returns the value at quantile q of sorted durations
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	index := int(float64(len(sorted)-1) * q)
+	return sorted[index]
+}
+
+// String renders the report for the terminal
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Clients:           %d\n", r.Clients)
+	fmt.Fprintf(&b, "Connected:         %d (%.1f%%)\n", r.Connected, 100*float64(r.Connected)/float64(r.Clients))
+	fmt.Fprintf(&b, "Failed:            %d\n", r.Failed)
+	fmt.Fprintf(&b, "Events:            %d (%.2f/s)\n", r.Events, r.EventsPerSec)
+	fmt.Fprintf(&b, "Inter-event p50:   %s\n", r.GapP50.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Inter-event p99:   %s\n", r.GapP99.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Inter-event max:   %s\n", r.GapMax.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Elapsed:           %s\n", r.Elapsed.Round(time.Millisecond))
+	for _, msg := range r.ConnectErrors {
+		fmt.Fprintf(&b, "Error:             %s\n", msg)
+	}
+	return b.String()
+}
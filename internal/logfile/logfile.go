@@ -0,0 +1,204 @@
+// Package logfile writes logs to a file that is rotated by size and age, keeping
+// a bounded number of optionally gzipped old files.
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures rotation
+type Options struct {
+	MaxSize  int64         // Rotate before a write would grow the file past this many bytes, 0 for no limit
+	MaxAge   time.Duration // Rotate once the file has been written to for this long, 0 for no limit
+	Keep     int           // Rotated files kept, oldest deleted first; 0 keeps all
+	Compress bool          // Gzip rotated files
+}
+
+// rotatedFormat timestamps rotated files, sorting in rotation order
+const rotatedFormat = "20060102T150405.000"
+
+// Writer is a rotating log file, safe for concurrent use
+type Writer struct {
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	compressing sync.WaitGroup
+}
+
+// Open opens or creates the log file at path, appending to it
+func Open(path string, opts Options) (*Writer, error) {
+	w := &Writer{path: path, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the current file, counting what it already holds
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.opened = time.Now()
+	return nil
+}
+
+// Write appends p, rotating first if it would exceed the size or age limit
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.due(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n more bytes;
+// an empty file is never rotated, so oversized writes still land somewhere
+func (w *Writer) due(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && time.Since(w.opened) >= w.opts.MaxAge
+}
+
+// rotate renames the current file aside and starts a new one
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %v", err)
+	}
+
+	rotated := w.path + "." + time.Now().Format(rotatedFormat)
+	if err := os.Rename(w.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %v", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	// Compress and prune off the write path
+	w.compressing.Add(1)
+	go func() {
+		defer w.compressing.Done()
+
+		if w.opts.Compress {
+			if err := compress(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "logfile: %v\n", err)
+			}
+		}
+		w.prune()
+	}()
+	return nil
+}
+
+// compress gzips a rotated file in place of the original
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s.gz: %v", path, err)
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write %s.gz: %v", path, err)
+	}
+
+	return os.Remove(path)
+}
+
+// prune deletes the oldest rotated files beyond the keep limit
+func (w *Writer) prune() {
+	if w.opts.Keep <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+
+	// Skip files still being compressed, whose .gz is counted instead
+	var rotated []string
+	for _, match := range matches {
+		if w.opts.Compress && !strings.HasSuffix(match, ".gz") {
+			if _, err := os.Stat(match + ".gz"); err == nil {
+				continue
+			}
+		}
+		rotated = append(rotated, match)
+	}
+
+	sort.Strings(rotated)
+	for len(rotated) > w.opts.Keep {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+}
+
+// Close closes the file once pending compression has finished
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.compressing.Wait()
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,151 @@
+package memeservice
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	blocklist "github.com/elt0nxale/meme-tunnel-streamer/internal/blocklist"
+)
+
+const (
+	// maxScreenBytes bounds the images downloaded to check against the blocklist
+	maxScreenBytes = 20 << 20
+
+	// fingerprintCacheTTL is how long an image's fingerprint is remembered,
+	// so each refresh only downloads new images
+	fingerprintCacheTTL = 24 * time.Hour
+)
+
+// SetBlocklist drops memes whose images match bl from the pool, from the next
+// refresh on; nil disables it
+func (ms *Service) SetBlocklist(bl *blocklist.List) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.blocklist = bl
+	ms.lastFetch = time.Time{}
+}
+
+// screen drops memes whose images match the blocklist, downloading each
+// image to fingerprint it. Videos and images that cannot be fetched pass
+func (ms *Service) screen(ctx context.Context, client *http.Client, bl *blocklist.List, memes []Meme) []Meme {
+	blocked := make([]bool, len(memes))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentProbes)
+	for i := range memes {
+		meme := memes[i]
+		if meme.MediaType == MediaVideo || meme.Size > maxScreenBytes ||
+			!(strings.HasPrefix(meme.URL, "http://") || strings.HasPrefix(meme.URL, "https://")) {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fp, err := ms.Fingerprint(ctx, client, meme.URL)
+			if err != nil {
+				return
+			}
+			_, blocked[i] = bl.Check(fp, fmt.Sprintf("meme %s (%s)", meme.ID, meme.URL))
+		}()
+	}
+	wg.Wait()
+
+	kept := memes[:0]
+	for i, meme := range memes {
+		if !blocked[i] {
+			kept = append(kept, meme)
+		}
+	}
+	return kept
+}
+
+// Fingerprint downloads an image and fingerprints it for the blocklist,
+// caching the result by URL; a nil client uses the service's media client
+func (ms *Service) Fingerprint(ctx context.Context, client *http.Client, url string) (blocklist.Fingerprint, error) {
+	key := "fingerprint:" + url
+	if cached, found, _ := ms.cache.Get(ctx, key); found {
+		if fp, err := parseFingerprint(string(cached)); err == nil {
+			return fp, nil
+		}
+	}
+
+	if client == nil {
+		ms.mu.RLock()
+		client = ms.mediaClient()
+		ms.mu.RUnlock()
+	}
+	data, err := download(ctx, client, url, maxScreenBytes)
+	if err != nil {
+		return blocklist.Fingerprint{}, err
+	}
+	fp := blocklist.Compute(data)
+	ms.cache.Set(ctx, key, []byte(formatFingerprint(fp)), fingerprintCacheTTL)
+	return fp, nil
+}
+
+// download reads the body of a URL, failing for bodies over limit bytes
+func download(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %s", url, resp.Status)
+	}
+	// Read one byte past the limit to detect oversized bodies without a length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", url, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
+
+// formatFingerprint encodes a fingerprint for the cache as "<sha256> <dhash>",
+// the dHash empty for data that is not an image
+func formatFingerprint(fp blocklist.Fingerprint) string {
+	dhash := ""
+	if fp.HasDHash {
+		dhash = strconv.FormatUint(fp.DHash, 16)
+	}
+	return hex.EncodeToString(fp.SHA256[:]) + " " + dhash
+}
+
+// parseFingerprint decodes a cached fingerprint
+func parseFingerprint(s string) (blocklist.Fingerprint, error) {
+	digest, dhash, _ := strings.Cut(s, " ")
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != 32 {
+		return blocklist.Fingerprint{}, fmt.Errorf("invalid cached fingerprint %q", s)
+	}
+	fp := blocklist.Fingerprint{SHA256: [32]byte(sum)}
+	if dhash != "" {
+		if fp.DHash, err = strconv.ParseUint(dhash, 16, 64); err != nil {
+			return blocklist.Fingerprint{}, fmt.Errorf("invalid cached fingerprint %q", s)
+		}
+		fp.HasDHash = true
+	}
+	return fp, nil
+}
@@ -0,0 +1,277 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultBlueskyAppView is the public AT Protocol AppView feeds are read from
+const DefaultBlueskyAppView = "https://public.api.bsky.app"
+
+// blueskyHandleTTL is how long a resolved handle stays cached
+const blueskyHandleTTL = 24 * time.Hour
+
+// blueskyAdultLabels mark posts that are kept out of the pool
+var blueskyAdultLabels = []string{"porn", "sexual", "nudity", "graphic-media", "gore"}
+
+// BlueskySource describes a Bluesky custom feed or the posts of one account
+type BlueskySource struct {
+	Actor string        // Handle or DID owning the feed, or whose posts are read
+	Feed  string        // Record key of a custom feed, empty for the actor's own posts
+	TTL   time.Duration // How long the raw response stays cached
+}
+
+// ParseBlueskySource parses a feed or profile as linked from bsky.app, e.g.
+// "https://bsky.app/profile/did:plc:abc/feed/funny?ttl=10m" or
+// "https://bsky.app/profile/memes.example.com", or an "at://" feed URI
+func ParseBlueskySource(spec string) (BlueskySource, error) {
+	invalid := fmt.Errorf("invalid Bluesky feed %q, expected https://bsky.app/profile/<actor>[/feed/<name>] or at://<did>/app.bsky.feed.generator/<name>", spec)
+	base, rawQuery, _ := strings.Cut(spec, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return BlueskySource{}, invalid
+	}
+
+	var source BlueskySource
+	if did, collection, rkey, ok := splitATURI(base); ok {
+		if collection != "app.bsky.feed.generator" || did == "" || rkey == "" {
+			return BlueskySource{}, invalid
+		}
+		source = BlueskySource{Actor: did, Feed: rkey}
+	} else {
+		u, err := url.Parse(base)
+		if err != nil || u.Scheme != "https" || u.Host != "bsky.app" {
+			return BlueskySource{}, invalid
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		switch {
+		case len(parts) == 2 && parts[0] == "profile":
+			source = BlueskySource{Actor: parts[1]}
+		case len(parts) == 4 && parts[0] == "profile" && parts[2] == "feed" && parts[3] != "":
+			source = BlueskySource{Actor: parts[1], Feed: parts[3]}
+		default:
+			return BlueskySource{}, invalid
+		}
+		if source.Actor == "" {
+			return BlueskySource{}, invalid
+		}
+	}
+
+	source.TTL = refreshInterval
+	if raw := query.Get("ttl"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return BlueskySource{}, fmt.Errorf("invalid ttl %q in Bluesky feed %q", raw, spec)
+		}
+		source.TTL = ttl
+	}
+	return source, nil
+}
+
+// Key identifies the feed in the response cache
+func (s BlueskySource) Key() string {
+	return strings.ToLower(s.Actor + "/" + s.Feed)
+}
+
+// SetBlueskySources replaces the Bluesky feeds memes are pulled from, read
+// through appView, and forces a refresh on next fetch
+func (ms *Service) SetBlueskySources(appView string, sources []BlueskySource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.blueskyAppView = strings.TrimSuffix(appView, "/")
+	ms.blueskySources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// blueskyImages is an images embed as the AppView presents it
+type blueskyImages struct {
+	Type   string `json:"$type"`
+	Images []struct {
+		Fullsize    string `json:"fullsize"`
+		Alt         string `json:"alt"`
+		AspectRatio *struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"aspectRatio,omitempty"`
+	} `json:"images"`
+}
+
+// blueskyLabel is a moderation label attached to a post
+type blueskyLabel struct {
+	Val string `json:"val"`
+}
+
+// blueskyFeed is the subset of a feed response we use
+type blueskyFeed struct {
+	Feed []struct {
+		Post struct {
+			URI    string `json:"uri"`
+			Author struct {
+				Handle string `json:"handle"`
+			} `json:"author"`
+			Record struct {
+				Text      string    `json:"text"`
+				CreatedAt time.Time `json:"createdAt"`
+			} `json:"record"`
+			Embed *struct {
+				blueskyImages
+				Media *blueskyImages `json:"media,omitempty"` // Images beside a quoted post
+			} `json:"embed,omitempty"`
+			LikeCount   int            `json:"likeCount"`
+			RepostCount int            `json:"repostCount"`
+			Labels      []blueskyLabel `json:"labels"`
+		} `json:"post"`
+	} `json:"feed"`
+}
+
+// blueskyProvider fetches the image posts of one Bluesky feed
+type blueskyProvider struct {
+	ms       *Service
+	source   BlueskySource
+	appView  string
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p blueskyProvider) Name() string {
+	if p.source.Feed == "" {
+		return "bluesky:" + p.source.Actor
+	}
+	return "bluesky:" + p.source.Actor + "/feed/" + p.source.Feed
+}
+
+// cacheKey implements cachedProvider
+func (p blueskyProvider) cacheKey() string {
+	return "response:bluesky:" + p.source.Key()
+}
+
+// Fetch implements Provider, turning every post with an images embed into a meme
+func (p blueskyProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	query := url.Values{}
+	query.Set("limit", "50")
+	endpoint := p.appView + "/xrpc/app.bsky.feed.getAuthorFeed"
+	if p.source.Feed == "" {
+		query.Set("actor", p.source.Actor)
+		query.Set("filter", "posts_with_media")
+	} else {
+		did, err := p.resolve(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("feed", "at://"+did+"/app.bsky.feed.generator/"+p.source.Feed)
+		endpoint = p.appView + "/xrpc/app.bsky.feed.getFeed"
+	}
+
+	var feed blueskyFeed
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "bluesky",
+		name:     p.Name(),
+		url:      endpoint + "?" + query.Encode(),
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &feed)
+	if err != nil {
+		return nil, err
+	}
+
+	memes := make([]Meme, 0, len(feed.Feed))
+	for _, item := range feed.Feed {
+		post := item.Post
+		adult := slices.ContainsFunc(post.Labels, func(l blueskyLabel) bool { return slices.Contains(blueskyAdultLabels, l.Val) })
+		if post.Embed == nil || adult {
+			continue
+		}
+
+		embed := post.Embed.blueskyImages
+		if post.Embed.Media != nil {
+			embed = *post.Embed.Media
+		}
+		if !strings.HasPrefix(embed.Type, "app.bsky.embed.images") || len(embed.Images) == 0 {
+			continue
+		}
+
+		did, _, rkey, ok := splitATURI(post.URI)
+		if !ok {
+			continue
+		}
+
+		meme := Meme{
+			ID:         "bluesky_" + strings.TrimPrefix(did, "did:plc:") + "_" + rkey,
+			Title:      strings.Join(strings.Fields(post.Record.Text), " "),
+			Score:      post.LikeCount + post.RepostCount,
+			Permalink:  "https://bsky.app/profile/" + post.Author.Handle + "/post/" + rkey,
+			CreatedUTC: post.Record.CreatedAt.Unix(),
+			MediaType:  MediaImage,
+		}
+		for _, img := range embed.Images {
+			image := Image{URL: img.Fullsize}
+			if img.AspectRatio != nil {
+				image.Width, image.Height = img.AspectRatio.Width, img.AspectRatio.Height
+			}
+			meme.Images = append(meme.Images, image)
+			if meme.Title == "" {
+				meme.Title = img.Alt
+			}
+		}
+		meme.URL = meme.Images[0].URL
+		meme.Width = meme.Images[0].Width
+		meme.Height = meme.Images[0].Height
+		if len(meme.Images) == 1 {
+			meme.Images = nil
+		}
+		if meme.Title == "" {
+			meme.Title = "@" + post.Author.Handle
+		}
+		meme.Tags = extractTags(meme.Title, "", "")
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// resolve returns the DID of the feed's owner, looking up handles, which
+// feed URIs cannot contain
+func (p blueskyProvider) resolve(ctx context.Context, client *http.Client) (string, error) {
+	if strings.HasPrefix(p.source.Actor, "did:") {
+		return p.source.Actor, nil
+	}
+
+	var resolved struct {
+		DID string `json:"did"`
+	}
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "bluesky",
+		name:     "Bluesky handle " + p.source.Actor,
+		url:      p.appView + "/xrpc/com.atproto.identity.resolveHandle?handle=" + url.QueryEscape(p.source.Actor),
+		cacheKey: "bluesky:handle:" + strings.ToLower(p.source.Actor),
+		ttl:      blueskyHandleTTL,
+		maxBytes: p.maxBytes,
+	}, &resolved)
+	if err != nil {
+		return "", err
+	}
+	if resolved.DID == "" {
+		return "", fmt.Errorf("Bluesky handle %s did not resolve", p.source.Actor)
+	}
+	return resolved.DID, nil
+}
+
+// splitATURI splits an "at://<authority>/<collection>/<rkey>" URI, which
+// net/url rejects because DIDs contain colons
+func splitATURI(uri string) (authority, collection, rkey string, ok bool) {
+	rest, found := strings.CutPrefix(uri, "at://")
+	if !found {
+		return "", "", "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
@@ -0,0 +1,71 @@
+package memeservice
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	netguard "github.com/elt0nxale/meme-tunnel-streamer/internal/netguard"
+)
+
+// ClientConfig tunes the shared outbound HTTP client
+type ClientConfig struct {
+	RequestTimeout        time.Duration // Overall per-request deadline
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConnsPerHost   int
+	HTTP2                 bool
+	PublicOnly            bool // Refuse loopback, private and link-local addresses, for URLs users control
+}
+
+// DefaultClientConfig returns the client settings used when none are supplied
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		RequestTimeout:        10 * time.Second,
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   maxConcurrentProbes,
+		HTTP2:                 true,
+	}
+}
+
+// NewHTTPClient builds a pooled HTTP client for upstream requests
+func NewHTTPClient(cfg ClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.PublicOnly {
+		// A proxy would dial on our behalf and sidestep the address check
+		dialer.Control = netguard.PublicAddressOnly
+		proxy = nil
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+	}
+
+	// A non-nil empty map disables HTTP/2 negotiation
+	if !cfg.HTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{
+		Timeout:   cfg.RequestTimeout,
+		Transport: transport,
+	}
+}
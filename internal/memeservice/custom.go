@@ -0,0 +1,264 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// customName restricts custom source names to what fits in meme IDs and provider names
+var customName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CustomFields are the JSONPath-style paths mapping a JSON API onto memes,
+// such as "$.data.memes[*]" or "preview.images[0].url". Items locates the
+// list of memes in the response and may be empty when the response is the
+// list; the others are read from each item, and only Title and URL are required
+type CustomFields struct {
+	Items     string
+	ID        string
+	Title     string
+	URL       string
+	Permalink string
+	Score     string
+	NSFW      string
+}
+
+// CustomSource is an arbitrary JSON API read as a meme provider
+type CustomSource struct {
+	Name   string // Identifies the source in IDs, /readyz and --provider-rate custom:<name>
+	URL    string
+	Header http.Header   // Extra request headers, e.g. credentials
+	TTL    time.Duration // How long the raw response stays cached
+
+	items, id, title, link, permalink, score, nsfw jsonPath
+}
+
+// NewCustomSource validates a custom source and parses its field paths; a
+// zero ttl defaults to the refresh interval
+func NewCustomSource(name, rawURL string, header http.Header, ttl time.Duration, fields CustomFields) (CustomSource, error) {
+	if !customName.MatchString(name) {
+		return CustomSource{}, fmt.Errorf("invalid custom source name %q, expected letters, digits, - and _", name)
+	}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return CustomSource{}, fmt.Errorf("invalid URL %q for custom source %s, expected an http or https URL", rawURL, name)
+	}
+	if fields.Title == "" || fields.URL == "" {
+		return CustomSource{}, fmt.Errorf("custom source %s needs title and image paths", name)
+	}
+	if ttl < 0 {
+		return CustomSource{}, fmt.Errorf("invalid ttl %s for custom source %s", ttl, name)
+	}
+	if ttl == 0 {
+		ttl = refreshInterval
+	}
+
+	source := CustomSource{Name: name, URL: rawURL, Header: header, TTL: ttl}
+	for _, field := range []struct {
+		name string
+		expr string
+		dst  *jsonPath
+	}{
+		{"items", strings.TrimSuffix(fields.Items, "[*]"), &source.items},
+		{"id", fields.ID, &source.id},
+		{"title", fields.Title, &source.title},
+		{"url", fields.URL, &source.link},
+		{"permalink", fields.Permalink, &source.permalink},
+		{"score", fields.Score, &source.score},
+		{"nsfw", fields.NSFW, &source.nsfw},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		p, err := parseJSONPath(field.expr)
+		if err != nil {
+			return CustomSource{}, fmt.Errorf("invalid %s path in custom source %s: %v", field.name, name, err)
+		}
+		*field.dst = p
+	}
+	return source, nil
+}
+
+// SetCustomSources replaces the custom JSON APIs memes are pulled from and
+// forces a refresh on next fetch
+func (ms *Service) SetCustomSources(sources []CustomSource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.customSources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// customProvider fetches one custom JSON API
+type customProvider struct {
+	ms       *Service
+	source   CustomSource
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p customProvider) Name() string {
+	return "custom:" + p.source.Name
+}
+
+// cacheKey implements cachedProvider
+func (p customProvider) cacheKey() string {
+	return "response:custom:" + p.source.Name
+}
+
+// Fetch implements Provider, mapping every item with a title and an http or
+// https URL into a meme and skipping those flagged NSFW
+func (p customProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	var doc any
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     p.Name(),
+		name:     p.Name(),
+		url:      p.source.URL,
+		header:   p.source.Header,
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := p.source.items.lookup(doc)
+	items, isList := list.([]any)
+	if !ok || !isList {
+		return nil, fmt.Errorf("%s: items path does not lead to a list", p.Name())
+	}
+
+	base, _ := url.Parse(p.source.URL)
+	memes := make([]Meme, 0, len(items))
+	for _, item := range items {
+		if nsfw, ok := p.source.nsfw.lookup(item); p.source.nsfw != nil && ok && truthy(nsfw) {
+			continue
+		}
+		title, rawLink := p.source.title.text(item), p.source.link.text(item)
+		if title == "" || rawLink == "" {
+			continue
+		}
+		link, err := base.Parse(rawLink)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			continue
+		}
+
+		meme := Meme{
+			Title: title,
+			URL:   link.String(),
+			Tags:  extractTags(title, "", ""),
+		}
+		if id := p.source.id.text(item); id != "" {
+			meme.ID = "custom_" + p.source.Name + "_" + id
+		}
+		if raw := p.source.permalink.text(item); raw != "" {
+			if permalink, err := base.Parse(raw); err == nil {
+				meme.Permalink = permalink.String()
+			}
+		}
+		if score, err := strconv.ParseFloat(p.source.score.text(item), 64); err == nil {
+			meme.Score = int(score)
+		}
+
+		switch strings.ToLower(path.Ext(link.Path)) {
+		case ".mp4", ".webm":
+			meme.MediaType = MediaVideo
+			meme.Video = &Video{URL: meme.URL}
+		default:
+			meme = withMediaType(meme)
+		}
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// jsonPath is a parsed JSONPath-style path of object keys and array indexes;
+// an empty path is the value itself
+type jsonPath []any
+
+// parseJSONPath parses dot-separated keys with [n] indexes and an optional
+// leading $, e.g. "$.data.children[0].title" or "items"
+func parseJSONPath(expr string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	p := jsonPath{}
+	for rest != "" {
+		if index, ok := strings.CutPrefix(rest, "["); ok {
+			n, after, found := strings.Cut(index, "]")
+			i, err := strconv.Atoi(n)
+			if !found || err != nil || i < 0 {
+				return nil, fmt.Errorf("bad index in %q", expr)
+			}
+			p = append(p, i)
+			rest = strings.TrimPrefix(after, ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("empty key in %q", expr)
+		}
+		p = append(p, rest[:end])
+		rest = strings.TrimPrefix(rest[end:], ".")
+	}
+	return p, nil
+}
+
+// lookup follows the path through a decoded JSON value
+func (p jsonPath) lookup(v any) (any, bool) {
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[step]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := v.([]any)
+			if !ok || step >= len(arr) {
+				return nil, false
+			}
+			v = arr[step]
+		}
+	}
+	return v, true
+}
+
+// text returns the string or number at the path, empty when it is missing
+func (p jsonPath) text(v any) string {
+	if p == nil {
+		return ""
+	}
+	v, _ = p.lookup(v)
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// truthy reports whether a JSON flag is set, accepting true, non-zero numbers and "true"
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package memeservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// MemeInfo is background on a meme format, such as a Know Your Meme entry
+type MemeInfo struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`    // Reference page, e.g. on knowyourmeme.com
+	Origin string `json:"origin,omitempty"` // Where the format comes from
+	Year   int    `json:"year,omitempty"`
+	About  string `json:"about,omitempty"`
+}
+
+// datasetEntry is a MemeInfo as stored in a dataset file, with the other
+// names it is known by
+type datasetEntry struct {
+	MemeInfo
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Dataset matches meme titles to the formats they use
+type Dataset struct {
+	phrases  map[string]*MemeInfo // Entry names and aliases by their space-joined tokens
+	maxWords int                  // Words in the longest phrase
+	entries  int
+}
+
+// LoadDataset reads a JSON array of meme formats, each with a name and
+// optional url, origin, year, about and aliases, e.g. exported from Know Your Meme
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meme dataset: %v", err)
+	}
+
+	var entries []datasetEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse meme dataset %s: %v", path, err)
+	}
+
+	d := &Dataset{phrases: make(map[string]*MemeInfo)}
+	for i := range entries {
+		if entries[i].Name == "" {
+			return nil, fmt.Errorf("meme dataset %s: entry %d has no name", path, i)
+		}
+		info := &entries[i].MemeInfo
+		for _, name := range append([]string{info.Name}, entries[i].Aliases...) {
+			words := tokenize(name)
+			if len(words) == 0 {
+				continue
+			}
+			// The first entry claiming a phrase keeps it
+			phrase := strings.Join(words, " ")
+			if _, taken := d.phrases[phrase]; !taken {
+				d.phrases[phrase] = info
+				d.maxWords = max(d.maxWords, len(words))
+			}
+		}
+	}
+	d.entries = len(entries)
+	return d, nil
+}
+
+// Len returns the number of meme formats in the dataset
+func (d *Dataset) Len() int {
+	return d.entries
+}
+
+// Lookup returns the format whose name or alias appears in title as whole
+// words, preferring the longest match
+func (d *Dataset) Lookup(title string) (*MemeInfo, bool) {
+	words := tokenize(title)
+	for n := min(d.maxWords, len(words)); n > 0; n-- {
+		for i := 0; i+n <= len(words); i++ {
+			if info, ok := d.phrases[strings.Join(words[i:i+n], " ")]; ok {
+				return info, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// SetDataset attaches background from d to memes whose titles name a known
+// format, from the next refresh on; nil disables it
+func (ms *Service) SetDataset(d *Dataset) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.dataset = d
+	ms.lastFetch = time.Time{}
+}
+
+// enrich sets the Info of memes found in the dataset; callers hold ms.mu
+func (ms *Service) enrich(memes []Meme) {
+	if ms.dataset == nil {
+		return
+	}
+	for i := range memes {
+		if info, ok := ms.dataset.Lookup(memes[i].Title); ok {
+			memes[i].Info = info
+		} else {
+			memes[i].Info = nil
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package memeservice
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Media types a meme can have
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+	MediaGIF   = "gif"
+)
+
+// mediaTypes are the values accepted by ?media=
+var mediaTypes = []string{MediaImage, MediaVideo, MediaGIF}
+
+// Filter restricts which memes a stream or listing receives; the zero value matches all
+type Filter struct {
+	Tags       []string // Meme must carry at least one of these normalized tags
+	MediaTypes []string // Meme must be one of these media types
+	MaxBytes   int64    // Memes with a known size above this are skipped, 0 for no limit
+}
+
+// byteUnits are the suffixes accepted by ParseByteSize, longest first
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// ParseByteSize parses sizes like "5MB", "500KB" or "1048576" (binary units)
+func ParseByteSize(raw string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 5MB", raw)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseMediaTypes parses a comma-separated ?media= value
+func ParseMediaTypes(raw string) ([]string, error) {
+	var types []string
+	for _, media := range strings.Split(raw, ",") {
+		media = strings.ToLower(strings.TrimSpace(media))
+		if media == "" {
+			continue
+		}
+		if !slices.Contains(mediaTypes, media) {
+			return nil, fmt.Errorf("unknown media type %q, expected %s", media, strings.Join(mediaTypes, ", "))
+		}
+		types = append(types, media)
+	}
+	return types, nil
+}
+
+// FilterFromQuery builds a filter from ?tags=, ?media= and ?max_size= parameters
+func FilterFromQuery(query url.Values) (Filter, error) {
+	media, err := ParseMediaTypes(query.Get("media"))
+	if err != nil {
+		return Filter{}, err
+	}
+	maxBytes, err := ParseByteSize(query.Get("max_size"))
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid max_size: %v", err)
+	}
+	return Filter{Tags: ParseTags(query.Get("tags")), MediaTypes: media, MaxBytes: maxBytes}, nil
+}
+
+// Narrow combines a server-wide filter with a request's: tags and media types
+// must satisfy both and the smaller size limit wins
+func (f Filter) Narrow(request Filter) Filter {
+	narrowed := Filter{Tags: f.Tags, MediaTypes: f.MediaTypes, MaxBytes: f.MaxBytes}
+	if request.MaxBytes > 0 && (f.MaxBytes == 0 || request.MaxBytes < f.MaxBytes) {
+		narrowed.MaxBytes = request.MaxBytes
+	}
+	switch {
+	case len(f.Tags) == 0:
+		narrowed.Tags = request.Tags
+	case len(request.Tags) > 0:
+		narrowed.Tags = slices.DeleteFunc(slices.Clone(request.Tags), func(tag string) bool {
+			return !slices.Contains(f.Tags, tag)
+		})
+		if len(narrowed.Tags) == 0 {
+			// No meme carries the empty tag, so this matches none
+			narrowed.Tags = []string{""}
+		}
+	}
+	switch {
+	case len(f.MediaTypes) == 0:
+		narrowed.MediaTypes = request.MediaTypes
+	case len(request.MediaTypes) > 0:
+		narrowed.MediaTypes = slices.DeleteFunc(slices.Clone(request.MediaTypes), func(media string) bool {
+			return !slices.Contains(f.MediaTypes, media)
+		})
+		if len(narrowed.MediaTypes) == 0 {
+			// Nothing satisfies both; match no meme rather than every meme
+			narrowed.MediaTypes = []string{"none"}
+		}
+	}
+	return narrowed
+}
+
+// Matches reports whether a meme passes the filter
+func (f Filter) Matches(meme Meme) bool {
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(tag string) bool {
+		return slices.Contains(meme.Tags, tag)
+	}) {
+		return false
+	}
+	if len(f.MediaTypes) > 0 && !slices.Contains(f.MediaTypes, meme.MediaType) {
+		return false
+	}
+	// Unknown sizes pass, since HEAD probes can fail or be refused
+	if f.MaxBytes > 0 && meme.Size > f.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// Empty reports whether the filter matches every meme
+func (f Filter) Empty() bool {
+	return len(f.Tags) == 0 && len(f.MediaTypes) == 0 && f.MaxBytes == 0
+}
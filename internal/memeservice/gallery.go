@@ -0,0 +1,58 @@
+package memeservice
+
+import "html"
+
+// Image is one picture of a multi-image meme
+type Image struct {
+	URL      string `json:"url"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	ProxyURL string `json:"proxy_url,omitempty"` // Signed /img URL when the image proxy is enabled
+}
+
+// redditGallery holds the gallery fields of a Reddit post
+type redditGallery struct {
+	IsGallery bool `json:"is_gallery"`
+
+	GalleryData struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+
+	MediaMetadata map[string]struct {
+		Status string `json:"status"`
+		S      struct {
+			U   string `json:"u"`   // Still image
+			GIF string `json:"gif"` // Animated images
+			X   int    `json:"x"`
+			Y   int    `json:"y"`
+		} `json:"s"`
+	} `json:"media_metadata"`
+}
+
+// galleryImages expands a gallery post into its images in display order;
+// Reddit HTML-escapes the URLs in media_metadata
+func (g redditGallery) galleryImages() []Image {
+	if !g.IsGallery {
+		return nil
+	}
+
+	var images []Image
+	for _, item := range g.GalleryData.Items {
+		media, exists := g.MediaMetadata[item.MediaID]
+		if !exists || media.Status != "valid" {
+			continue
+		}
+
+		url := media.S.U
+		if url == "" {
+			url = media.S.GIF
+		}
+		if url == "" {
+			continue
+		}
+		images = append(images, Image{URL: html.UnescapeString(url), Width: media.S.X, Height: media.S.Y})
+	}
+	return images
+}
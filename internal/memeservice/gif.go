@@ -0,0 +1,110 @@
+package memeservice
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// previewVariants are the alternative renditions Reddit offers for animated previews
+type previewVariants struct {
+	GIF *struct {
+		Source struct {
+			URL string `json:"url"`
+		} `json:"source"`
+	} `json:"gif"`
+}
+
+// gifDomains host animated content whatever the URL's extension
+var gifDomains = []string{"giphy.com", "media.giphy.com", "i.giphy.com", "tenor.com", "media.tenor.com", "gfycat.com"}
+
+// gifURL reports whether a post is animated and returns a URL clients can
+// show in an <img>, judged by extension, domain and Reddit's preview variants
+func (p RedditPost) gifURL(current string) (string, bool) {
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".gif":
+		return current, true
+	case ".gifv":
+		// Imgur's .gifv is an HTML page; the .gif beside it is the animation
+		u.Path = strings.TrimSuffix(u.Path, path.Ext(u.Path)) + ".gif"
+		return u.String(), true
+	}
+
+	for _, domain := range gifDomains {
+		if strings.EqualFold(p.Domain, domain) {
+			return current, true
+		}
+	}
+
+	// Reddit attaches a gif variant to previews of animated images
+	if p.PostHint == "image" && len(p.Preview.Images) > 0 {
+		if gif := p.Preview.Images[0].Variants.GIF; gif != nil && gif.Source.URL != "" {
+			return html.UnescapeString(gif.Source.URL), true
+		}
+	}
+	return "", false
+}
+
+// Rendition is an alternative encoding of an animated meme, e.g. a smaller GIF or an MP4
+type Rendition struct {
+	Name   string `json:"name"`   // Provider's name for it, e.g. fixed_height or tinygif
+	Format string `json:"format"` // gif, mp4, webp or webm
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// newRendition describes a rendition, taking its format from the URL's extension
+func newRendition(name, link string, width, height int, size int64) Rendition {
+	format := ""
+	if u, err := url.Parse(link); err == nil {
+		format = strings.TrimPrefix(strings.ToLower(path.Ext(u.Path)), ".")
+	}
+	return Rendition{Name: name, Format: format, URL: link, Width: width, Height: height, Size: size}
+}
+
+// GIFSource is a GIF provider's trending list or the results of a search
+type GIFSource struct {
+	Query string        // Search terms, empty for trending
+	TTL   time.Duration // How long the raw response stays cached
+}
+
+// ParseGIFSource parses a "trending[:ttl]" or "search:<query>[:ttl]" source
+func ParseGIFSource(spec string) (GIFSource, error) {
+	parts := strings.Split(spec, ":")
+	source := GIFSource{TTL: refreshInterval}
+	ttlIndex := 1
+	switch {
+	case parts[0] == "trending" && len(parts) <= 2:
+	case parts[0] == "search" && len(parts) >= 2 && len(parts) <= 3 && strings.TrimSpace(parts[1]) != "":
+		source.Query = strings.TrimSpace(parts[1])
+		ttlIndex = 2
+	default:
+		return GIFSource{}, fmt.Errorf("invalid GIF source %q, expected trending[:ttl] or search:<query>[:ttl]", spec)
+	}
+
+	if len(parts) > ttlIndex && parts[ttlIndex] != "" {
+		ttl, err := time.ParseDuration(parts[ttlIndex])
+		if err != nil || ttl <= 0 {
+			return GIFSource{}, fmt.Errorf("invalid ttl %q in GIF source %q", parts[ttlIndex], spec)
+		}
+		source.TTL = ttl
+	}
+	return source, nil
+}
+
+// Key identifies the source in the response cache
+func (s GIFSource) Key() string {
+	if s.Query == "" {
+		return "trending"
+	}
+	return "search/" + strings.ToLower(s.Query)
+}
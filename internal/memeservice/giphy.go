@@ -0,0 +1,142 @@
+package memeservice
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultGiphyAPIURL is the Giphy API GIFs are read from
+const DefaultGiphyAPIURL = "https://api.giphy.com/v1"
+
+// giphyRenditions are the Giphy renditions copied into a meme, largest first
+var giphyRenditions = []string{"original", "downsized", "fixed_height", "fixed_width", "fixed_height_small"}
+
+// SetGiphySources replaces the Giphy lists memes are pulled from, read from
+// apiURL with apiKey, and forces a refresh on next fetch
+func (ms *Service) SetGiphySources(apiURL, apiKey string, sources []GIFSource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.giphyAPIURL = apiURL
+	ms.giphyAPIKey = apiKey
+	ms.giphySources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// giphyImage is one rendition of a Giphy GIF; Giphy sends numbers as strings
+type giphyImage struct {
+	URL      string `json:"url"`
+	Width    string `json:"width"`
+	Height   string `json:"height"`
+	Size     string `json:"size"`
+	MP4      string `json:"mp4,omitempty"`
+	MP4Size  string `json:"mp4_size,omitempty"`
+	WebP     string `json:"webp,omitempty"`
+	WebPSize string `json:"webp_size,omitempty"`
+}
+
+// giphyResponse is the subset of a Giphy list we use
+type giphyResponse struct {
+	Data []struct {
+		ID       string                `json:"id"`
+		Title    string                `json:"title"`
+		URL      string                `json:"url"` // Page on giphy.com
+		Imported string                `json:"import_datetime"`
+		Images   map[string]giphyImage `json:"images"`
+	} `json:"data"`
+}
+
+// giphyProvider fetches Giphy's trending GIFs or a search
+type giphyProvider struct {
+	ms       *Service
+	source   GIFSource
+	apiURL   string
+	apiKey   string
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p giphyProvider) Name() string {
+	return "giphy:" + p.source.Key()
+}
+
+// cacheKey implements cachedProvider
+func (p giphyProvider) cacheKey() string {
+	return "response:giphy:" + p.source.Key()
+}
+
+// Fetch implements Provider, mapping each GIF's renditions into its meme
+func (p giphyProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	query := url.Values{}
+	query.Set("api_key", p.apiKey)
+	query.Set("limit", "25")
+	query.Set("rating", "pg-13")
+	endpoint := p.apiURL + "/gifs/trending"
+	if p.source.Query != "" {
+		query.Set("q", p.source.Query)
+		endpoint = p.apiURL + "/gifs/search"
+	}
+
+	var list giphyResponse
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "giphy",
+		name:     p.Name(),
+		url:      endpoint + "?" + query.Encode(),
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	memes := make([]Meme, 0, len(list.Data))
+	for _, gif := range list.Data {
+		original, ok := gif.Images["original"]
+		if !ok || original.URL == "" {
+			continue
+		}
+
+		meme := Meme{
+			ID:        "giphy_" + gif.ID,
+			Title:     gif.Title,
+			URL:       original.URL,
+			Width:     atoi(original.Width),
+			Height:    atoi(original.Height),
+			Size:      int64(atoi(original.Size)),
+			Permalink: gif.URL,
+			MediaType: MediaGIF,
+		}
+		if imported, err := time.Parse(time.DateTime, gif.Imported); err == nil {
+			meme.CreatedUTC = imported.Unix()
+		}
+		if meme.Title == "" {
+			meme.Title = "GIF"
+		}
+		meme.Tags = extractTags(meme.Title, "", p.source.Query)
+
+		for _, name := range giphyRenditions {
+			image, ok := gif.Images[name]
+			if !ok {
+				continue
+			}
+			width, height := atoi(image.Width), atoi(image.Height)
+			for _, encoding := range [][2]string{{image.URL, image.Size}, {image.MP4, image.MP4Size}, {image.WebP, image.WebPSize}} {
+				if link, size := encoding[0], encoding[1]; link != "" {
+					meme.Renditions = append(meme.Renditions, newRendition(name, link, width, height, int64(atoi(size))))
+				}
+			}
+		}
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// atoi parses a number sent as a string, 0 when it is missing or invalid
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
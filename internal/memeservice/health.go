@@ -0,0 +1,134 @@
+package memeservice
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// unhealthyAfter is how many consecutive failures take a provider out of rotation
+	unhealthyAfter = 3
+
+	// minProviderBackoff and maxProviderBackoff bound how long an unhealthy provider is skipped
+	minProviderBackoff = 30 * time.Second
+	maxProviderBackoff = 5 * time.Minute
+
+	// healthAlpha weights the newest sample in the moving averages
+	healthAlpha = 0.3
+)
+
+// errProviderUnhealthy marks a provider skipped while it cools down
+var errProviderUnhealthy = errors.New("provider unhealthy, skipped until retry")
+
+// ProviderHealth is a provider's recent reliability
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	Status              string     `json:"status"` // healthy, degraded or unhealthy
+	Requests            int64      `json:"requests"`
+	Errors              int64      `json:"errors"`
+	ErrorRate           float64    `json:"error_rate"` // Moving average, 0-1
+	LatencyMs           float64    `json:"latency_ms"` // Moving average of fetch time
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // When an unhealthy provider is tried again
+}
+
+// providerState tracks one provider between refreshes
+type providerState struct {
+	health  ProviderHealth
+	backoff time.Duration
+}
+
+// healthTracker records fetch outcomes and decides which providers to skip
+type healthTracker struct {
+	mu        sync.Mutex
+	providers map[string]*providerState
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{providers: make(map[string]*providerState)}
+}
+
+// state returns the tracked state for a provider; callers must hold ht.mu
+func (ht *healthTracker) state(name string) *providerState {
+	st, exists := ht.providers[name]
+	if !exists {
+		st = &providerState{health: ProviderHealth{Name: name, Status: "healthy"}}
+		ht.providers[name] = st
+	}
+	return st
+}
+
+// allow reports whether a provider should be fetched now; unhealthy providers
+// get a single trial once their backoff expires
+func (ht *healthTracker) allow(name string, now time.Time) bool {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	retryAt := ht.state(name).health.RetryAt
+	return retryAt == nil || !now.Before(*retryAt)
+}
+
+// record updates a provider's health with the outcome of a fetch
+func (ht *healthTracker) record(name string, latency time.Duration, err error, now time.Time) {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	st := ht.state(name)
+	h := &st.health
+
+	ms := float64(latency) / float64(time.Millisecond)
+	if h.Requests == 0 {
+		h.LatencyMs = ms
+	} else {
+		h.LatencyMs = healthAlpha*ms + (1-healthAlpha)*h.LatencyMs
+	}
+	h.Requests++
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	h.ErrorRate = healthAlpha*failed + (1-healthAlpha)*h.ErrorRate
+
+	if err == nil {
+		h.ConsecutiveFailures = 0
+		h.LastSuccess = &now
+		h.RetryAt = nil
+		st.backoff = 0
+	} else {
+		h.Errors++
+		h.ConsecutiveFailures++
+		h.LastError = err.Error()
+
+		// Take the provider out of rotation with exponential backoff
+		if h.ConsecutiveFailures >= unhealthyAfter {
+			st.backoff = min(max(st.backoff*2, minProviderBackoff), maxProviderBackoff)
+			retryAt := now.Add(st.backoff)
+			h.RetryAt = &retryAt
+		}
+	}
+
+	switch {
+	case h.ConsecutiveFailures >= unhealthyAfter:
+		h.Status = "unhealthy"
+	case h.ConsecutiveFailures > 0 || h.ErrorRate > 0.5:
+		h.Status = "degraded"
+	default:
+		h.Status = "healthy"
+	}
+}
+
+// snapshot returns the health of the named providers in order
+func (ht *healthTracker) snapshot(names []string) []ProviderHealth {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	health := make([]ProviderHealth, 0, len(names))
+	for _, name := range names {
+		health = append(health, ht.state(name).health)
+	}
+	return health
+}
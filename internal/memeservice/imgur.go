@@ -0,0 +1,217 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultImgurAPIURL is the Imgur API galleries are read from
+const DefaultImgurAPIURL = "https://api.imgur.com/3"
+
+// ImgurSource describes an Imgur gallery section or subreddit gallery
+type ImgurSource struct {
+	Section string        // hot, top, user, or r/<subreddit>
+	Sort    string        // viral, top, time or rising (user only); time or top for subreddits
+	Window  string        // day, week, month, year, all (top only)
+	TTL     time.Duration // How long the raw response stays cached
+}
+
+// ParseImgurSource parses a "section[:sort[:window[:ttl]]]" source, where
+// section is hot, top, user or r/<subreddit>
+func ParseImgurSource(spec string) (ImgurSource, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 4 || parts[0] == "" {
+		return ImgurSource{}, fmt.Errorf("invalid Imgur source %q, expected section[:sort[:window[:ttl]]]", spec)
+	}
+
+	source := ImgurSource{Section: parts[0], Sort: "viral", TTL: refreshInterval}
+	sorts := []string{"viral", "top", "time"}
+	subreddit, isSubreddit := strings.CutPrefix(parts[0], "r/")
+	switch {
+	case isSubreddit && subreddit != "" && !strings.Contains(subreddit, "/"):
+		source.Sort = "time"
+		sorts = []string{"time", "top"}
+	case parts[0] == "user":
+		sorts = append(sorts, "rising")
+	case parts[0] != "hot" && parts[0] != "top":
+		return ImgurSource{}, fmt.Errorf("invalid section %q in Imgur source %q, expected hot, top, user or r/<subreddit>", parts[0], spec)
+	}
+
+	if len(parts) > 1 && parts[1] != "" {
+		if !slices.Contains(sorts, parts[1]) {
+			return ImgurSource{}, fmt.Errorf("invalid sort %q in Imgur source %q", parts[1], spec)
+		}
+		source.Sort = parts[1]
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		switch parts[2] {
+		case "day", "week", "month", "year", "all":
+			source.Window = parts[2]
+		default:
+			return ImgurSource{}, fmt.Errorf("invalid window %q in Imgur source %q", parts[2], spec)
+		}
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		ttl, err := time.ParseDuration(parts[3])
+		if err != nil || ttl <= 0 {
+			return ImgurSource{}, fmt.Errorf("invalid ttl %q in Imgur source %q", parts[3], spec)
+		}
+		source.TTL = ttl
+	}
+	return source, nil
+}
+
+// Key identifies the source in the response cache
+func (s ImgurSource) Key() string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", s.Section, s.Sort, s.Window))
+}
+
+// URL returns the gallery endpoint of the source's first page on the given API
+func (s ImgurSource) URL(apiURL string) string {
+	path := "/gallery/" + s.Section + "/" + s.Sort
+	if subreddit, ok := strings.CutPrefix(s.Section, "r/"); ok {
+		path = "/gallery/r/" + url.PathEscape(subreddit) + "/" + s.Sort
+	}
+	if s.Window != "" {
+		path += "/" + s.Window
+	}
+	return strings.TrimSuffix(apiURL, "/") + path + "/0"
+}
+
+// SetImgurSources replaces the Imgur galleries memes are pulled from, read
+// from apiURL with clientID, and forces a refresh on next fetch
+func (ms *Service) SetImgurSources(apiURL, clientID string, sources []ImgurSource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.imgurAPIURL = apiURL
+	ms.imgurClientID = clientID
+	ms.imgurSources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// imgurImage is a single image or video of an Imgur post
+type imgurImage struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // MIME type, e.g. image/gif or video/mp4
+	Link     string `json:"link"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Size     int64  `json:"size"`
+	Animated bool   `json:"animated"`
+	MP4      string `json:"mp4,omitempty"`
+}
+
+// imgurGallery is the subset of a gallery response we use
+type imgurGallery struct {
+	Data []struct {
+		imgurImage
+		Title    string       `json:"title"`
+		Points   int          `json:"points"`
+		Datetime int64        `json:"datetime"`
+		NSFW     bool         `json:"nsfw"`
+		IsAlbum  bool         `json:"is_album"`
+		Images   []imgurImage `json:"images,omitempty"` // Album contents
+		Tags     []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+	} `json:"data"`
+}
+
+// imgurProvider fetches the posts of one Imgur gallery
+type imgurProvider struct {
+	ms       *Service
+	source   ImgurSource
+	apiURL   string
+	clientID string
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p imgurProvider) Name() string {
+	name := "imgur:" + p.source.Section + "/" + p.source.Sort
+	if p.source.Window != "" {
+		name += "/" + p.source.Window
+	}
+	return name
+}
+
+// cacheKey implements cachedProvider
+func (p imgurProvider) cacheKey() string {
+	return "response:imgur:" + p.source.Key()
+}
+
+// Fetch implements Provider, turning posts and albums into memes, albums as galleries
+func (p imgurProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	var gallery imgurGallery
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "imgur",
+		name:     p.Name(),
+		url:      p.source.URL(p.apiURL),
+		header:   http.Header{"Authorization": {"Client-ID " + p.clientID}},
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &gallery)
+	if err != nil {
+		return nil, err
+	}
+
+	subreddit, _ := strings.CutPrefix(p.source.Section, "r/")
+	if subreddit == p.source.Section {
+		subreddit = ""
+	}
+
+	memes := make([]Meme, 0, len(gallery.Data))
+	for _, post := range gallery.Data {
+		images := post.Images
+		if !post.IsAlbum {
+			images = []imgurImage{post.imgurImage}
+		}
+		if post.NSFW || len(images) == 0 || images[0].Link == "" {
+			continue
+		}
+
+		var flair []string
+		for _, tag := range post.Tags {
+			flair = append(flair, tag.Name)
+		}
+		meme := Meme{
+			ID:         "imgur_" + post.ID,
+			Title:      post.Title,
+			Score:      post.Points,
+			Subreddit:  subreddit,
+			Permalink:  "https://imgur.com/gallery/" + post.ID,
+			CreatedUTC: post.Datetime,
+			Tags:       extractTags(post.Title, strings.Join(flair, " "), subreddit),
+		}
+
+		first := images[0]
+		if first.Type == "video/mp4" {
+			meme.MediaType = MediaVideo
+			meme.Video = &Video{URL: first.Link, Width: first.Width, Height: first.Height}
+			meme.URL = first.Link
+		} else {
+			for _, img := range images {
+				if img.Type != "video/mp4" {
+					meme.Images = append(meme.Images, Image{URL: img.Link, Width: img.Width, Height: img.Height})
+				}
+			}
+			meme.URL = first.Link
+			if len(meme.Images) == 1 {
+				meme.Images = nil
+			}
+			meme = withMediaType(meme)
+		}
+		meme.Width = first.Width
+		meme.Height = first.Height
+		meme.Size = first.Size
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
@@ -0,0 +1,171 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// lemmySorts are the post sorts the Lemmy API accepts
+var lemmySorts = []string{
+	"Active", "Hot", "New", "Old", "Scaled", "Controversial", "MostComments", "NewComments",
+	"TopHour", "TopSixHour", "TopTwelveHour", "TopDay", "TopWeek", "TopMonth",
+	"TopThreeMonths", "TopSixMonths", "TopNineMonths", "TopYear", "TopAll",
+}
+
+// LemmySource describes a Lemmy community to pull memes from
+type LemmySource struct {
+	Instance  string        // Base URL of the instance, e.g. https://lemmy.world
+	Community string        // Community name, e.g. memes
+	Sort      string        // One of lemmySorts
+	TTL       time.Duration // How long the raw response stays cached
+}
+
+// ParseLemmySource parses a community URL such as
+// "https://lemmy.world/c/memes?sort=TopDay&ttl=10m"; sort defaults to Hot
+func ParseLemmySource(spec string) (LemmySource, error) {
+	u, err := url.Parse(spec)
+	community, found := "", false
+	if err == nil {
+		community, found = strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/c/")
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || !found || community == "" || strings.Contains(community, "/") {
+		return LemmySource{}, fmt.Errorf("invalid Lemmy community %q, expected https://instance/c/community", spec)
+	}
+
+	source := LemmySource{
+		Instance:  u.Scheme + "://" + u.Host,
+		Community: community,
+		Sort:      "Hot",
+		TTL:       refreshInterval,
+	}
+	query := u.Query()
+	if sort := query.Get("sort"); sort != "" {
+		i := slices.IndexFunc(lemmySorts, func(s string) bool { return strings.EqualFold(s, sort) })
+		if i < 0 {
+			return LemmySource{}, fmt.Errorf("invalid sort %q in Lemmy community %q", sort, spec)
+		}
+		source.Sort = lemmySorts[i]
+	}
+	if raw := query.Get("ttl"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return LemmySource{}, fmt.Errorf("invalid ttl %q in Lemmy community %q", raw, spec)
+		}
+		source.TTL = ttl
+	}
+	return source, nil
+}
+
+// Key identifies the community in the response cache
+func (s LemmySource) Key() string {
+	return strings.ToLower(fmt.Sprintf("%s/c/%s/%s", s.Instance, s.Community, s.Sort))
+}
+
+// URL returns the API listing URL for the community's posts
+func (s LemmySource) URL() string {
+	query := url.Values{}
+	query.Set("community_name", s.Community)
+	query.Set("sort", s.Sort)
+	query.Set("limit", "26")
+	return s.Instance + "/api/v3/post/list?" + query.Encode()
+}
+
+// SetLemmySources replaces the Lemmy communities memes are pulled from and
+// forces a refresh on next fetch
+func (ms *Service) SetLemmySources(sources []LemmySource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.lemmySources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// lemmyResponse is the subset of a Lemmy post listing we use
+type lemmyResponse struct {
+	Posts []struct {
+		Post struct {
+			ID        int64  `json:"id"`
+			Name      string `json:"name"`
+			URL       string `json:"url"`
+			APID      string `json:"ap_id"` // Canonical URL of the post
+			Published string `json:"published"`
+			NSFW      bool   `json:"nsfw"`
+		} `json:"post"`
+		Counts struct {
+			Score int `json:"score"`
+		} `json:"counts"`
+	} `json:"posts"`
+}
+
+// lemmyProvider fetches the posts of one Lemmy community
+type lemmyProvider struct {
+	ms       *Service
+	source   LemmySource
+	maxBytes int64 // Largest listing read, 0 for no limit
+}
+
+// Name implements Provider
+func (p lemmyProvider) Name() string {
+	u, _ := url.Parse(p.source.Instance)
+	return "lemmy:" + p.source.Community + "@" + u.Host + "/" + p.source.Sort
+}
+
+// cacheKey implements cachedProvider
+func (p lemmyProvider) cacheKey() string {
+	return "response:lemmy:" + p.source.Key()
+}
+
+// Fetch implements Provider, keeping image and GIF posts that are safe for work
+func (p lemmyProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	var listing lemmyResponse
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "lemmy",
+		name:     p.Name(),
+		url:      p.source.URL(),
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &listing)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(p.source.Instance)
+	memes := make([]Meme, 0, len(listing.Posts))
+	for _, item := range listing.Posts {
+		post := item.Post
+		if post.NSFW || !isImageURL(post.URL) {
+			continue
+		}
+		meme := withMediaType(Meme{
+			ID:        fmt.Sprintf("lemmy_%s_%d", u.Host, post.ID),
+			Title:     post.Name,
+			URL:       post.URL,
+			Score:     item.Counts.Score,
+			Subreddit: p.source.Community,
+			Permalink: post.APID,
+			Tags:      extractTags(post.Name, "", p.source.Community),
+		})
+		if published, ok := parseLemmyTime(post.Published); ok {
+			meme.CreatedUTC = published.Unix()
+		}
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// parseLemmyTime parses a post's publication time; instances before 0.19
+// send it without a time zone, in UTC
+func parseLemmyTime(raw string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
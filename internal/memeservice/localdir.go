@@ -0,0 +1,221 @@
+package memeservice
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// localIDPrefix marks the IDs of memes read from the local directory
+const localIDPrefix = "local_"
+
+// localDebounce waits out bursts of file events, such as a large copy, before rescanning
+const localDebounce = 500 * time.Millisecond
+
+// localExtensions are the file types streamed from the local directory
+var localExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".mp4", ".webm"}
+
+// IsLocalFile reports whether name is a file the local directory provider
+// streams, rather than a sidecar, hidden file or path into a subdirectory
+func IsLocalFile(name string) bool {
+	return filepath.Base(name) == name && !strings.HasPrefix(name, ".") && slices.Contains(localExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// SetLocalDir streams the images and videos in dir, linking them under
+// urlPrefix where the caller serves the directory, and forces a refresh on
+// next fetch. An empty dir disables the provider
+func (ms *Service) SetLocalDir(dir, urlPrefix string) error {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("failed to open local meme directory: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("local meme directory %s is not a directory", dir)
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.localDir = dir
+	ms.localURL = urlPrefix
+	ms.lastFetch = time.Time{}
+	return nil
+}
+
+// WatchLocalDir hot-adds files to the pool as they appear in the local
+// directory, and drops them as they are deleted, until ctx is done
+func (ms *Service) WatchLocalDir(ctx context.Context) error {
+	ms.mu.RLock()
+	dir, urlPrefix := ms.localDir, ms.localURL
+	ms.mu.RUnlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", dir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(localDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Local meme directory watcher error: %v", err)
+			case <-debounce:
+				debounce = nil
+				memes, err := scanLocalDir(dir, urlPrefix)
+				if err != nil {
+					log.Printf("Failed to rescan local meme directory: %v", err)
+					continue
+				}
+				ms.setLocalMemes(memes)
+			}
+		}
+	}()
+	return nil
+}
+
+// setLocalMemes swaps the local directory's memes in the pool for memes,
+// leaving every other provider's untouched
+func (ms *Service) setLocalMemes(memes []Meme) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	before := len(ms.memes)
+	pool := slices.DeleteFunc(slices.Clone(ms.memes), func(m Meme) bool { return strings.HasPrefix(m.ID, localIDPrefix) })
+	local := before - len(pool)
+	ms.enrich(memes)
+	ms.memes = ms.limitPool(append(pool, memes...), time.Now())
+	if len(memes) != local {
+		log.Printf("Local meme directory now has %d memes (was %d)", len(memes), local)
+	}
+}
+
+// localProvider streams the files of a directory on disk
+type localProvider struct {
+	dir       string
+	urlPrefix string
+}
+
+// Name implements Provider
+func (p localProvider) Name() string {
+	return "local:" + p.dir
+}
+
+// Fetch implements Provider, reading the directory without touching the network
+func (p localProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	return scanLocalDir(p.dir, p.urlPrefix)
+}
+
+// scanLocalDir builds a meme from every image and video directly inside dir
+func scanLocalDir(dir, urlPrefix string) ([]Meme, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local meme directory: %v", err)
+	}
+
+	var memes []Meme
+	for _, entry := range entries {
+		if entry.IsDir() || !IsLocalFile(entry.Name()) {
+			continue
+		}
+		meme, err := localMeme(dir, entry.Name(), urlPrefix)
+		if err != nil {
+			log.Printf("Skipping local meme %s: %v", entry.Name(), err)
+			continue
+		}
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// localMeme builds the meme of one file, titled by the first line of a
+// sidecar .txt file with the same name, or else by the file name
+func localMeme(dir, name, urlPrefix string) (Meme, error) {
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return Meme{}, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	meme := Meme{
+		ID:         localIDPrefix + name,
+		Title:      sidecarTitle(filepath.Join(dir, stem+".txt")),
+		URL:        urlPrefix + url.PathEscape(name),
+		Size:       info.Size(),
+		CreatedUTC: info.ModTime().Unix(),
+		MediaType:  MediaImage,
+	}
+	if meme.Title == "" {
+		meme.Title = strings.Join(strings.Fields(strings.NewReplacer("_", " ", "-", " ").Replace(stem)), " ")
+	}
+	meme.Tags = extractTags(meme.Title, "", "")
+
+	switch ext {
+	case ".mp4", ".webm":
+		meme.MediaType = MediaVideo
+		meme.Video = &Video{URL: meme.URL}
+	case ".gif":
+		meme.MediaType = MediaGIF
+	}
+	if meme.MediaType != MediaVideo {
+		if f, err := os.Open(path); err == nil {
+			if config, _, err := image.DecodeConfig(bufio.NewReader(f)); err == nil {
+				meme.Width, meme.Height = config.Width, config.Height
+			}
+			f.Close()
+		}
+	}
+	return meme, nil
+}
+
+// sidecarTitle returns the first non-blank line of a sidecar file, empty when
+// there is none
+func sidecarTitle(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line
+		}
+	}
+	return ""
+}
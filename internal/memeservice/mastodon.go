@@ -0,0 +1,212 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMastodonTitle bounds titles taken from post text, in characters
+const maxMastodonTitle = 300
+
+// htmlTag matches the markup of Mastodon post content
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// MastodonSource describes a hashtag timeline on a Mastodon instance
+type MastodonSource struct {
+	Instance string        // Base URL of the instance, e.g. https://mastodon.social
+	Hashtag  string        // Without the #, e.g. memes
+	TTL      time.Duration // How long the raw response stays cached
+}
+
+// ParseMastodonSource parses a hashtag URL such as
+// "https://mastodon.social/tags/memes?ttl=10m"
+func ParseMastodonSource(spec string) (MastodonSource, error) {
+	u, err := url.Parse(spec)
+	hashtag, found := "", false
+	if err == nil {
+		hashtag, found = strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/tags/")
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || !found || hashtag == "" || strings.Contains(hashtag, "/") {
+		return MastodonSource{}, fmt.Errorf("invalid Mastodon hashtag %q, expected https://instance/tags/hashtag", spec)
+	}
+
+	source := MastodonSource{
+		Instance: u.Scheme + "://" + u.Host,
+		Hashtag:  strings.TrimPrefix(hashtag, "#"),
+		TTL:      refreshInterval,
+	}
+	if raw := u.Query().Get("ttl"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return MastodonSource{}, fmt.Errorf("invalid ttl %q in Mastodon hashtag %q", raw, spec)
+		}
+		source.TTL = ttl
+	}
+	return source, nil
+}
+
+// Key identifies the hashtag timeline in the response cache
+func (s MastodonSource) Key() string {
+	return strings.ToLower(s.Instance + "/tags/" + s.Hashtag)
+}
+
+// URL returns the API URL of the hashtag's public timeline, media posts only
+func (s MastodonSource) URL() string {
+	query := url.Values{}
+	query.Set("limit", "40")
+	query.Set("only_media", "true")
+	return s.Instance + "/api/v1/timelines/tag/" + url.PathEscape(s.Hashtag) + "?" + query.Encode()
+}
+
+// SetMastodonSources replaces the Mastodon hashtags memes are pulled from and
+// forces a refresh on next fetch
+func (ms *Service) SetMastodonSources(sources []MastodonSource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.mastodonSources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// mastodonStatus is the subset of a Mastodon status we use
+type mastodonStatus struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+	Content     string    `json:"content"` // HTML
+	Sensitive   bool      `json:"sensitive"`
+	Favourites  int       `json:"favourites_count"`
+	Reblogs     int       `json:"reblogs_count"`
+	Attachments []struct {
+		Type        string `json:"type"` // image, gifv, video or audio
+		URL         string `json:"url"`
+		Description string `json:"description"`
+		Meta        struct {
+			Original struct {
+				Width    int     `json:"width"`
+				Height   int     `json:"height"`
+				Duration float64 `json:"duration"`
+			} `json:"original"`
+		} `json:"meta"`
+	} `json:"media_attachments"`
+}
+
+// mastodonProvider fetches the media posts of one hashtag timeline
+type mastodonProvider struct {
+	ms       *Service
+	source   MastodonSource
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p mastodonProvider) Name() string {
+	u, _ := url.Parse(p.source.Instance)
+	return "mastodon:#" + p.source.Hashtag + "@" + u.Host
+}
+
+// cacheKey implements cachedProvider
+func (p mastodonProvider) cacheKey() string {
+	return "response:mastodon:" + p.source.Key()
+}
+
+// Fetch implements Provider. Each post becomes one meme: a gallery of its
+// images, or its first GIF or video, which Mastodon serves as MP4
+func (p mastodonProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	var statuses []mastodonStatus
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "mastodon",
+		name:     p.Name(),
+		url:      p.source.URL(),
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(p.source.Instance)
+	memes := make([]Meme, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Sensitive {
+			continue
+		}
+		meme, ok := status.toMeme(p.source.Hashtag)
+		if !ok {
+			continue
+		}
+		meme.ID = fmt.Sprintf("mastodon_%s_%s", u.Host, status.ID)
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
+
+// toMeme maps a status and its attachments to a meme, false if it has no
+// picture or video
+func (s mastodonStatus) toMeme(hashtag string) (Meme, bool) {
+	meme := Meme{
+		Title:      htmlText(s.Content),
+		Score:      s.Favourites + s.Reblogs,
+		Permalink:  s.URL,
+		CreatedUTC: s.CreatedAt.Unix(),
+		MediaType:  MediaImage,
+	}
+
+	for _, media := range s.Attachments {
+		original := media.Meta.Original
+		switch media.Type {
+		case "image":
+			if meme.Video == nil {
+				meme.Images = append(meme.Images, Image{URL: media.URL, Width: original.Width, Height: original.Height})
+			}
+		case "gifv", "video":
+			if meme.Video == nil && len(meme.Images) == 0 {
+				meme.MediaType = MediaVideo
+				meme.Video = &Video{URL: media.URL, Duration: int(original.Duration), Width: original.Width, Height: original.Height}
+			}
+		}
+		if meme.Title == "" {
+			meme.Title = media.Description
+		}
+	}
+
+	switch {
+	case meme.Video != nil:
+		meme.URL = meme.Video.URL
+		meme.Width = meme.Video.Width
+		meme.Height = meme.Video.Height
+	case len(meme.Images) > 0:
+		meme.URL = meme.Images[0].URL
+		meme.Width = meme.Images[0].Width
+		meme.Height = meme.Images[0].Height
+		if len(meme.Images) == 1 {
+			meme.Images = nil
+		}
+		meme = withMediaType(meme)
+	default:
+		return Meme{}, false
+	}
+
+	if meme.Title == "" {
+		meme.Title = "#" + hashtag
+	}
+	meme.Tags = extractTags(meme.Title, "", hashtag)
+	return meme, true
+}
+
+// htmlText flattens post HTML to a single line of text, truncated to a title's length
+func htmlText(content string) string {
+	content = strings.NewReplacer("<br>", " ", "<br/>", " ", "<br />", " ", "</p>", " ").Replace(content)
+	text := strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(content, ""))), " ")
+	if utf8.RuneCountInString(text) > maxMastodonTitle {
+		text = string([]rune(text)[:maxMastodonTitle-1]) + "…"
+	}
+	return text
+}
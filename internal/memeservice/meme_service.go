@@ -1,98 +1,716 @@
 package memeservice
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	blocklist "github.com/elt0nxale/meme-tunnel-streamer/internal/blocklist"
+	cache "github.com/elt0nxale/meme-tunnel-streamer/internal/cache"
+)
+
+const (
+	// maxConcurrentFetches bounds the number of sources fetched in parallel
+	maxConcurrentFetches = 4
+
+	// maxConcurrentProbes bounds the number of in-flight HEAD requests during refresh
+	maxConcurrentProbes = 8
+
+	// refreshInterval is the minimum time between upstream fetches
+	refreshInterval = 5 * time.Minute
+
+	// sizeCacheTTL is how long probed image sizes are remembered
+	sizeCacheTTL = time.Hour
+
+	// DefaultMaxResponseSize bounds upstream listings unless changed with SetMaxResponseSize
+	DefaultMaxResponseSize = 8 << 20
 )
 
 // Meme represents the structure of a meme from Reddit
 type Meme struct {
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Size   int64  `json:"size,omitempty"` // Content-Length in bytes, 0 if unknown
+	Score  int    `json:"score"`
+
+	Subreddit  string   `json:"subreddit,omitempty"`
+	Permalink  string   `json:"permalink,omitempty"`
+	CreatedUTC int64    `json:"created_utc,omitempty"`
+	Tags       []string `json:"tags,omitempty"` // Derived from subreddit, flair and title
+
+	Images []Image `json:"images,omitempty"` // Every picture of a gallery post, URL is the first
+
+	MediaType string `json:"media_type"`      // image or video
+	Video     *Video `json:"video,omitempty"` // Set for video memes, whose URL is the MP4
+
+	Renditions []Rendition `json:"renditions,omitempty"` // Other encodings of a GIF, from GIF providers
+
+	Info *MemeInfo `json:"info,omitempty"` // Background on the format the title names, from the meme dataset
+}
+
+// Key uniquely identifies a meme across refreshes
+func (m Meme) Key() string {
+	if m.ID != "" {
+		return m.ID
+	}
+	return m.URL
+}
+
+// RedditPost represents the subset of a Reddit post we care about
+type RedditPost struct {
+	ID    string `json:"id"`
 	Title string `json:"title"`
 	URL   string `json:"url"`
+	Score int    `json:"score"`
+
+	Subreddit  string  `json:"subreddit"`
+	Permalink  string  `json:"permalink"`
+	CreatedUTC float64 `json:"created_utc"`
+	Flair      string  `json:"link_flair_text"`
+	PostHint   string  `json:"post_hint"`
+	Domain     string  `json:"domain"`
+
+	redditGallery
+	redditMedia
+
+	Preview struct {
+		Images []struct {
+			Source struct {
+				URL    string `json:"url"`
+				Width  int    `json:"width"`
+				Height int    `json:"height"`
+			} `json:"source"`
+			Variants previewVariants `json:"variants"`
+		} `json:"images"`
+	} `json:"preview"`
 }
 
 // RedditResponse represents the JSON response from Reddit
 type RedditResponse struct {
 	Data struct {
 		Children []struct {
-			Data Meme `json:"data"`
+			Data RedditPost `json:"data"`
 		} `json:"children"`
 	} `json:"data"`
 }
 
 // Service manages meme retrieval and distribution
 type Service struct {
-	memes     []Meme
-	mu        sync.RWMutex
-	lastFetch time.Time
+	memes      []Meme
+	mu         sync.RWMutex
+	lastFetch  time.Time
+	lastErr    error
+	trending   []Trending
+	onTrending func([]Trending)
+	onNewMemes func([]Meme)
+	cache      cache.Cache
+	sources    []Source // Subreddits; DefaultSource when empty and no other provider is configured
+	client     *http.Client
+	media      *http.Client // Fetches meme media for size probes and screening, nil to use client
+	baseURL    string
+	mirrors    []string // Fallback hosts for baseURL, tried in order
+	ranker     Ranker
+
+	lemmySources    []LemmySource    // Lemmy communities
+	mastodonSources []MastodonSource // Mastodon hashtag timelines
+	blueskySources  []BlueskySource  // Bluesky feeds, read through blueskyAppView
+	blueskyAppView  string
+	imgurSources    []ImgurSource // Imgur galleries, read from imgurAPIURL
+	imgurAPIURL     string
+	imgurClientID   string
+	giphySources    []GIFSource // Giphy lists, read from giphyAPIURL
+	giphyAPIURL     string
+	giphyAPIKey     string
+	tenorSources    []GIFSource // Tenor lists, read from tenorAPIURL
+	tenorAPIURL     string
+	tenorAPIKey     string
+	customSources   []CustomSource // JSON APIs mapped by config
+	localDir        string         // Directory of images streamed as memes, linked under localURL
+	localURL        string
+
+	dataset   *Dataset        // Meme formats titles are matched against, nil when unused
+	blocklist *blocklist.List // Content hashes kept out of the pool, nil when unused
+
+	motdDay string // Calendar day of the cached meme of the day
+	motd    Meme
+
+	refreshPaused bool               // Keep serving the cached pool without contacting upstream
+	inflight      singleflight.Group // Shares one refresh between concurrent FetchMemes callers
+
+	added []Meme // Memes merged into every pool, from AddMemes
+
+	maxPool  int                  // Most memes kept in the pool, 0 for no limit
+	rotation Rotation             // Which memes leave a full pool
+	entered  map[string]time.Time // When each pooled meme first entered it, by key
+
+	maxResponseBytes int64 // Largest upstream listing read, 0 for no limit
+
+	health         *healthTracker // Per-provider reliability used for failover
+	endpointHealth *healthTracker // Per-endpoint reliability, keyed by base URL
+	limiters       *limiterSet    // Per-provider request rate limits
 }
 
-// NewService creates a new meme service
-func NewService() *Service {
+// NewService creates a new meme service pulling from the given sources through a shared client
+func NewService(responseCache cache.Cache, sources []Source, client *http.Client) *Service {
+	if responseCache == nil {
+		responseCache = cache.NewMemoryCache()
+	}
+	if client == nil {
+		client = NewHTTPClient(DefaultClientConfig())
+	}
+
 	return &Service{
-		memes: []Meme{},
+		memes:    []Meme{},
+		cache:    responseCache,
+		sources:  sources,
+		client:   client,
+		baseURL:  DefaultBaseURL,
+		mirrors:  DefaultMirrors,
+		ranker:   RandomRanker{},
+		rotation: RotateFIFO,
+
+		maxResponseBytes: DefaultMaxResponseSize,
+		health:           newHealthTracker(),
+		endpointHealth:   newHealthTracker(),
+		limiters:         newLimiterSet(DefaultProviderRates),
 	}
 }
 
-// FetchMemes retrieves top memes from Reddit
-func (ms *Service) FetchMemes() error {
+// SetBaseURL points the service at a different Reddit-compatible host, such as a mock
+func (ms *Service) SetBaseURL(baseURL string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.baseURL = baseURL
+	ms.lastFetch = time.Time{}
+}
+
+// HTTPClient returns the client used for upstream requests
+func (ms *Service) HTTPClient() *http.Client {
+	return ms.client
+}
+
+// SetMediaClient sets the client that fetches meme media, e.g. one restricted
+// to public addresses since media URLs come from remote parties
+func (ms *Service) SetMediaClient(client *http.Client) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.media = client
+}
+
+// mediaClient returns the client for meme media; callers hold mu
+func (ms *Service) mediaClient() *http.Client {
+	if ms.media != nil {
+		return ms.media
+	}
+	return ms.client
+}
+
+// SetMaxResponseSize bounds how many bytes of an upstream listing are read,
+// failing the fetch beyond it; 0 removes the limit
+func (ms *Service) SetMaxResponseSize(maxBytes int64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.maxResponseBytes = maxBytes
+}
+
+// SetRanker changes how memes are chosen for streams
+func (ms *Service) SetRanker(ranker Ranker) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.ranker = ranker
+}
+
+// SetSources replaces the configured sources and forces a refresh on next fetch
+func (ms *Service) SetSources(sources []Source) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.sources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// SetProviderRates overrides the request rate limits of the named providers
+func (ms *Service) SetProviderRates(rates map[string]ProviderRate) {
+	ms.limiters.set(rates)
+}
+
+// SetRefreshPaused stops or restarts upstream refreshes, e.g. during quiet hours
+func (ms *Service) SetRefreshPaused(paused bool) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	// Limit fetch frequency
-	if time.Since(ms.lastFetch) < 5*time.Minute {
+	ms.refreshPaused = paused
+}
+
+// OnTrending registers a callback invoked when memes jump significantly in rank
+func (ms *Service) OnTrending(fn func([]Trending)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.onTrending = fn
+}
+
+// OnNewMemes registers a callback invoked with the memes that entered the
+// pool, after a refresh or AddMemes
+func (ms *Service) OnNewMemes(fn func([]Meme)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.onNewMemes = fn
+}
+
+// FetchMemes retrieves top memes from Reddit, aborting upstream calls when ctx is cancelled.
+// Concurrent callers share a single refresh, which runs to completion even if
+// the caller that started it goes away
+func (ms *Service) FetchMemes(ctx context.Context) error {
+	if !ms.stale() {
 		return nil
 	}
 
-	url := "https://www.reddit.com/r/memes.json?limit=26"
-	req, err := http.NewRequest("GET", url, nil)
+	refresh := ms.inflight.DoChan("refresh", func() (any, error) {
+		return nil, ms.refreshAndNotify(context.WithoutCancel(ctx))
+	})
+	select {
+	case result := <-refresh:
+		return result.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshAndNotify refreshes the pool, recording failures and announcing rank
+// jumps and new memes
+func (ms *Service) refreshAndNotify(ctx context.Context) error {
+	jumps, added, err := ms.refresh(ctx)
+	if err != nil {
+		ms.mu.Lock()
+		ms.lastErr = err
+		ms.mu.Unlock()
+		return err
+	}
+
+	// Notify outside the lock so callbacks may read from the service
+	ms.mu.RLock()
+	onTrending, onNewMemes := ms.onTrending, ms.onNewMemes
+	ms.mu.RUnlock()
+	if onTrending != nil && len(jumps) > 0 {
+		onTrending(jumps)
+	}
+	if onNewMemes != nil && len(added) > 0 {
+		onNewMemes(added)
+	}
+	return nil
+}
+
+// stale reports whether the pool is due for a refresh; a paused service
+// still fills an empty pool once
+func (ms *Service) stale() bool {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return time.Since(ms.lastFetch) >= refreshInterval && !(ms.refreshPaused && !ms.lastFetch.IsZero())
+}
+
+// ForceRefresh refetches every source now, skipping the refresh interval and response cache
+func (ms *Service) ForceRefresh(ctx context.Context) error {
+	ms.mu.RLock()
+	providers := ms.providers()
+	ms.mu.RUnlock()
+
+	// Invalidate outside the lock, as the cache may be remote
+	for _, provider := range providers {
+		cached, ok := provider.(cachedProvider)
+		if !ok {
+			continue
+		}
+		if err := ms.cache.Delete(ctx, cached.cacheKey()); err != nil {
+			log.Printf("Response cache invalidation failed: %v", err)
+		}
+	}
+
+	ms.mu.Lock()
+	ms.lastFetch = time.Time{}
+	ms.mu.Unlock()
+
+	return ms.FetchMemes(ctx)
+}
+
+// refresh fetches memes if the cache is stale and returns any rank jumps and
+// the memes new to the pool. The
+// lock is only held to read settings and to swap in the new pool, so streams
+// keep drawing from the current pool while upstream requests are in flight
+func (ms *Service) refresh(ctx context.Context) ([]Trending, []Meme, error) {
+	if !ms.stale() {
+		return nil, nil, nil
+	}
+
+	ms.mu.RLock()
+	client, media := ms.client, ms.mediaClient()
+	providers := ms.providers()
+	bl := ms.blocklist
+	ms.mu.RUnlock()
+
+	// Fetch every healthy provider concurrently, tolerating partial failures
+	results := make([][]Meme, len(providers))
+	errs := make([]error, len(providers))
+
+	var g errgroup.Group
+	g.SetLimit(maxConcurrentFetches)
+	for i, provider := range providers {
+		if !ms.health.allow(provider.Name(), time.Now()) {
+			errs[i] = errProviderUnhealthy
+			continue
+		}
+		g.Go(func() error {
+			start := time.Now()
+			results[i], errs[i] = provider.Fetch(ctx, client)
+			ms.health.record(provider.Name(), time.Since(start), errs[i], time.Now())
+			return nil
+		})
+	}
+	g.Wait()
+
+	// Merge what succeeded and report what failed
+	var memes []Meme
+	var failures []error
+	for i, provider := range providers {
+		if errs[i] != nil {
+			log.Printf("Provider %s failed: %v", provider.Name(), errs[i])
+			failures = append(failures, fmt.Errorf("%s: %w", provider.Name(), errs[i]))
+			continue
+		}
+		memes = append(memes, results[i]...)
+	}
+
+	// Enrich memes with image sizes, then drop known-bad images
+	if len(failures) < len(providers) {
+		ms.probeSizes(ctx, media, memes)
+		if bl != nil {
+			memes = ms.screen(ctx, media, bl, memes)
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if len(failures) == len(providers) {
+		// Fail over to the stale pool rather than erroring while it has memes
+		if len(ms.memes) > 0 {
+			ms.lastErr = errors.Join(failures...)
+			log.Printf("All providers failed, serving %d cached memes", len(ms.memes))
+			return nil, nil, nil
+		}
+		return nil, nil, errors.Join(failures...)
+	}
+
+	memes = ms.limitPool(append(memes, ms.added...), time.Now())
+	ms.enrich(memes)
+
+	// Compare against the previous pool to find trending memes
+	now := time.Now()
+	var jumps []Trending
+	ms.trending, jumps = computeTrending(ms.memes, memes, now.Sub(ms.lastFetch))
+	added := newMemes(ms.memes, memes)
+
+	ms.memes = memes
+	ms.lastFetch = now
+	ms.lastErr = errors.Join(failures...)
+	return jumps, added, nil
+}
+
+// newMemes returns the memes of current missing from previous
+func newMemes(previous, current []Meme) []Meme {
+	seen := make(map[string]bool, len(previous))
+	for _, meme := range previous {
+		seen[meme.Key()] = true
+	}
+	var added []Meme
+	for _, meme := range current {
+		if !seen[meme.Key()] {
+			added = append(added, meme)
+		}
+	}
+	return added
+}
+
+// fetchSource retrieves and parses the memes of a single source
+func (ms *Service) fetchSource(ctx context.Context, client *http.Client, p redditProvider) ([]Meme, error) {
+	redditResp, err := ms.fetchListing(ctx, client, p)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, err
+	}
+
+	// Extract memes
+	memes := make([]Meme, 0, len(redditResp.Data.Children))
+	for _, child := range redditResp.Data.Children {
+		memes = append(memes, child.Data.toMeme())
+	}
+	return memes, nil
+}
+
+// fetchListing returns the upstream listing for a source, consulting the
+// cache first and falling back across endpoints. Only the fields we use are cached
+func (ms *Service) fetchListing(ctx context.Context, client *http.Client, p redditProvider) (RedditResponse, error) {
+	source := p.source
+	key := p.cacheKey()
+	if body, found, err := ms.cache.Get(ctx, key); err != nil {
+		log.Printf("Response cache lookup failed: %v", err)
+	} else if found {
+		var cached RedditResponse
+		if err := json.Unmarshal(body, &cached); err == nil {
+			return cached, nil
+		}
+		log.Printf("Discarding unreadable cached response for r/%s", source.Subreddit)
+	}
+
+	redditResp, err := ms.fetchFromEndpoints(ctx, client, p)
+	if err != nil {
+		return RedditResponse{}, err
+	}
+
+	if encoded, err := json.Marshal(redditResp); err != nil {
+		log.Printf("Response cache encoding failed: %v", err)
+	} else if err := ms.cache.Set(ctx, key, encoded, source.TTL); err != nil {
+		log.Printf("Response cache store failed: %v", err)
+	}
+	return redditResp, nil
+}
+
+// fetchFrom requests a source's listing from one Reddit-compatible endpoint,
+// decoding the response as it streams in, up to the provider's size limit
+func (ms *Service) fetchFrom(ctx context.Context, client *http.Client, p redditProvider, endpoint string) (RedditResponse, error) {
+	source := p.source
+	req, err := http.NewRequestWithContext(ctx, "GET", source.URL(endpoint), nil)
+	if err != nil {
+		return RedditResponse{}, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	// Set User-Agent to prevent Reddit from blocking
 	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
 
-	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch memes: %v", err)
+		return RedditResponse{}, fmt.Errorf("failed to fetch memes from r/%s: %v", source.Subreddit, err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+	if resp.StatusCode != http.StatusOK {
+		return RedditResponse{}, fmt.Errorf("unexpected status fetching r/%s: %s", source.Subreddit, resp.Status)
+	}
+	if p.maxBytes > 0 && resp.ContentLength > p.maxBytes {
+		return RedditResponse{}, fmt.Errorf("response from r/%s is %d bytes, over the %d byte limit", source.Subreddit, resp.ContentLength, p.maxBytes)
+	}
+
+	var body io.Reader = resp.Body
+	if p.maxBytes > 0 {
+		body = http.MaxBytesReader(nil, resp.Body, p.maxBytes)
 	}
 
 	var redditResp RedditResponse
-	if err := json.Unmarshal(body, &redditResp); err != nil {
-		return fmt.Errorf("failed to parse JSON: %v", err)
+	if err := json.NewDecoder(body).Decode(&redditResp); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return RedditResponse{}, fmt.Errorf("response from r/%s exceeds the %d byte limit", source.Subreddit, tooLarge.Limit)
+		}
+		return RedditResponse{}, fmt.Errorf("failed to parse JSON from r/%s: %v", source.Subreddit, err)
 	}
+	return redditResp, nil
+}
 
-	// Extract memes
-	ms.memes = make([]Meme, 0, len(redditResp.Data.Children))
-	for _, child := range redditResp.Data.Children {
-		ms.memes = append(ms.memes, child.Data)
+// toMeme converts a Reddit post into a Meme, using preview data for dimensions
+func (p RedditPost) toMeme() Meme {
+	meme := Meme{
+		ID:         p.ID,
+		Title:      p.Title,
+		URL:        p.URL,
+		Score:      p.Score,
+		Subreddit:  p.Subreddit,
+		CreatedUTC: int64(p.CreatedUTC),
+		Tags:       extractTags(p.Title, p.Flair, p.Subreddit),
+		MediaType:  MediaImage,
+	}
+	if p.Permalink != "" {
+		meme.Permalink = "https://www.reddit.com" + p.Permalink
+	}
+	if len(p.Preview.Images) > 0 {
+		source := p.Preview.Images[0].Source
+		meme.Width = source.Width
+		meme.Height = source.Height
 	}
 
-	ms.lastFetch = time.Now()
-	return nil
+	// Galleries link to reddit.com, so point at their first image instead
+	if images := p.galleryImages(); len(images) > 0 {
+		meme.Images = images
+		meme.URL = images[0].URL
+		meme.Width = images[0].Width
+		meme.Height = images[0].Height
+	}
+
+	// v.redd.it links are HTML pages; point at the playable MP4 instead
+	if video := p.video(p.URL); video != nil {
+		meme.MediaType = MediaVideo
+		meme.Video = video
+		meme.URL = video.URL
+		meme.Width = video.Width
+		meme.Height = video.Height
+	} else if url, ok := p.gifURL(meme.URL); ok {
+		meme.MediaType = MediaGIF
+		meme.URL = url
+	}
+	return meme
+}
+
+// probeSizes issues HEAD requests with bounded concurrency to fill in image sizes
+func (ms *Service) probeSizes(ctx context.Context, client *http.Client, memes []Meme) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentProbes)
+
+	for i := range memes {
+		// Providers that report sizes, such as Imgur, need no probe
+		if memes[i].URL == "" || memes[i].Size > 0 {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(meme *Meme) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			key := "size:" + meme.URL
+			if cached, found, _ := ms.cache.Get(ctx, key); found {
+				if size, err := strconv.ParseInt(string(cached), 10, 64); err == nil {
+					meme.Size = size
+					return
+				}
+			}
+
+			size, err := probeSize(ctx, client, meme.URL)
+			if err != nil {
+				return
+			}
+			meme.Size = size
+			ms.cache.Set(ctx, key, []byte(strconv.FormatInt(size, 10)), sizeCacheTTL)
+		}(&memes[i])
+	}
+
+	wg.Wait()
+}
+
+// probeSize returns the Content-Length reported for a URL
+func probeSize(ctx context.Context, client *http.Client, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe %s: %v", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0, fmt.Errorf("no content length for %s", url)
+	}
+	return resp.ContentLength, nil
+}
+
+// GetRandomMeme returns a meme passing filter, chosen by the configured ranker
+// using the given random source; recent holds keys the stream saw lately
+func (ms *Service) GetRandomMeme(rng *rand.Rand, recent map[string]bool, filter Filter) Meme {
+	return ms.GetRankedMeme(nil, rng, recent, filter)
 }
 
-// GetRandomMeme returns a random meme
-func (ms *Service) GetRandomMeme() Meme {
+// GetRankedMeme is GetRandomMeme choosing with ranker instead of the
+// service's, e.g. for an experiment cohort; nil uses the service's
+func (ms *Service) GetRankedMeme(ranker Ranker, rng *rand.Rand, recent map[string]bool, filter Filter) Meme {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	if len(ms.memes) == 0 {
+	if ranker == nil {
+		ranker = ms.ranker
+	}
+
+	pool := ms.memes
+	if !filter.Empty() {
+		pool = filterMemes(pool, filter)
+	}
+	if len(pool) == 0 {
 		return Meme{Title: "No memes available", URL: ""}
 	}
 
-	return ms.memes[rand.Intn(len(ms.memes))]
+	return pool[ranker.Pick(rng, pool, recent, time.Now())]
+}
+
+// GetMemes returns a copy of the current meme pool
+func (ms *Service) GetMemes() []Meme {
+	return ms.ListMemes(Filter{})
+}
+
+// ListMemes returns a copy of the memes in the pool passing filter
+func (ms *Service) ListMemes(filter Filter) []Meme {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return filterMemes(ms.memes, filter)
+}
+
+// filterMemes returns a new slice of the memes passing filter
+func filterMemes(memes []Meme, filter Filter) []Meme {
+	matched := make([]Meme, 0, len(memes))
+	for _, meme := range memes {
+		if filter.Matches(meme) {
+			matched = append(matched, meme)
+		}
+	}
+	return matched
+}
+
+// PoolSize returns the number of cached memes
+func (ms *Service) PoolSize() int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return len(ms.memes)
+}
+
+// ProviderHealth reports the reliability of every configured provider
+func (ms *Service) ProviderHealth() []ProviderHealth {
+	ms.mu.RLock()
+	providers := ms.providers()
+	ms.mu.RUnlock()
+
+	names := make([]string, len(providers))
+	for i, provider := range providers {
+		names[i] = provider.Name()
+	}
+	return ms.health.snapshot(names)
+}
+
+// FetchStatus returns the time of the last successful fetch and the most recent fetch error
+func (ms *Service) FetchStatus() (time.Time, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.lastFetch, ms.lastErr
 }
@@ -0,0 +1,92 @@
+package memeservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMirrors are tried in order when the Reddit host fails a listing request
+var DefaultMirrors = []string{"https://old.reddit.com"}
+
+// errEndpointsUnhealthy is returned when every endpoint is cooling down
+var errEndpointsUnhealthy = errors.New("every endpoint unhealthy, skipped until retry")
+
+// SetMirrors replaces the Reddit-compatible hosts that listings fall back to,
+// in order, when the base URL blocks, rate-limits or fails a request
+func (ms *Service) SetMirrors(mirrors []string) error {
+	cleaned := make([]string, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid mirror %q, expected an http or https URL", mirror)
+		}
+		cleaned = append(cleaned, strings.TrimSuffix(mirror, "/"))
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.mirrors = cleaned
+	return nil
+}
+
+// endpoints returns the base URL followed by its mirrors; callers must hold ms.mu
+func (ms *Service) endpoints() []string {
+	endpoints := []string{strings.TrimSuffix(ms.baseURL, "/")}
+	for _, mirror := range ms.mirrors {
+		if mirror != endpoints[0] {
+			endpoints = append(endpoints, mirror)
+		}
+	}
+	return endpoints
+}
+
+// EndpointHealth reports the reliability of the base URL and every mirror
+func (ms *Service) EndpointHealth() []ProviderHealth {
+	ms.mu.RLock()
+	endpoints := ms.endpoints()
+	ms.mu.RUnlock()
+
+	return ms.endpointHealth.snapshot(endpoints)
+}
+
+// fetchFromEndpoints requests a listing from each healthy endpoint of a
+// provider in turn, returning the first that succeeds
+func (ms *Service) fetchFromEndpoints(ctx context.Context, client *http.Client, p redditProvider) (RedditResponse, error) {
+	var failures []error
+	for i, endpoint := range p.endpoints {
+		if !ms.endpointHealth.allow(endpoint, time.Now()) {
+			continue
+		}
+
+		// Only requests that reach Reddit count against its limit
+		if err := ms.limiters.wait(ctx, "reddit"); err != nil {
+			return RedditResponse{}, err
+		}
+
+		start := time.Now()
+		listing, err := ms.fetchFrom(ctx, client, p, endpoint)
+		if ctx.Err() != nil {
+			return RedditResponse{}, err
+		}
+		ms.endpointHealth.record(endpoint, time.Since(start), err, time.Now())
+		if err == nil {
+			if i > 0 {
+				log.Printf("Fetched r/%s from mirror %s", p.source.Subreddit, endpoint)
+			}
+			return listing, nil
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", endpoint, err))
+	}
+
+	if len(failures) == 0 {
+		return RedditResponse{}, errEndpointsUnhealthy
+	}
+	return RedditResponse{}, errors.Join(failures...)
+}
@@ -0,0 +1,36 @@
+package memeservice
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// MemeOfTheDay deterministically picks one meme for a calendar day such as
+// "2026-10-17". Every meme is scored by hashing the day with its key and the
+// highest score wins, so instances with the same pool agree without
+// coordinating. The first pick of a day is remembered so refreshes do not
+// change it.
+func (ms *Service) MemeOfTheDay(day string) (Meme, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.motdDay == day {
+		return ms.motd, true
+	}
+	if len(ms.memes) == 0 {
+		return Meme{}, false
+	}
+
+	var best Meme
+	var bestScore uint64
+	for i, meme := range ms.memes {
+		sum := sha256.Sum256([]byte(day + "\x00" + meme.Key()))
+		score := binary.BigEndian.Uint64(sum[:8])
+		if i == 0 || score > bestScore {
+			best, bestScore = meme, score
+		}
+	}
+
+	ms.motdDay, ms.motd = day, best
+	return best, true
+}
@@ -0,0 +1,89 @@
+package memeservice
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Rotation decides which memes leave a pool that outgrew its size limit
+type Rotation string
+
+// Rotation policies
+const (
+	RotateFIFO        Rotation = "fifo"         // Drop the memes that entered the pool first
+	RotateLowestScore Rotation = "lowest-score" // Drop the lowest-scored memes
+)
+
+// ParseRotation returns the rotation policy registered under name
+func ParseRotation(name string) (Rotation, error) {
+	switch rotation := Rotation(name); rotation {
+	case RotateFIFO, RotateLowestScore:
+		return rotation, nil
+	default:
+		return "", fmt.Errorf("unknown pool rotation %q, expected fifo or lowest-score", name)
+	}
+}
+
+// SetPoolLimit caps the pool at max memes, zero for no limit, evicting with
+// rotation whenever a refresh or AddMemes would exceed it
+func (ms *Service) SetPoolLimit(max int, rotation Rotation) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.maxPool = max
+	ms.rotation = rotation
+	ms.memes = ms.limitPool(ms.memes, time.Now())
+}
+
+// limitPool records when each meme entered the pool and returns the memes
+// kept under the size limit, in their original order; callers hold ms.mu
+func (ms *Service) limitPool(memes []Meme, now time.Time) []Meme {
+	entered := make(map[string]time.Time, len(memes))
+	for _, meme := range memes {
+		key := meme.Key()
+		if at, ok := ms.entered[key]; ok {
+			entered[key] = at
+		} else {
+			entered[key] = now
+		}
+	}
+	ms.entered = entered
+
+	if ms.maxPool <= 0 || len(memes) <= ms.maxPool {
+		return memes
+	}
+
+	// Rank memes from first to last to evict; ties leave from the end of the
+	// listing, so a fresh pool keeps the top of it
+	order := make([]int, len(memes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		var c int
+		if ms.rotation == RotateLowestScore {
+			c = cmp.Compare(memes[a].Score, memes[b].Score)
+		} else {
+			c = entered[memes[a].Key()].Compare(entered[memes[b].Key()])
+		}
+		return cmp.Or(c, cmp.Compare(b, a))
+	})
+
+	evicted := make(map[int]bool, len(memes)-ms.maxPool)
+	for _, i := range order[:len(memes)-ms.maxPool] {
+		evicted[i] = true
+		delete(ms.entered, memes[i].Key())
+	}
+
+	kept := make([]Meme, 0, ms.maxPool)
+	for i, meme := range memes {
+		if !evicted[i] {
+			kept = append(kept, meme)
+		}
+	}
+	log.Printf("Pool over its limit of %d, evicted %d memes (%s)", ms.maxPool, len(evicted), ms.rotation)
+	return kept
+}
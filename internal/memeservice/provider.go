@@ -0,0 +1,208 @@
+package memeservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Provider fetches memes from a single upstream
+type Provider interface {
+	// Name identifies the provider in health reports and logs
+	Name() string
+
+	// Fetch returns the provider's current memes
+	Fetch(ctx context.Context, client *http.Client) ([]Meme, error)
+}
+
+// redditProvider fetches one subreddit source through the service's response cache
+type redditProvider struct {
+	ms        *Service
+	source    Source
+	endpoints []string // Reddit-compatible hosts in fallback order, read when the provider was built
+	maxBytes  int64    // Largest listing read, 0 for no limit
+}
+
+// Name implements Provider
+func (p redditProvider) Name() string {
+	name := "reddit:r/" + p.source.Subreddit + "/" + p.source.Sort
+	if p.source.Time != "" {
+		name += "/" + p.source.Time
+	}
+	return name
+}
+
+// Fetch implements Provider
+func (p redditProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	return p.ms.fetchSource(ctx, client, p)
+}
+
+// cacheKey implements cachedProvider
+func (p redditProvider) cacheKey() string {
+	return "response:" + p.source.Key()
+}
+
+// cachedProvider is a provider whose upstream responses go through the
+// response cache under a single key, which ForceRefresh invalidates
+type cachedProvider interface {
+	cacheKey() string
+}
+
+// providers returns the configured providers; callers must hold ms.mu
+func (ms *Service) providers() []Provider {
+	var others []Provider
+	for _, source := range ms.lemmySources {
+		others = append(others, lemmyProvider{ms: ms, source: source, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.mastodonSources {
+		others = append(others, mastodonProvider{ms: ms, source: source, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.blueskySources {
+		others = append(others, blueskyProvider{ms: ms, source: source, appView: ms.blueskyAppView, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.imgurSources {
+		others = append(others, imgurProvider{ms: ms, source: source, apiURL: ms.imgurAPIURL, clientID: ms.imgurClientID, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.giphySources {
+		others = append(others, giphyProvider{ms: ms, source: source, apiURL: ms.giphyAPIURL, apiKey: ms.giphyAPIKey, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.tenorSources {
+		others = append(others, tenorProvider{ms: ms, source: source, apiURL: ms.tenorAPIURL, apiKey: ms.tenorAPIKey, maxBytes: ms.maxResponseBytes})
+	}
+	for _, source := range ms.customSources {
+		others = append(others, customProvider{ms: ms, source: source, maxBytes: ms.maxResponseBytes})
+	}
+	if ms.localDir != "" {
+		others = append(others, localProvider{dir: ms.localDir, urlPrefix: ms.localURL})
+	}
+
+	// The default subreddit only fills in when nothing else is configured
+	sources := ms.sources
+	if len(sources) == 0 && len(others) == 0 {
+		sources = []Source{DefaultSource}
+	}
+
+	providers := make([]Provider, 0, len(sources)+len(others))
+	endpoints := ms.endpoints()
+	for _, source := range sources {
+		providers = append(providers, redditProvider{ms: ms, source: source, endpoints: endpoints, maxBytes: ms.maxResponseBytes})
+	}
+	return append(providers, others...)
+}
+
+// jsonRequest describes an upstream JSON document fetched through the response cache
+type jsonRequest struct {
+	kind     string // Rate limit bucket, e.g. lemmy
+	name     string // Upstream named in errors
+	url      string
+	header   http.Header   // Extra request headers, e.g. credentials
+	cacheKey string        // Response cache key
+	ttl      time.Duration // How long the decoded document stays cached
+	maxBytes int64         // Largest response read, 0 for no limit
+}
+
+// fetchJSON decodes an upstream JSON document into v, consulting the response
+// cache first and waiting on the kind's rate limit otherwise. Like Reddit
+// listings, responses are decoded as they stream in and only the fields of v
+// are cached
+func (ms *Service) fetchJSON(ctx context.Context, client *http.Client, jr jsonRequest, v any) error {
+	if body, found, err := ms.cache.Get(ctx, jr.cacheKey); err != nil {
+		log.Printf("Response cache lookup failed: %v", err)
+	} else if found {
+		if err := json.Unmarshal(body, v); err == nil {
+			return nil
+		}
+		log.Printf("Discarding unreadable cached response for %s", jr.name)
+	}
+
+	if err := ms.limiters.wait(ctx, jr.kind); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", jr.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+	req.Header.Set("Accept", "application/json")
+	for name, values := range jr.header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Leave out the URL, which may carry an API key, as errors reach /api/stats
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to fetch %s: %v", jr.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status fetching %s: %s", jr.name, resp.Status)
+	}
+	if jr.maxBytes > 0 && resp.ContentLength > jr.maxBytes {
+		return fmt.Errorf("response from %s is %d bytes, over the %d byte limit", jr.name, resp.ContentLength, jr.maxBytes)
+	}
+
+	var body io.Reader = resp.Body
+	if jr.maxBytes > 0 {
+		body = http.MaxBytesReader(nil, resp.Body, jr.maxBytes)
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("response from %s exceeds the %d byte limit", jr.name, tooLarge.Limit)
+		}
+		return fmt.Errorf("failed to parse JSON from %s: %v", jr.name, err)
+	}
+
+	if encoded, err := json.Marshal(v); err != nil {
+		log.Printf("Response cache encoding failed: %v", err)
+	} else if err := ms.cache.Set(ctx, jr.cacheKey, encoded, jr.ttl); err != nil {
+		log.Printf("Response cache store failed: %v", err)
+	}
+	return nil
+}
+
+// imageExtensions are the file types that non-Reddit providers accept as memes
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".gifv", ".webp"}
+
+// isImageURL reports whether a link points straight at a picture or animation,
+// judged by its extension or a GIF host
+func isImageURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if slices.Contains(imageExtensions, strings.ToLower(path.Ext(u.Path))) {
+		return true
+	}
+	return slices.ContainsFunc(gifDomains, func(domain string) bool { return strings.EqualFold(u.Hostname(), domain) })
+}
+
+// withMediaType marks a meme linking to an animation as a GIF, rewriting
+// .gifv links like Reddit posts; everything else stays an image
+func withMediaType(meme Meme) Meme {
+	meme.MediaType = MediaImage
+	host := ""
+	if u, err := url.Parse(meme.URL); err == nil {
+		host = u.Hostname()
+	}
+	if gif, ok := (RedditPost{Domain: host}).gifURL(meme.URL); ok {
+		meme.MediaType = MediaGIF
+		meme.URL = gif
+	}
+	return meme
+}
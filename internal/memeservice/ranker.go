@@ -0,0 +1,119 @@
+package memeservice
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Ranker chooses the next meme for a stream from the pool
+type Ranker interface {
+	// Pick returns the index into pool of the chosen meme; pool is never empty.
+	// recent holds keys the stream saw lately.
+	Pick(rng *rand.Rand, pool []Meme, recent map[string]bool, now time.Time) int
+}
+
+// ParseRanker returns the ranker registered under name
+func ParseRanker(name string) (Ranker, error) {
+	switch name {
+	case "random":
+		return RandomRanker{}, nil
+	case "composite":
+		return DefaultCompositeRanker(), nil
+	default:
+		return nil, fmt.Errorf("unknown ranker %q, expected random or composite", name)
+	}
+}
+
+// RandomRanker picks uniformly among memes not sent recently, falling back to
+// the whole pool when every meme was
+type RandomRanker struct{}
+
+// Pick implements Ranker
+func (RandomRanker) Pick(rng *rand.Rand, pool []Meme, recent map[string]bool, now time.Time) int {
+	candidates := make([]int, 0, len(pool))
+	for i, meme := range pool {
+		if !recent[meme.Key()] {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return rng.Intn(len(pool))
+	}
+
+	return candidates[rng.Intn(len(candidates))]
+}
+
+// CompositeRanker samples memes weighted by score and post age, penalising
+// recently sent memes instead of excluding them outright
+type CompositeRanker struct {
+	ScoreWeight   float64       // Weight of the log-scaled score, relative to the pool's best
+	RecencyWeight float64       // Weight of post freshness
+	HalfLife      time.Duration // Post age at which freshness halves
+	RecentPenalty float64       // Multiplier applied to recently sent memes (0-1)
+	Floor         float64       // Minimum weight so every meme stays reachable
+}
+
+// DefaultCompositeRanker returns a ranker balancing score and freshness
+func DefaultCompositeRanker() CompositeRanker {
+	return CompositeRanker{
+		ScoreWeight:   1,
+		RecencyWeight: 1,
+		HalfLife:      12 * time.Hour,
+		RecentPenalty: 0.02,
+		Floor:         0.05,
+	}
+}
+
+// Pick implements Ranker
+func (cr CompositeRanker) Pick(rng *rand.Rand, pool []Meme, recent map[string]bool, now time.Time) int {
+	maxScore := 0
+	for _, meme := range pool {
+		maxScore = max(maxScore, meme.Score)
+	}
+
+	weights := make([]float64, len(pool))
+	total := 0.0
+	for i, meme := range pool {
+		weight := cr.Floor + cr.ScoreWeight*scoreComponent(meme.Score, maxScore) +
+			cr.RecencyWeight*cr.recencyComponent(meme.CreatedUTC, now)
+		if recent[meme.Key()] {
+			weight *= cr.RecentPenalty
+		}
+		weights[i] = weight
+		total += weight
+	}
+
+	// Weighted sampling
+	target := rng.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return i
+		}
+	}
+	return len(pool) - 1
+}
+
+// scoreComponent scales a score logarithmically to 0-1 against the pool's best
+func scoreComponent(score, maxScore int) float64 {
+	if score <= 0 || maxScore <= 0 {
+		return 0
+	}
+	return math.Log1p(float64(score)) / math.Log1p(float64(maxScore))
+}
+
+// recencyComponent decays from 1 for a brand new post, halving every HalfLife;
+// posts without a creation time count as half fresh
+func (cr CompositeRanker) recencyComponent(createdUTC int64, now time.Time) float64 {
+	if createdUTC == 0 || cr.HalfLife <= 0 {
+		return 0.5
+	}
+
+	age := now.Sub(time.Unix(createdUTC, 0))
+	if age < 0 {
+		age = 0
+	}
+	return math.Exp2(-float64(age) / float64(cr.HalfLife))
+}
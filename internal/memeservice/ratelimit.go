@@ -0,0 +1,90 @@
+package memeservice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultProviderRates keep refreshes well under upstream limits when none are configured
+var DefaultProviderRates = map[string]ProviderRate{
+	"reddit": {RPS: 0.5, Burst: 4},
+	"imgur":  {RPS: 0.1, Burst: 4},   // 12,500 requests a day per client ID
+	"giphy":  {RPS: 0.025, Burst: 4}, // 100 requests an hour with a beta key
+}
+
+// ProviderRate is a token-bucket limit for requests to one provider
+type ProviderRate struct {
+	RPS   float64
+	Burst int
+}
+
+// ParseProviderRate parses a "provider=rps[:burst]" limit, e.g. "reddit=0.5:4"
+func ParseProviderRate(spec string) (string, ProviderRate, error) {
+	provider, limit, found := strings.Cut(spec, "=")
+	if !found || provider == "" {
+		return "", ProviderRate{}, fmt.Errorf("invalid provider rate %q, expected provider=rps[:burst]", spec)
+	}
+
+	rpsRaw, burstRaw, hasBurst := strings.Cut(limit, ":")
+	rps, err := strconv.ParseFloat(rpsRaw, 64)
+	if err != nil || rps <= 0 {
+		return "", ProviderRate{}, fmt.Errorf("invalid rate %q for provider %s", rpsRaw, provider)
+	}
+
+	pr := ProviderRate{RPS: rps, Burst: max(1, int(rps))}
+	if hasBurst {
+		burst, err := strconv.Atoi(burstRaw)
+		if err != nil || burst <= 0 {
+			return "", ProviderRate{}, fmt.Errorf("invalid burst %q for provider %s", burstRaw, provider)
+		}
+		pr.Burst = burst
+	}
+	return provider, pr, nil
+}
+
+// limiterSet holds one token bucket per provider kind
+type limiterSet struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newLimiterSet(rates map[string]ProviderRate) *limiterSet {
+	ls := &limiterSet{limiters: make(map[string]*rate.Limiter)}
+	ls.set(rates)
+	return ls
+}
+
+// set replaces the configured limits, keeping unchanged buckets
+func (ls *limiterSet) set(rates map[string]ProviderRate) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for provider, pr := range rates {
+		if limiter, exists := ls.limiters[provider]; exists {
+			limiter.SetLimit(rate.Limit(pr.RPS))
+			limiter.SetBurst(pr.Burst)
+			continue
+		}
+		ls.limiters[provider] = rate.NewLimiter(rate.Limit(pr.RPS), pr.Burst)
+	}
+}
+
+// wait blocks until a request to provider is allowed; providers without a limit pass immediately
+func (ls *limiterSet) wait(ctx context.Context, provider string) error {
+	ls.mu.Lock()
+	limiter := ls.limiters[provider]
+	ls.mu.Unlock()
+
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("%s rate limit: %v", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package memeservice
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Source describes a single subreddit listing to pull memes from
+type Source struct {
+	Subreddit string
+	Sort      string        // hot, new, top, rising
+	Time      string        // hour, day, week, month, year, all (top only)
+	TTL       time.Duration // How long the raw response stays cached
+}
+
+// DefaultBaseURL is the Reddit host sources are fetched from
+const DefaultBaseURL = "https://www.reddit.com"
+
+// DefaultSource is used when no sources are configured
+var DefaultSource = Source{Subreddit: "memes", Sort: "hot", TTL: refreshInterval}
+
+// ParseSource parses a "subreddit[:sort[:time[:ttl]]]" source specification
+func ParseSource(spec string) (Source, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 4 || parts[0] == "" {
+		return Source{}, fmt.Errorf("invalid source %q, expected subreddit[:sort[:time[:ttl]]]", spec)
+	}
+
+	source := Source{Subreddit: parts[0], Sort: "hot", TTL: refreshInterval}
+	if len(parts) > 1 && parts[1] != "" {
+		switch parts[1] {
+		case "hot", "new", "top", "rising":
+			source.Sort = parts[1]
+		default:
+			return Source{}, fmt.Errorf("invalid sort %q in source %q", parts[1], spec)
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		switch parts[2] {
+		case "hour", "day", "week", "month", "year", "all":
+			source.Time = parts[2]
+		default:
+			return Source{}, fmt.Errorf("invalid time %q in source %q", parts[2], spec)
+		}
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		ttl, err := time.ParseDuration(parts[3])
+		if err != nil || ttl <= 0 {
+			return Source{}, fmt.Errorf("invalid ttl %q in source %q", parts[3], spec)
+		}
+		source.TTL = ttl
+	}
+
+	return source, nil
+}
+
+// Key identifies the source in the response cache
+func (s Source) Key() string {
+	return fmt.Sprintf("%s/%s/%s", strings.ToLower(s.Subreddit), s.Sort, s.Time)
+}
+
+// URL returns the JSON listing URL for the source on the given Reddit host
+func (s Source) URL(baseURL string) string {
+	query := url.Values{}
+	query.Set("limit", "26")
+	if s.Time != "" {
+		query.Set("t", s.Time)
+	}
+
+	return fmt.Sprintf("%s/r/%s/%s.json?%s",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(s.Subreddit), s.Sort, query.Encode())
+}
+
+// String returns the source in its specification form
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%s:%s:%s", s.Subreddit, s.Sort, s.Time, s.TTL)
+}
@@ -0,0 +1,53 @@
+package memeservice
+
+import (
+	"slices"
+	"time"
+)
+
+// NewSubmission builds a meme posted by a user rather than fetched from a
+// provider, tagged by its title and typed by its URL like Reddit posts
+func NewSubmission(title, url string) Meme {
+	meme := Meme{
+		Title:     title,
+		URL:       url,
+		Tags:      extractTags(title, "", ""),
+		MediaType: MediaImage,
+	}
+	if gif, ok := (RedditPost{}).gifURL(url); ok {
+		meme.MediaType = MediaGIF
+		meme.URL = gif
+	}
+	return meme
+}
+
+// AddMemes puts memes that no provider returns, such as user submissions,
+// into the pool now and keeps them in it across refreshes, subject to the
+// pool limit
+func (ms *Service) AddMemes(memes ...Meme) {
+	ms.mu.Lock()
+	memes = slices.Clone(memes)
+	ms.enrich(memes)
+	ms.added = append(ms.added, memes...)
+	ms.memes = ms.limitPool(append(ms.memes[:len(ms.memes):len(ms.memes)], memes...), time.Now())
+	onNewMemes := ms.onNewMemes
+	ms.mu.Unlock()
+
+	// Notify outside the lock so callbacks may read from the service
+	if onNewMemes != nil && len(memes) > 0 {
+		onNewMemes(memes)
+	}
+}
+
+// RemoveMemes takes memes added with AddMemes out of the pool by key
+func (ms *Service) RemoveMemes(keys ...string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	removed := func(m Meme) bool { return slices.Contains(keys, m.Key()) }
+	ms.added = slices.DeleteFunc(slices.Clone(ms.added), removed)
+	ms.memes = slices.DeleteFunc(slices.Clone(ms.memes), removed)
+	for _, key := range keys {
+		delete(ms.entered, key)
+	}
+}
@@ -0,0 +1,70 @@
+package memeservice
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxTags bounds how many tags a meme carries
+const maxTags = 10
+
+// stopWords are common title words that make useless tags
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "you": true, "your": true, "are": true,
+	"was": true, "this": true, "that": true, "with": true, "when": true, "what": true,
+	"have": true, "has": true, "his": true, "her": true, "they": true, "them": true,
+	"but": true, "not": true, "just": true, "its": true, "from": true, "who": true,
+	"how": true, "why": true, "all": true, "can": true, "one": true, "out": true,
+	"get": true, "got": true, "about": true, "into": true, "than": true, "then": true,
+	"there": true, "their": true, "our": true, "will": true, "would": true, "like": true,
+	"me": true, "my": true, "meme": true, "memes": true,
+}
+
+// extractTags derives tags from the subreddit, flair and title words
+func extractTags(title, flair, subreddit string) []string {
+	var tags []string
+	add := func(tag string) {
+		if tag != "" && len(tags) < maxTags && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	add(NormalizeTag(subreddit))
+	for _, word := range tokenize(flair) {
+		add(NormalizeTag(word))
+	}
+	for _, word := range tokenize(title) {
+		if len(word) >= 3 && !stopWords[word] {
+			add(NormalizeTag(word))
+		}
+	}
+	return tags
+}
+
+// tokenize splits text into lowercase letter and digit runs
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NormalizeTag lowercases a tag and strips a plural "s" so "cats" matches "cat"
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(tag, "#")))
+	if len(tag) > 3 && strings.HasSuffix(tag, "s") && !strings.HasSuffix(tag, "ss") {
+		tag = strings.TrimSuffix(tag, "s")
+	}
+	return tag
+}
+
+// ParseTags parses a comma-separated ?tags= value into normalized tags
+func ParseTags(raw string) []string {
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		if tag = NormalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
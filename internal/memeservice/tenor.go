@@ -0,0 +1,136 @@
+package memeservice
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultTenorAPIURL is the Tenor API GIFs are read from
+const DefaultTenorAPIURL = "https://tenor.googleapis.com/v2"
+
+// tenorFormats are the Tenor media formats requested and copied into a meme, largest first
+var tenorFormats = []string{"gif", "mediumgif", "tinygif", "mp4", "tinymp4", "webm"}
+
+// SetTenorSources replaces the Tenor lists memes are pulled from, read from
+// apiURL with apiKey, and forces a refresh on next fetch
+func (ms *Service) SetTenorSources(apiURL, apiKey string, sources []GIFSource) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.tenorAPIURL = apiURL
+	ms.tenorAPIKey = apiKey
+	ms.tenorSources = sources
+	ms.lastFetch = time.Time{}
+}
+
+// tenorResponse is the subset of a Tenor list we use
+type tenorResponse struct {
+	Results []struct {
+		ID          string   `json:"id"`
+		Title       string   `json:"title"`
+		Description string   `json:"content_description"`
+		ItemURL     string   `json:"itemurl"` // Page on tenor.com
+		Created     float64  `json:"created"`
+		Tags        []string `json:"tags"`
+		Media       map[string]struct {
+			URL  string `json:"url"`
+			Dims []int  `json:"dims"` // Width and height
+			Size int64  `json:"size"`
+		} `json:"media_formats"`
+	} `json:"results"`
+}
+
+// tenorProvider fetches Tenor's featured GIFs or a search
+type tenorProvider struct {
+	ms       *Service
+	source   GIFSource
+	apiURL   string
+	apiKey   string
+	maxBytes int64 // Largest response read, 0 for no limit
+}
+
+// Name implements Provider
+func (p tenorProvider) Name() string {
+	return "tenor:" + p.source.Key()
+}
+
+// cacheKey implements cachedProvider
+func (p tenorProvider) cacheKey() string {
+	return "response:tenor:" + p.source.Key()
+}
+
+// Fetch implements Provider, mapping each GIF's media formats into its meme
+func (p tenorProvider) Fetch(ctx context.Context, client *http.Client) ([]Meme, error) {
+	query := url.Values{}
+	query.Set("key", p.apiKey)
+	query.Set("client_key", "meme-fetcher")
+	query.Set("limit", "25")
+	query.Set("contentfilter", "medium")
+	query.Set("media_filter", strings.Join(tenorFormats, ","))
+	endpoint := p.apiURL + "/featured"
+	if p.source.Query != "" {
+		query.Set("q", p.source.Query)
+		endpoint = p.apiURL + "/search"
+	}
+
+	var list tenorResponse
+	err := p.ms.fetchJSON(ctx, client, jsonRequest{
+		kind:     "tenor",
+		name:     p.Name(),
+		url:      endpoint + "?" + query.Encode(),
+		cacheKey: p.cacheKey(),
+		ttl:      p.source.TTL,
+		maxBytes: p.maxBytes,
+	}, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	memes := make([]Meme, 0, len(list.Results))
+	for _, gif := range list.Results {
+		var renditions []Rendition
+		for _, name := range tenorFormats {
+			media, ok := gif.Media[name]
+			if !ok || media.URL == "" {
+				continue
+			}
+			rendition := newRendition(name, media.URL, 0, 0, media.Size)
+			if len(media.Dims) == 2 {
+				rendition.Width, rendition.Height = media.Dims[0], media.Dims[1]
+			}
+			renditions = append(renditions, rendition)
+		}
+
+		i := slices.IndexFunc(renditions, func(r Rendition) bool { return r.Format == "gif" })
+		if i < 0 {
+			continue
+		}
+		primary := renditions[i]
+
+		meme := Meme{
+			ID:         "tenor_" + gif.ID,
+			Title:      gif.Title,
+			URL:        primary.URL,
+			Width:      primary.Width,
+			Height:     primary.Height,
+			Size:       primary.Size,
+			Permalink:  gif.ItemURL,
+			CreatedUTC: int64(gif.Created),
+			MediaType:  MediaGIF,
+			Renditions: renditions,
+		}
+		if meme.Title == "" {
+			meme.Title = gif.Description
+		}
+		if meme.Title == "" {
+			meme.Title = "GIF"
+		}
+		meme.Tags = extractTags(meme.Title, strings.Join(gif.Tags, " "), p.source.Query)
+		memes = append(memes, meme)
+	}
+	return memes, nil
+}
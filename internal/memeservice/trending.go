@@ -0,0 +1,91 @@
+package memeservice
+
+import (
+	"sort"
+	"time"
+)
+
+// rankJumpThreshold is the number of positions a meme must climb to be announced
+const rankJumpThreshold = 5
+
+// Trending describes how quickly a meme gained score between two refreshes
+type Trending struct {
+	Meme
+	ScoreDelta   int     `json:"score_delta"`
+	Velocity     float64 `json:"velocity"` // Score gained per hour
+	Rank         int     `json:"rank"`
+	PreviousRank int     `json:"previous_rank"`
+}
+
+// computeTrending compares two pools and returns memes ordered by velocity
+// along with those whose rank jumped significantly
+func computeTrending(previous, current []Meme, elapsed time.Duration) ([]Trending, []Trending) {
+	if len(previous) == 0 || elapsed <= 0 {
+		return nil, nil
+	}
+
+	previousRanks := rankByScore(previous)
+	currentRanks := rankByScore(current)
+
+	previousScores := make(map[string]int, len(previous))
+	for _, meme := range previous {
+		previousScores[meme.ID] = meme.Score
+	}
+
+	trending := make([]Trending, 0, len(current))
+	var jumps []Trending
+	for _, meme := range current {
+		previousScore, seen := previousScores[meme.ID]
+		if !seen || meme.ID == "" {
+			continue
+		}
+
+		delta := meme.Score - previousScore
+		entry := Trending{
+			Meme:         meme,
+			ScoreDelta:   delta,
+			Velocity:     float64(delta) / elapsed.Hours(),
+			Rank:         currentRanks[meme.ID],
+			PreviousRank: previousRanks[meme.ID],
+		}
+		trending = append(trending, entry)
+
+		if entry.PreviousRank-entry.Rank >= rankJumpThreshold {
+			jumps = append(jumps, entry)
+		}
+	}
+
+	sort.Slice(trending, func(i, j int) bool {
+		return trending[i].Velocity > trending[j].Velocity
+	})
+	return trending, jumps
+}
+
+// rankByScore maps meme IDs to their 1-based rank ordered by score
+func rankByScore(memes []Meme) map[string]int {
+	sorted := make([]Meme, len(memes))
+	copy(sorted, memes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	ranks := make(map[string]int, len(sorted))
+	for i, meme := range sorted {
+		ranks[meme.ID] = i + 1
+	}
+	return ranks
+}
+
+// GetTrending returns up to limit memes gaining score fastest
+func (ms *Service) GetTrending(limit int) []Trending {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if limit <= 0 || limit > len(ms.trending) {
+		limit = len(ms.trending)
+	}
+
+	trending := make([]Trending, limit)
+	copy(trending, ms.trending[:limit])
+	return trending
+}
@@ -0,0 +1,61 @@
+package memeservice
+
+import (
+	"html"
+	"strings"
+)
+
+// Video describes the playable streams of a video meme
+type Video struct {
+	URL      string `json:"url"` // Progressive MP4 playable by <video>
+	DASHURL  string `json:"dash_url,omitempty"`
+	HLSURL   string `json:"hls_url,omitempty"`
+	Duration int    `json:"duration,omitempty"` // Seconds
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+// redditVideo is Reddit's description of a hosted video
+type redditVideo struct {
+	FallbackURL string `json:"fallback_url"`
+	DASHURL     string `json:"dash_url"`
+	HLSURL      string `json:"hls_url"`
+	Duration    int    `json:"duration"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// redditMedia holds the video fields of a Reddit post
+type redditMedia struct {
+	IsVideo bool `json:"is_video"`
+
+	Media struct {
+		RedditVideo *redditVideo `json:"reddit_video"`
+	} `json:"media"`
+	SecureMedia struct {
+		RedditVideo *redditVideo `json:"reddit_video"`
+	} `json:"secure_media"`
+}
+
+// video extracts a post's hosted video, if it is a v.redd.it post
+func (m redditMedia) video(url string) *Video {
+	rv := m.SecureMedia.RedditVideo
+	if rv == nil {
+		rv = m.Media.RedditVideo
+	}
+	if rv == nil || rv.FallbackURL == "" {
+		return nil
+	}
+	if !m.IsVideo && !strings.Contains(url, "v.redd.it") {
+		return nil
+	}
+
+	return &Video{
+		URL:      html.UnescapeString(rv.FallbackURL),
+		DASHURL:  html.UnescapeString(rv.DASHURL),
+		HLSURL:   html.UnescapeString(rv.HLSURL),
+		Duration: rv.Duration,
+		Width:    rv.Width,
+		Height:   rv.Height,
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressor is implemented by both the gzip and brotli writers
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compress negotiates brotli or gzip response compression. Event streams are
+// passed through untouched unless compressSSE is set, in which case every
+// flush also flushes the compressor so events are not held back.
+// Range responses, already-encoded bodies and compressed media are never
+// compressed.
+func Compress(compressSSE bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			w.Header().Add("Vary", "Accept-Encoding")
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, compressSSE: compressSSE}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks brotli over gzip when the client accepts it
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		// Honour explicit refusals such as "gzip;q=0"
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted["br"]:
+		return "br"
+	case accepted["gzip"]:
+		return "gzip"
+	default:
+		return ""
+	}
+}
+
+// compressWriter lazily decides whether to compress once headers are known
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	compressSSE bool
+
+	decided bool
+	enc     compressor
+}
+
+// decide inspects the response headers and sets up compression if appropriate
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	isSSE := strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+	if h.Get("Content-Encoding") != "" || (isSSE && !cw.compressSSE) || isCompressedMedia(h.Get("Content-Type")) ||
+		status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return
+	}
+
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+
+	if cw.encoding == "br" {
+		cw.enc = brotli.NewWriterLevel(cw.ResponseWriter, brotli.DefaultCompression)
+	} else {
+		cw.enc = gzip.NewWriter(cw.ResponseWriter)
+	}
+}
+
+// isCompressedMedia reports whether a content type is already compressed, i.e.
+// images and video other than SVG, which is text
+func isCompressedMedia(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "video/")
+}
+
+// WriteHeader finalises the compression decision before sending headers
+func (cw *compressWriter) WriteHeader(status int) {
+	cw.decide(status)
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses the body when compression is enabled
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+// Flush pushes buffered compressed data through to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close terminates the compressed stream
+func (cw *compressWriter) Close() error {
+	if cw.enc == nil {
+		return nil
+	}
+	return cw.enc.Close()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// ValidateHosts rejects requests whose Host header or Origin is not allowed.
+// Patterns may be exact names or "*.example.com" wildcards; an empty list allows
+// everything. Requests without an Origin header are not subject to origin checks.
+func ValidateHosts(allowedHosts, allowedOrigins []string) func(http.Handler) http.Handler {
+	hosts := normalizePatterns(allowedHosts)
+	origins := normalizePatterns(allowedOrigins)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := stripPort(r.Host)
+			if len(hosts) > 0 && !matchAny(hosts, host) {
+				log.Printf("Rejected request for host %q from %s", r.Host, r.RemoteAddr)
+				apierror.Write(w, r, apierror.New(http.StatusMisdirectedRequest, "host_not_allowed", "host not allowed"))
+				return
+			}
+
+			if origin := r.Header.Get("Origin"); origin != "" && len(origins) > 0 {
+				if !originAllowed(origins, origin, host) {
+					log.Printf("Rejected request from origin %q (%s)", origin, r.RemoteAddr)
+					apierror.Write(w, r, apierror.New(http.StatusForbidden, "origin_not_allowed", "origin not allowed"))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// OriginAllowed reports whether a browser at origin may use a server reached
+// as requestHost: the same host, or one matching an allowed origin pattern
+func OriginAllowed(allowedOrigins []string, origin, requestHost string) bool {
+	return originAllowed(normalizePatterns(allowedOrigins), origin, stripPort(requestHost))
+}
+
+// originAllowed accepts same-origin requests and origins matching a pattern by
+// full origin ("https://app.example.com") or by host name alone
+func originAllowed(patterns []string, origin, requestHost string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	originHost := stripPort(parsed.Host)
+	if originHost == requestHost {
+		return true
+	}
+	return matchAny(patterns, strings.ToLower(origin)) || matchAny(patterns, originHost)
+}
+
+// matchAny reports whether value matches one of the patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == value {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(value, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePatterns lowercases patterns and drops empty entries
+func normalizePatterns(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern != "" {
+			normalized = append(normalized, strings.TrimSuffix(pattern, "/"))
+		}
+	}
+	return normalized
+}
+
+// stripPort removes any port from a host and lowercases it
+func stripPort(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
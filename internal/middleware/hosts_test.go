@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestValidateHosts checks the Host header and Origin checks, with wildcards
+func TestValidateHosts(t *testing.T) {
+	handler := ValidateHosts(
+		[]string{"localhost", "*.ngrok.app", " Memes.Example.com "},
+		[]string{"https://app.example.com/", "*.partner.dev"},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{name: "allowed host", host: "localhost:8080", want: http.StatusOK},
+		{name: "host case and port", host: "MEMES.example.com:443", want: http.StatusOK},
+		{name: "wildcard host", host: "abc.ngrok.app", want: http.StatusOK},
+		{name: "wildcard needs a subdomain", host: "ngrok.app", want: http.StatusMisdirectedRequest},
+		{name: "suffix is not a subdomain", host: "evilngrok.app", want: http.StatusMisdirectedRequest},
+		{name: "unknown host", host: "attacker.test", want: http.StatusMisdirectedRequest},
+		{name: "rebinding by IP", host: "127.0.0.1:8080", want: http.StatusMisdirectedRequest},
+		{name: "same origin", host: "localhost:8080", origin: "http://localhost:8080", want: http.StatusOK},
+		{name: "allowed origin", host: "localhost", origin: "https://app.example.com", want: http.StatusOK},
+		{name: "allowed origin other scheme", host: "localhost", origin: "http://app.example.com", want: http.StatusForbidden},
+		{name: "wildcard origin", host: "localhost", origin: "https://www.partner.dev", want: http.StatusOK},
+		{name: "cross-site origin", host: "localhost", origin: "https://attacker.test", want: http.StatusForbidden},
+		{name: "opaque origin", host: "localhost", origin: "null", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/memes", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+// TestValidateHostsEmpty checks empty lists allow every host and origin
+func TestValidateHostsEmpty(t *testing.T) {
+	handler := ValidateHosts(nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/memes", nil)
+	r.Host = "anything.test"
+	r.Header.Set("Origin", "https://elsewhere.test")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// TestOriginAllowed checks the check used for WebSocket upgrades, which also
+// applies without any allowed origins
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		host    string
+		want    bool
+	}{
+		{nil, "http://localhost:8080", "localhost:8080", true},
+		{nil, "https://abc.ngrok.app", "abc.ngrok.app", true},
+		{nil, "https://attacker.test", "localhost:8080", false},
+		{nil, "", "localhost", false},
+		{[]string{"https://app.example.com"}, "https://app.example.com", "localhost", true},
+		{[]string{"app.example.com"}, "http://app.example.com:3000", "localhost", true},
+		{[]string{"*.example.com"}, "https://evil-example.com", "localhost", false},
+	}
+
+	for _, tt := range tests {
+		if got := OriginAllowed(tt.allowed, tt.origin, tt.host); got != tt.want {
+			t.Errorf("OriginAllowed(%q, %q, %q) = %v, want %v", tt.allowed, tt.origin, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Package netguard builds HTTP clients for URLs chosen by users or remote
+// parties, which must not reach the host's own network
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// NewClient creates a client that only connects to public addresses
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		// No proxy, which would sidestep the address check
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: PublicAddressOnly}).DialContext,
+		},
+	}
+}
+
+// PublicAddressOnly refuses connections to loopback, private and link-local
+// addresses. It checks the address actually dialled, after DNS resolution, so
+// names pointing inside the network are refused too
+func PublicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package netguard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestPublicAddressOnly checks the dialer refuses addresses inside the
+// host's network
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.8.9.10:80", false},
+		{"[::1]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fc00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"localhost:80", false},
+		{"93.184.216.34", false},
+	}
+
+	for _, tt := range tests {
+		err := PublicAddressOnly("tcp", tt.address, nil)
+		if (err == nil) != tt.want {
+			t.Errorf("PublicAddressOnly(%q) error = %v, want allowed %v", tt.address, err, tt.want)
+		}
+	}
+}
+
+// TestNewClientRefusesLoopback checks the client cannot reach a local server
+func TestNewClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	resp, err := NewClient(5 * time.Second).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("client reached %s", srv.URL)
+	}
+}
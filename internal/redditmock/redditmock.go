@@ -0,0 +1,291 @@
+// Package redditmock provides a fake Reddit listing API for hermetic
+// end-to-end tests of the fetch and stream pipeline.
+package redditmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures the behaviour of the mock
+type Options struct {
+	Latency   time.Duration // Delay added to every response
+	ErrorRate float64       // Fraction of listing requests answered with 503
+	Posts     int           // Generated posts per subreddit when no fixture is loaded
+	Fixture   string        // Optional Reddit listing JSON file served for every subreddit
+	Seed      int64         // Seed for generated scores and injected errors
+}
+
+// Server is a running fake Reddit
+type Server struct {
+	opts     Options
+	fixture  []byte
+	started  time.Time
+	listener net.Listener
+	http     *http.Server
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates a mock without starting it, for mounting Handler in tests
+func New(opts Options) (*Server, error) {
+	if opts.Posts <= 0 {
+		opts.Posts = 26
+	}
+
+	s := &Server{
+		opts:    opts,
+		started: time.Now(),
+		rng:     rand.New(rand.NewSource(opts.Seed)),
+	}
+
+	if opts.Fixture != "" {
+		fixture, err := os.ReadFile(opts.Fixture)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %v", err)
+		}
+		if !json.Valid(fixture) {
+			return nil, fmt.Errorf("fixture %s is not valid JSON", opts.Fixture)
+		}
+		s.fixture = fixture
+	}
+
+	return s, nil
+}
+
+// Start creates a mock and serves it on a random local port
+func Start(opts Options) (*Server, error) {
+	s, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %v", err)
+	}
+
+	s.listener = ln
+	s.http = &http.Server{Handler: s.Handler()}
+	go s.http.Serve(ln)
+	return s, nil
+}
+
+// URL returns the base URL of a started mock
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// Close stops a started mock
+func (s *Server) Close() error {
+	if s.http == nil {
+		return nil
+	}
+	return s.http.Close()
+}
+
+// Handler returns the mock's routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /r/{subreddit}/{listing}", s.handleListing)
+	mux.HandleFunc("GET /img/{name}", s.handleImage)
+	mux.HandleFunc("GET /video/{name}", s.handleVideo)
+	return mux
+}
+
+// handleListing serves a subreddit listing in Reddit's JSON shape
+func (s *Server) handleListing(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.opts.Latency)
+
+	if s.failNow() {
+		http.Error(w, "mock upstream error", http.StatusServiceUnavailable)
+		return
+	}
+
+	subreddit := r.PathValue("subreddit")
+	if !strings.HasSuffix(r.PathValue("listing"), ".json") {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.fixture != nil {
+		w.Write(s.fixture)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.listing(subreddit, baseURL(r)))
+}
+
+// failNow decides whether to inject an error
+func (s *Server) failNow() bool {
+	if s.opts.ErrorRate <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rng.Float64() < s.opts.ErrorRate
+}
+
+// galleryEvery makes every Nth generated post a three-image gallery
+const galleryEvery = 9
+
+// addGallery turns a generated post into a gallery linking to reddit.com, like the real thing
+func addGallery(data map[string]any, base, id string) {
+	items := []map[string]any{}
+	metadata := map[string]any{}
+	for n := 1; n <= 3; n++ {
+		mediaID := fmt.Sprintf("%s_g%d", id, n)
+		items = append(items, map[string]any{"media_id": mediaID})
+		metadata[mediaID] = map[string]any{
+			"status": "valid",
+			"s":      map[string]any{"u": fmt.Sprintf("%s/img/%s.png?format=png&amp;n=%d", base, mediaID, n), "x": 320, "y": 240},
+		}
+	}
+
+	data["url"] = fmt.Sprintf("https://www.reddit.com/gallery/%s", id)
+	data["is_gallery"] = true
+	data["gallery_data"] = map[string]any{"items": items}
+	data["media_metadata"] = metadata
+}
+
+// videoEvery makes every Nth generated post a v.redd.it video
+const videoEvery = 7
+
+// addVideo turns a generated post into a hosted video, with its MP4 fallback under secure_media
+func addVideo(data map[string]any, base, id string) {
+	video := map[string]any{
+		"fallback_url": fmt.Sprintf("%s/video/%s.mp4?source=fallback", base, id),
+		"dash_url":     fmt.Sprintf("%s/video/%s.mpd?a=1&amp;v=1", base, id),
+		"hls_url":      fmt.Sprintf("%s/video/%s.m3u8?a=1&amp;v=1", base, id),
+		"duration":     12,
+		"width":        320,
+		"height":       240,
+	}
+
+	data["url"] = fmt.Sprintf("https://v.redd.it/%s", id)
+	data["is_video"] = true
+	data["media"] = map[string]any{"reddit_video": video}
+	data["secure_media"] = map[string]any{"reddit_video": video}
+}
+
+// gifEvery makes every Nth generated post an animated GIF
+const gifEvery = 5
+
+// addGIF turns a generated post into a GIF with Reddit's gif preview variant
+func addGIF(data map[string]any, base, id string) {
+	url := fmt.Sprintf("%s/img/%s.gif", base, id)
+	data["url"] = url
+	data["post_hint"] = "image"
+	data["preview"] = map[string]any{
+		"images": []map[string]any{{
+			"source":   map[string]any{"url": fmt.Sprintf("%s/img/%s.png", base, id), "width": 320, "height": 240},
+			"variants": map[string]any{"gif": map[string]any{"source": map[string]any{"url": url, "width": 320, "height": 240}}},
+		}},
+	}
+}
+
+// mockFlairs are rotated across generated posts so tag filtering can be exercised
+var mockFlairs = []string{"Cats", "Programming", "Dogs", "OC", "Gaming"}
+
+// listing generates posts whose scores grow over time so trending can be exercised
+func (s *Server) listing(subreddit, base string) map[string]any {
+	elapsedMinutes := time.Since(s.started).Minutes()
+	children := make([]map[string]any, 0, s.opts.Posts)
+
+	for i := 0; i < s.opts.Posts; i++ {
+		id := fmt.Sprintf("%s%d", subreddit, i)
+		growth := float64((i*7)%11 + 1)
+		data := map[string]any{
+			"id":              id,
+			"title":           fmt.Sprintf("Mock meme %d from r/%s", i+1, subreddit),
+			"url":             fmt.Sprintf("%s/img/%s.png", base, id),
+			"score":           1000 - i*30 + int(growth*elapsedMinutes),
+			"subreddit":       subreddit,
+			"link_flair_text": mockFlairs[i%len(mockFlairs)],
+			"permalink":       fmt.Sprintf("/r/%s/comments/%s/", subreddit, id),
+			"created_utc":     float64(s.started.Add(-time.Duration(i) * time.Hour).Unix()),
+			"preview": map[string]any{
+				"images": []map[string]any{{
+					"source": map[string]any{"url": fmt.Sprintf("%s/img/%s.png", base, id), "width": 320, "height": 240},
+				}},
+			},
+		}
+		if i%galleryEvery == galleryEvery-1 {
+			addGallery(data, base, id)
+		} else if i%videoEvery == videoEvery-1 {
+			addVideo(data, base, id)
+		} else if i%gifEvery == gifEvery-1 {
+			addGIF(data, base, id)
+		}
+		children = append(children, map[string]any{"kind": "t3", "data": data})
+	}
+
+	return map[string]any{
+		"kind": "Listing",
+		"data": map[string]any{"children": children},
+	}
+}
+
+// handleImage serves a small generated PNG (or GIF for .gif names), answering HEAD with its size
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.opts.Latency)
+
+	name := r.PathValue("name")
+	var hue uint8
+	for _, c := range name {
+		hue += uint8(c)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	fill := color.RGBA{R: hue, G: 255 - hue, B: hue / 2, A: 255}
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if strings.HasSuffix(name, ".gif") {
+		contentType = "image/gif"
+		gif.Encode(&buf, img, nil)
+	} else {
+		png.Encode(&buf, img)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// handleVideo serves a placeholder body with a video content type
+func (s *Server) handleVideo(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.opts.Latency)
+
+	// Not a playable file, but enough to exercise video URLs end to end
+	body := []byte("mock video " + r.PathValue("name"))
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.Write(body)
+}
+
+// baseURL reconstructs the mock's own URL from a request
+func baseURL(r *http.Request) string {
+	return "http://" + r.Host
+}
@@ -0,0 +1,44 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header carries the request ID in both directions
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from clients or proxies
+const maxLength = 128
+
+// contextKey stores the request ID on the request context
+type contextKey struct{}
+
+// Middleware assigns every request an ID, reusing a sane incoming X-Request-ID,
+// and echoes it in the response
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" || len(id) > maxLength {
+			id = newID()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, id)))
+	})
+}
+
+// FromContext returns the request's ID, or empty outside the middleware
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// newID returns a random 16-byte hex ID
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,287 @@
+package s3archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+const (
+	// maxImageBytes bounds the images downloaded for the archive
+	maxImageBytes = 20 << 20
+
+	// maxConcurrentUploads bounds the images archived at once
+	maxConcurrentUploads = 4
+)
+
+// Manifest lists the memes seen in the pool on one day and where their
+// images were archived
+type Manifest struct {
+	Date        string          `json:"date"` // YYYY-MM-DD in UTC
+	GeneratedAt time.Time       `json:"generated_at"`
+	Memes       []ManifestEntry `json:"memes"`
+}
+
+// ManifestEntry is a meme as it was served, with its archived image
+type ManifestEntry struct {
+	memeservice.Meme
+	ArchiveKey string `json:"archive_key,omitempty"` // Object key of the image, empty if it was not archived
+}
+
+// Stats counts the archiver's work since startup
+type Stats struct {
+	Bucket   string     `json:"bucket"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	Uploaded int64      `json:"uploaded"` // Images written
+	Skipped  int64      `json:"skipped"`  // Images already archived or not archivable
+	Failed   int64      `json:"failed"`   // Images or manifests that could not be written
+}
+
+// Archiver uploads the images of the memes it is given and keeps a manifest
+// per day of every meme it saw
+type Archiver struct {
+	store    *Client
+	download *http.Client
+
+	mu       sync.Mutex
+	manifest *Manifest       // Today's manifest, nil before the first run
+	archived map[string]bool // Image keys known to be stored
+	entries  map[string]int  // Index in the manifest by meme key
+	stats    Stats
+}
+
+// NewArchiver archives into store, downloading images with download
+func NewArchiver(store *Client, download *http.Client) *Archiver {
+	return &Archiver{
+		store:    store,
+		download: download,
+		archived: make(map[string]bool),
+		entries:  make(map[string]int),
+		stats:    Stats{Bucket: store.Bucket()},
+	}
+}
+
+// Run archives the images of memes not archived yet and rewrites today's
+// manifest with them
+func (a *Archiver) Run(ctx context.Context, memes []memeservice.Meme) error {
+	now := time.Now().UTC()
+	if err := a.startDay(ctx, now.Format(time.DateOnly)); err != nil {
+		return err
+	}
+
+	keys := make([]string, len(memes))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentUploads)
+	for i, meme := range memes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			keys[i] = a.archiveImage(ctx, meme)
+		}()
+	}
+	wg.Wait()
+
+	a.mu.Lock()
+	for i, meme := range memes {
+		entry := ManifestEntry{Meme: meme, ArchiveKey: keys[i]}
+		if idx, seen := a.entries[meme.Key()]; seen {
+			if entry.ArchiveKey == "" {
+				entry.ArchiveKey = a.manifest.Memes[idx].ArchiveKey
+			}
+			a.manifest.Memes[idx] = entry
+			continue
+		}
+		a.entries[meme.Key()] = len(a.manifest.Memes)
+		a.manifest.Memes = append(a.manifest.Memes, entry)
+	}
+	a.manifest.GeneratedAt = now
+	data, err := json.MarshalIndent(a.manifest, "", "  ")
+	date := a.manifest.Date
+	a.stats.LastRun = &now
+	a.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := a.store.Put(ctx, manifestKey(date), "application/json", data); err != nil {
+		a.mu.Lock()
+		a.stats.Failed++
+		a.mu.Unlock()
+		return fmt.Errorf("failed to upload manifest: %v", err)
+	}
+	return nil
+}
+
+// startDay switches to the manifest of date, continuing the one already in
+// the bucket so restarts keep the memes seen earlier that day
+func (a *Archiver) startDay(ctx context.Context, date string) error {
+	a.mu.Lock()
+	current := a.manifest != nil && a.manifest.Date == date
+	a.mu.Unlock()
+	if current {
+		return nil
+	}
+
+	manifest := &Manifest{Date: date, Memes: []ManifestEntry{}}
+	data, err := a.store.Get(ctx, manifestKey(date))
+	switch {
+	case errors.Is(err, errNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to read manifest: %v", err)
+	default:
+		if err := json.Unmarshal(data, manifest); err != nil {
+			log.Printf("Archive manifest %s is invalid, starting a new one: %v", manifestKey(date), err)
+			manifest = &Manifest{Date: date, Memes: []ManifestEntry{}}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.manifest = manifest
+	a.entries = make(map[string]int, len(manifest.Memes))
+	for i, entry := range manifest.Memes {
+		a.entries[entry.Key()] = i
+		if entry.ArchiveKey != "" {
+			a.archived[entry.ArchiveKey] = true
+		}
+	}
+	return nil
+}
+
+// archiveImage uploads a meme's image unless it is stored already, returning
+// its key or "" for memes that cannot be archived
+func (a *Archiver) archiveImage(ctx context.Context, meme memeservice.Meme) string {
+	if meme.MediaType == memeservice.MediaVideo || meme.Size > maxImageBytes ||
+		!(strings.HasPrefix(meme.URL, "http://") || strings.HasPrefix(meme.URL, "https://")) {
+		a.count(&a.stats.Skipped)
+		return ""
+	}
+
+	key := imageKey(meme.URL)
+	a.mu.Lock()
+	archived := a.archived[key]
+	a.mu.Unlock()
+	if !archived {
+		exists, err := a.store.Exists(ctx, key)
+		if err != nil {
+			log.Printf("Archive: %v", err)
+			a.count(&a.stats.Failed)
+			return ""
+		}
+		archived = exists
+	}
+	if archived {
+		a.markArchived(key)
+		a.count(&a.stats.Skipped)
+		return key
+	}
+
+	data, contentType, err := a.fetch(ctx, meme.URL)
+	if err != nil {
+		log.Printf("Archive: %v", err)
+		a.count(&a.stats.Failed)
+		return ""
+	}
+	if err := a.store.Put(ctx, key, contentType, data); err != nil {
+		log.Printf("Archive: %v", err)
+		a.count(&a.stats.Failed)
+		return ""
+	}
+	a.markArchived(key)
+	a.count(&a.stats.Uploaded)
+	return key
+}
+
+// fetch downloads an image with its content type
+func (a *Archiver) fetch(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+
+	resp, err := a.download.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s returned %s", url, resp.Status)
+	}
+	// Read one byte past the limit to detect oversized bodies without a length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", url, err)
+	}
+	if len(data) > maxImageBytes {
+		return nil, "", fmt.Errorf("%s is larger than %d bytes", url, maxImageBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// markArchived remembers that an image is stored
+func (a *Archiver) markArchived(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.archived[key] = true
+}
+
+// count increments one of the stats counters
+func (a *Archiver) count(counter *int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	*counter++
+}
+
+// Stats returns the archiver's counters
+func (a *Archiver) Stats() Stats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := a.stats
+	if stats.LastRun != nil {
+		lastRun := *stats.LastRun
+		stats.LastRun = &lastRun
+	}
+	return stats
+}
+
+// imageKey names an image by a hash of its URL, keeping the extension so
+// the archive is browsable
+func imageKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	ext := strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0]))
+	if len(ext) > 5 || strings.ContainsAny(ext, "/:") {
+		ext = ""
+	}
+	return "images/" + hex.EncodeToString(sum[:16]) + ext
+}
+
+// manifestKey names the manifest of a day
+func manifestKey(date string) string {
+	return "manifests/" + date + ".json"
+}
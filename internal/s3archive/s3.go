@@ -0,0 +1,212 @@
+// Package s3archive copies meme images and daily pool manifests to
+// S3-compatible object storage, so the archive outlives Reddit link rot
+package s3archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxObjectBytes bounds the objects read back from the bucket
+const maxObjectBytes = 64 << 20
+
+// errNotFound reports a missing object
+var errNotFound = fmt.Errorf("object not found")
+
+// Config locates a bucket and the credentials to write to it
+type Config struct {
+	Endpoint  string // Base URL such as https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	Prefix    string // Prepended to every object key, e.g. "memes/"
+	AccessKey string
+	SecretKey string
+}
+
+// Client reads and writes objects with path-style requests signed with AWS
+// Signature Version 4, which AWS, MinIO, R2 and most other stores accept
+type Client struct {
+	cfg    Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewClient validates cfg and returns a client sending requests with client
+func NewClient(cfg Config, client *http.Client) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q, expected an http(s) URL", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("no bucket given")
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no region given")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("no access key or secret key given")
+	}
+	return &Client{cfg: cfg, base: base, client: client, now: time.Now}, nil
+}
+
+// Bucket returns the bucket objects are written to
+func (c *Client) Bucket() string {
+	return c.cfg.Bucket
+}
+
+// Put writes an object under the prefix
+func (c *Client) Put(ctx context.Context, key, contentType string, data []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, key, contentType, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("uploading %s returned %s: %s", key, resp.Status, errorBody(resp))
+	}
+	return nil
+}
+
+// Exists reports whether an object is already stored under the prefix
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, "", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking %s returned %s", key, resp.Status)
+	}
+}
+
+// Get reads an object under the prefix, errNotFound when there is none
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading %s returned %s: %s", key, resp.Status, errorBody(resp))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// do sends a signed request for an object
+func (c *Client) do(ctx context.Context, method, key, contentType string, body []byte) (*http.Response, error) {
+	target := *c.base
+	target.Path = c.base.Path + "/" + c.cfg.Bucket + "/" + c.cfg.Prefix + key
+	target.RawPath = escapePath(target.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+	c.sign(req, body)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %v", c.base.Host, err)
+	}
+	return resp, nil
+}
+
+// sign adds the Signature Version 4 headers covering the host, date and
+// payload hash
+func (c *Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // No query string
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretKey), date)
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath percent-encodes every byte of a path outside the unreserved
+// set except "/", as Signature Version 4 expects of S3 object keys
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9') {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// errorBody returns the start of an error response for logs
+func errorBody(resp *http.Response) string {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	s3archive "github.com/elt0nxale/meme-tunnel-streamer/internal/s3archive"
+)
+
+// SetArchiver copies the pool's images and a daily manifest to object
+// storage every interval once RunArchiver starts it
+func (s *Server) SetArchiver(a *s3archive.Archiver, interval time.Duration) {
+	s.archiver = a
+	s.archiveInterval = interval
+}
+
+// RunArchiver archives the pool at startup and then every interval in the
+// background until ctx is cancelled
+func (s *Server) RunArchiver(ctx context.Context) {
+	if s.archiver == nil || s.archiveInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.archiveInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.memeService.FetchMemes(ctx); err != nil {
+				s.logger.Printf("Archive refresh failed: %v", err)
+			}
+			memes := s.memeService.GetMemes()
+			if err := s.archiver.Run(ctx, memes); err != nil {
+				s.logger.Printf("Archive failed: %v", err)
+			} else {
+				stats := s.archiver.Stats()
+				s.logger.Printf("Archived %d memes to %s (%d images uploaded, %d failed since startup)",
+					len(memes), stats.Bucket, stats.Uploaded, stats.Failed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	audit "github.com/elt0nxale/meme-tunnel-streamer/internal/audit"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	clientip "github.com/elt0nxale/meme-tunnel-streamer/internal/clientip"
+)
+
+// auditLogSize is how many admin actions are kept in memory
+const auditLogSize = 1000
+
+// RecordAudit adds an admin action performed outside HTTP, such as a signal-triggered reload
+func (s *Server) RecordAudit(actor, action string, params map[string]string, err error) {
+	entry := audit.Entry{Actor: actor, Action: action, Params: params}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.audit.Record(entry)
+}
+
+// auditRequest records an admin action made through an HTTP request
+func (s *Server) auditRequest(r *http.Request, action string, params map[string]string, err error) {
+	entry := audit.Entry{
+		Actor:    s.requestActor(r),
+		ClientIP: clientip.FromRequest(r),
+		Action:   action,
+		Params:   params,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.audit.Record(entry)
+}
+
+// requestActor names who made an admin request: "admin" for the admin token,
+// "user:<email>" for a login session, "key:<name>" for an API key, "anonymous" otherwise
+func (s *Server) requestActor(r *http.Request) string {
+	if s.isAdminToken(r) {
+		return "admin"
+	}
+	if session, ok := auth.SessionFromContext(r.Context()); ok {
+		return "user:" + session.Email
+	}
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + key.Name
+	}
+	return "anonymous"
+}
+
+// handleKick disconnects a stream
+func (s *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	connID := r.PathValue("connID")
+	st, exists := s.getStream(connID)
+	if !exists {
+		err := fmt.Errorf("stream %s not found", connID)
+		s.auditRequest(r, "stream.kick", map[string]string{"connID": connID}, err)
+		apierror.Write(w, r, apierror.NotFound(err.Error()))
+		return
+	}
+
+	s.connectionManager.AddConnectionEvent(connID, "Kicked by admin")
+	st.cancel()
+	s.auditRequest(r, "stream.kick", map[string]string{"connID": connID}, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRefresh refetches every source now, bypassing the refresh interval and response cache
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	err := s.memeService.ForceRefresh(r.Context())
+	s.auditRequest(r, "memes.refresh", nil, err)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAudit serves the audit trail, filtered by ?action=, ?actor=, ?since= and ?limit=
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("invalid since, expected RFC 3339"))
+			return
+		}
+		since = parsed
+	}
+
+	limit := 100
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid limit"))
+			return
+		}
+		limit = parsed
+	}
+
+	entries := s.audit.Query(query.Get("action"), query.Get("actor"), since, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
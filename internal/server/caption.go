@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	caption "github.com/elt0nxale/meme-tunnel-streamer/internal/caption"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	netguard "github.com/elt0nxale/meme-tunnel-streamer/internal/netguard"
+)
+
+const (
+	// captionsPath serves captions rendered with broadcast
+	captionsPath = "/captions/"
+
+	// maxCaptionSource bounds the image a caption is drawn on
+	maxCaptionSource = 10 << 20
+
+	// maxCaptionText bounds each line of caption text, in characters
+	maxCaptionText = 200
+
+	// maxStoredCaptions is how many broadcast captions are kept and pooled
+	maxStoredCaptions = 50
+)
+
+// templateName restricts caption template names to plain file names
+var templateName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// templateExtensions are the image types a caption template may have
+var templateExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+
+// captionRequest is the body of POST /api/caption
+type captionRequest struct {
+	URL       string `json:"url"`
+	Template  string `json:"template"`
+	Top       string `json:"top"`
+	Bottom    string `json:"bottom"`
+	Title     string `json:"title"`
+	Broadcast bool   `json:"broadcast"`
+}
+
+// SetCaptions enables POST /api/caption, drawing on images fetched by URL or
+// on templates named after the images in templatesDir, if not empty
+func (s *Server) SetCaptions(templatesDir string) {
+	s.captions = caption.NewStore(maxStoredCaptions)
+	s.captionTemplates = templatesDir
+	s.captionClient = netguard.NewClient(10 * time.Second)
+}
+
+// handleCaption renders top and bottom text over an image and answers with
+// the PNG or, to broadcast it, adds it to the pool and sends it to every stream
+func (s *Server) handleCaption(w http.ResponseWriter, r *http.Request) {
+	var req captionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionForm)).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("body must be JSON with url or template, top and bottom"))
+		return
+	}
+	if (req.URL == "") == (req.Template == "") {
+		apierror.Write(w, r, apierror.BadRequest("exactly one of url and template is required"))
+		return
+	}
+	if strings.TrimSpace(req.Top) == "" && strings.TrimSpace(req.Bottom) == "" {
+		apierror.Write(w, r, apierror.BadRequest("top or bottom text is required"))
+		return
+	}
+	if utf8.RuneCountInString(req.Top) > maxCaptionText || utf8.RuneCountInString(req.Bottom) > maxCaptionText {
+		apierror.Write(w, r, apierror.BadRequest("caption text is too long"))
+		return
+	}
+
+	data, err := s.captionSource(r, req)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	img, err := caption.Decode(data)
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusUnprocessableEntity, "invalid_image", err.Error()))
+		return
+	}
+	captioned, err := caption.Render(img, req.Top, req.Bottom)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	rendered, err := caption.Encode(captioned)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if req.Broadcast {
+		// Sending to every stream is an admin action
+		s.requireRole(auth.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+			s.broadcastCaption(w, r, req, rendered)
+		})(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(rendered.PNG)
+}
+
+// captionSource reads the template or fetches the URL a caption is drawn on
+func (s *Server) captionSource(r *http.Request, req captionRequest) ([]byte, error) {
+	if req.Template != "" {
+		if s.captionTemplates == "" || !templateName.MatchString(req.Template) {
+			return nil, apierror.NotFound("template not found")
+		}
+		for _, ext := range templateExtensions {
+			f, err := os.Open(filepath.Join(s.captionTemplates, req.Template+ext))
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+			return io.ReadAll(io.LimitReader(f, maxCaptionSource))
+		}
+		return nil, apierror.NotFound("template not found")
+	}
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, apierror.BadRequest("url must be an http or https URL")
+	}
+	upstream, err := http.NewRequestWithContext(r.Context(), http.MethodGet, req.URL, nil)
+	if err != nil {
+		return nil, apierror.BadRequest("invalid url")
+	}
+	upstream.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+	resp, err := s.captionClient.Do(upstream)
+	if err != nil {
+		return nil, apierror.New(http.StatusBadGateway, "upstream_error", "failed to fetch image")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apierror.New(http.StatusBadGateway, "upstream_error", "image fetch returned "+resp.Status)
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCaptionSource+1))
+	if err != nil {
+		return nil, apierror.New(http.StatusBadGateway, "upstream_error", "failed to read image")
+	}
+	if len(data) > maxCaptionSource {
+		return nil, apierror.New(http.StatusRequestEntityTooLarge, "image_too_large", "image is over 10MB")
+	}
+	return data, nil
+}
+
+// broadcastCaption keeps a rendered caption, adds it to the pool and sends it
+// to every stream as a caption event, answering with the meme
+func (s *Server) broadcastCaption(w http.ResponseWriter, r *http.Request, req captionRequest, rendered caption.Rendered) {
+	name, evicted := s.captions.Add(rendered)
+	for i, old := range evicted {
+		evicted[i] = captionID(old)
+	}
+	s.memeService.RemoveMemes(evicted...)
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = strings.TrimSpace(strings.Join(strings.Fields(req.Top+" "+req.Bottom), " "))
+	}
+	meme := memeservice.NewSubmission(title, s.basePath+captionsPath+name)
+	meme.ID = captionID(name)
+	meme.Width = rendered.Width
+	meme.Height = rendered.Height
+	meme.Size = int64(len(rendered.PNG))
+	s.memeService.AddMemes(meme)
+
+	data, err := json.Marshal(meme)
+	s.auditRequest(r, "meme.caption", map[string]string{"id": meme.ID, "title": meme.Title}, err)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	s.broadcaster.Broadcast(broadcaster.Event{Name: "caption", Data: data})
+	s.logger.Printf("Broadcast caption %s: %s", meme.ID, meme.Title)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", meme.URL)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// captionID is the meme ID of a stored caption
+func captionID(name string) string {
+	return "caption_" + strings.TrimSuffix(name, ".png")
+}
+
+// handleCaptionImage serves a broadcast caption
+func (s *Server) handleCaptionImage(w http.ResponseWriter, r *http.Request) {
+	rendered, ok := s.captions.Get(r.PathValue("name"))
+	if !ok {
+		apierror.Write(w, r, apierror.NotFound("caption not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	w.Write(rendered.PNG)
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// Cohort is one arm of a streaming experiment: the clients assigned to it get
+// its selection strategy and cadence instead of the server's
+type Cohort struct {
+	Name     string
+	Weight   int           // Share of clients, relative to the other cohorts
+	Ranker   string        // Selection strategy as accepted by --ranker, empty for the server's
+	Interval time.Duration // Delay between memes, zero for the server's
+
+	ranker memeservice.Ranker
+}
+
+// ParseCohort parses a cohort specification "name:weight[:ranker[:interval]]",
+// e.g. "fast:1::10s" or "composite:2:composite"
+func ParseCohort(spec string) (Cohort, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 4 || parts[0] == "" {
+		return Cohort{}, fmt.Errorf("invalid cohort %q, expected name:weight[:ranker[:interval]]", spec)
+	}
+
+	cohort := Cohort{Name: parts[0]}
+	weight, err := strconv.Atoi(parts[1])
+	if err != nil || weight <= 0 {
+		return Cohort{}, fmt.Errorf("invalid weight in cohort %q, expected a positive integer", spec)
+	}
+	cohort.Weight = weight
+
+	if len(parts) > 2 && parts[2] != "" {
+		if cohort.ranker, err = memeservice.ParseRanker(parts[2]); err != nil {
+			return Cohort{}, fmt.Errorf("invalid cohort %q: %v", spec, err)
+		}
+		cohort.Ranker = parts[2]
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		if cohort.Interval, err = time.ParseDuration(parts[3]); err != nil || cohort.Interval <= 0 {
+			return Cohort{}, fmt.Errorf("invalid interval in cohort %q, expected a positive duration such as 10s", spec)
+		}
+	}
+	return cohort, nil
+}
+
+// CohortStats is how an experiment cohort's streams behaved
+type CohortStats struct {
+	Name              string  `json:"name"`
+	Weight            int     `json:"weight"` // 0 for cohorts removed by a reload
+	Ranker            string  `json:"ranker,omitempty"`
+	Interval          string  `json:"interval,omitempty"`
+	Connections       int64   `json:"connections"`
+	ActiveStreams     int64   `json:"active_streams"`
+	MemesSent         int64   `json:"memes_sent"`
+	AvgSessionSeconds float64 `json:"avg_session_seconds"` // Over ended streams
+}
+
+// cohortMetrics accumulates the streams of one cohort
+type cohortMetrics struct {
+	connections int64
+	active      int64
+	memesSent   int64
+	ended       int64
+	connected   time.Duration // Total length of ended streams
+}
+
+// experiment assigns clients to cohorts and keeps their metrics, which
+// survive reloads that change the cohorts
+type experiment struct {
+	mu      sync.Mutex
+	cohorts []Cohort
+	total   int // Sum of the cohorts' weights
+	metrics map[string]*cohortMetrics
+}
+
+// configure replaces the cohorts; none ends the experiment
+func (e *experiment) configure(cohorts []Cohort) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cohorts = cohorts
+	e.total = 0
+	for _, cohort := range cohorts {
+		e.total += cohort.Weight
+	}
+}
+
+// assign picks a client's cohort from a hash of its token, so its reconnects
+// land in the same cohort while the weights stay the same; false when no
+// experiment runs or the client has no token
+func (e *experiment) assign(client string) (Cohort, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.total == 0 || client == "" {
+		return Cohort{}, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(client))
+	slot := int(h.Sum64() % uint64(e.total))
+	for _, cohort := range e.cohorts {
+		if slot < cohort.Weight {
+			return cohort, true
+		}
+		slot -= cohort.Weight
+	}
+	return Cohort{}, false
+}
+
+// record updates a cohort's metrics; callers hold e.mu
+func (e *experiment) record(name string) *cohortMetrics {
+	if e.metrics == nil {
+		e.metrics = make(map[string]*cohortMetrics)
+	}
+	m, exists := e.metrics[name]
+	if !exists {
+		m = &cohortMetrics{}
+		e.metrics[name] = m
+	}
+	return m
+}
+
+// started counts a stream opened in a cohort
+func (e *experiment) started(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m := e.record(name)
+	m.connections++
+	m.active++
+}
+
+// sent counts a meme sent to a cohort's stream
+func (e *experiment) sent(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.record(name).memesSent++
+}
+
+// ended counts a cohort's stream closing after d
+func (e *experiment) ended(name string, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m := e.record(name)
+	m.active--
+	m.ended++
+	m.connected += d
+}
+
+// stats lists the cohorts with metrics or configuration, by name
+func (e *experiment) stats() []CohortStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var stats []CohortStats
+	for _, cohort := range e.cohorts {
+		cs := CohortStats{Name: cohort.Name, Weight: cohort.Weight, Ranker: cohort.Ranker}
+		if cohort.Interval > 0 {
+			cs.Interval = cohort.Interval.String()
+		}
+		stats = append(stats, cs)
+	}
+	for name := range e.metrics {
+		if !slices.ContainsFunc(stats, func(cs CohortStats) bool { return cs.Name == name }) {
+			stats = append(stats, CohortStats{Name: name})
+		}
+	}
+	for i := range stats {
+		m, exists := e.metrics[stats[i].Name]
+		if !exists {
+			continue
+		}
+		stats[i].Connections = m.connections
+		stats[i].ActiveStreams = m.active
+		stats[i].MemesSent = m.memesSent
+		if m.ended > 0 {
+			stats[i].AvgSessionSeconds = m.connected.Seconds() / float64(m.ended)
+		}
+	}
+	slices.SortFunc(stats, func(a, b CohortStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// SetCohorts runs an experiment splitting clients between cohorts by their
+// client token; none ends it. Streams keep the cohort they started in
+func (s *Server) SetCohorts(cohorts []Cohort) error {
+	for i, cohort := range cohorts {
+		if slices.ContainsFunc(cohorts[:i], func(c Cohort) bool { return c.Name == cohort.Name }) {
+			return fmt.Errorf("duplicate cohort %q", cohort.Name)
+		}
+	}
+	s.experiment.configure(cohorts)
+	return nil
+}
+
+// streamInterval is the delay between a stream's timed memes: its cohort's
+// cadence if it has one, stretched during slowed quiet hours like the server's
+func (s *Server) streamInterval(st *stream) time.Duration {
+	if st.cohort == nil || st.cohort.Interval == 0 {
+		return s.memeIntervalNow()
+	}
+	return s.quietInterval(st.cohort.Interval)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// conformanceStep is one wire fragment of the conformance stream and the
+// event a conforming client dispatches for it, nil when it dispatches none
+type conformanceStep struct {
+	Name   string             `json:"name"`
+	Raw    string             `json:"raw"`
+	Expect *conformanceExpect `json:"expect"`
+}
+
+// conformanceExpect is an event as seen by an EventSource listener
+type conformanceExpect struct {
+	Type        string `json:"type"`
+	Data        string `json:"data"`
+	LastEventID string `json:"last_event_id"`
+}
+
+// conformanceSteps exercise the SSE grammar in a fixed order; later
+// expectations depend on the ids set by earlier steps
+var conformanceSteps = []conformanceStep{
+	{"bom_and_comment", "\ufeff: conformance stream, see GET /memes/conformance?format=json\n\n", nil},
+	{"retry", "retry: 2000\n\n", nil},
+	{"simple", "data: simple\n\n", &conformanceExpect{"message", "simple", ""}},
+	{"multi_line_data", "data: line1\ndata: line2\n\n", &conformanceExpect{"message", "line1\nline2", ""}},
+	{"no_space_after_colon", "data:nospace\n\n", &conformanceExpect{"message", "nospace", ""}},
+	{"only_one_space_stripped", "data:  leading\n\n", &conformanceExpect{"message", " leading", ""}},
+	{"fields_in_any_order", "data: reordered\nevent: custom\nid: 42\n\n", &conformanceExpect{"custom", "reordered", "42"}},
+	{"id_persists", "data: keeps-id\n\n", &conformanceExpect{"message", "keeps-id", "42"}},
+	{"id_reset", "id\ndata: id-reset\n\n", &conformanceExpect{"message", "id-reset", ""}},
+	{"id_with_null_ignored", "id: bad\x00id\ndata: null-id-ignored\n\n", &conformanceExpect{"message", "null-id-ignored", ""}},
+	{"id_without_data_kept", "id: 7\n\n", nil},
+	{"id_applies_to_next", "data: after-bare-id\n\n", &conformanceExpect{"message", "after-bare-id", "7"}},
+	{"crlf_line_endings", "data: crlf\r\n\r\n", &conformanceExpect{"message", "crlf", "7"}},
+	{"cr_line_endings", "data: cr\r\r", &conformanceExpect{"message", "cr", "7"}},
+	{"interleaved_comment", ": comment\ndata: after-comment\n: another\n\n", &conformanceExpect{"message", "after-comment", "7"}},
+	{"unknown_field_ignored", "foo: bar\ndata: unknown-field\n\n", &conformanceExpect{"message", "unknown-field", "7"}},
+	{"invalid_retry_ignored", "retry: soon\ndata: bad-retry\n\n", &conformanceExpect{"message", "bad-retry", "7"}},
+	{"empty_data", "data\n\n", &conformanceExpect{"message", "", "7"}},
+	{"event_without_data_dropped", "event: ghost\n\n", nil},
+	{"event_type_resets", "data: type-reset\n\n", &conformanceExpect{"message", "type-reset", "7"}},
+	{"colon_in_value", "data: a b : c\n\n", &conformanceExpect{"message", "a b : c", "7"}},
+	{"done", "event: done\nid: end\ndata: end\n\n", &conformanceExpect{"done", "end", "end"}},
+}
+
+// handleConformance writes the conformance sequence with ?delay= (default
+// 100ms) between steps, or with ?format=json describes it
+func (s *Server) handleConformance(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(conformanceSteps); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		return
+	}
+
+	delay := 100 * time.Millisecond
+	if raw := r.URL.Query().Get("delay"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 || parsed > 10*time.Second {
+			apierror.Write(w, r, apierror.BadRequest("invalid delay, expected a duration up to 10s"))
+			return
+		}
+		delay = parsed
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "streaming_unsupported", "streaming unsupported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for i, step := range conformanceSteps {
+		if i > 0 && delay > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+		if _, err := w.Write([]byte(step.Raw)); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
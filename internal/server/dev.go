@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+)
+
+// reloadDebounce coalesces bursts of file events from editors saving files
+const reloadDebounce = 200 * time.Millisecond
+
+// WatchAssets watches a directory on disk and tells every connected client to
+// reload the page when a template or static asset changes
+func (s *Server) WatchAssets(ctx context.Context, dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+
+	// fsnotify is not recursive, so watch every directory individually
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", dir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					watcher.Add(event.Name)
+				}
+				s.logger.Printf("Dev: %s changed", event.Name)
+				debounce = time.After(reloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				s.logger.Printf("Dev: watcher error: %v", err)
+			case <-debounce:
+				debounce = nil
+				s.broadcaster.Broadcast(broadcaster.Event{Name: "reload", Data: []byte("{}")})
+			}
+		}
+	}()
+
+	return nil
+}
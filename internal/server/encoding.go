@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+const (
+	// maxInlineImage is the largest image embedded with ?inline_images=1
+	maxInlineImage = 64 << 10
+
+	// inlineTimeout bounds fetching an image to embed
+	inlineTimeout = 5 * time.Second
+)
+
+// payloadOptions shape the events written to one stream
+type payloadOptions struct {
+	base64       bool           // Base64-encode every event's data, for ?encoding=base64
+	inlineImages bool           // Embed small images as data URIs, for ?inline_images=1
+	fields       fieldSelection // Meme fields sent, for ?fields=
+}
+
+// parsePayloadOptions reads a stream's payload options from its query
+func parsePayloadOptions(query url.Values) (payloadOptions, error) {
+	opts := payloadOptions{fields: parseFields(query.Get("fields"))}
+
+	switch encoding := query.Get("encoding"); encoding {
+	case "", "json":
+	case "base64":
+		opts.base64 = true
+	default:
+		return opts, fmt.Errorf("invalid encoding %q, expected json or base64", encoding)
+	}
+
+	if raw := query.Get("inline_images"); raw != "" {
+		inline, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid inline_images")
+		}
+		opts.inlineImages = inline
+	}
+
+	return opts, nil
+}
+
+// encodeData applies the stream's transfer encoding to an event's data
+func (o payloadOptions) encodeData(data []byte) []byte {
+	if !o.base64 {
+		return data
+	}
+	return []byte(base64.StdEncoding.EncodeToString(data))
+}
+
+// inlineImage fetches a small image meme as a data URI, or returns "" when it
+// is not an image, too large or unavailable
+func (s *Server) inlineImage(meme memeservice.Meme) string {
+	if meme.MediaType == memeservice.MediaVideo || meme.URL == "" || meme.Size > maxInlineImage {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), inlineTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meme.URL, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := s.memeService.HTTPClient().Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		return ""
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a length
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxInlineImage+1))
+	if err != nil || len(body) > maxInlineImage {
+		return ""
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(body)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	journal "github.com/elt0nxale/meme-tunnel-streamer/internal/journal"
+)
+
+// maxReplayLimit caps the events returned by one replay request
+const maxReplayLimit = 1000
+
+// eventsResponse is the body of GET /api/events
+type eventsResponse struct {
+	Events    []journal.Entry `json:"events"`
+	OldestSeq uint64          `json:"oldest_seq"` // Oldest event still retained
+	LastSeq   uint64          `json:"last_seq"`   // Newest event journaled
+	Truncated bool            `json:"truncated"`  // Events after since_seq were already discarded
+}
+
+// SetJournal records every broadcast event and enables GET /api/events
+func (s *Server) SetJournal(j *journal.Journal) {
+	s.journal = j
+	s.broadcaster.SetJournal(j)
+}
+
+// handleEvents replays journaled events after ?since_seq=
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if raw := r.URL.Query().Get("since_seq"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("invalid since_seq"))
+			return
+		}
+		since = parsed
+	}
+
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid limit"))
+			return
+		}
+		limit = min(parsed, maxReplayLimit)
+	}
+
+	oldest, last := s.journal.Bounds()
+	events := s.journal.Since(since, limit)
+	if events == nil {
+		events = []journal.Entry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(eventsResponse{
+		Events:    events,
+		OldestSeq: oldest,
+		LastSeq:   last,
+		Truncated: oldest > since+1,
+	}); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
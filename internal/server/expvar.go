@@ -0,0 +1,51 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// newVars builds the server's counters in expvar form; they are served by
+// handleVars rather than published, so several servers can share a process
+func (s *Server) newVars() *expvar.Map {
+	vars := new(expvar.Map).Init()
+	vars.Set("active_streams", expvar.Func(func() any { return s.broadcaster.Count() }))
+	vars.Set("total_connections", expvar.Func(func() any { return s.stats.totalConnections.Load() }))
+	vars.Set("events_sent", expvar.Func(func() any { return s.stats.eventsSent.Load() }))
+	vars.Set("bytes_sent", expvar.Func(func() any { return s.stats.bytesSent.Load() }))
+	vars.Set("pool_size", expvar.Func(func() any { return s.memeService.PoolSize() }))
+	vars.Set("broadcast_seq", expvar.Func(func() any { return s.broadcaster.LastSeq() }))
+	vars.Set("uptime_seconds", expvar.Func(func() any { return s.now().Sub(s.stats.startTime).Seconds() }))
+	vars.Set("fetch_errors", expvar.Func(func() any {
+		var errors int64
+		for _, provider := range s.memeService.ProviderHealth() {
+			errors += provider.Errors
+		}
+		return errors
+	}))
+	return vars
+}
+
+// handleVars serves the process-wide expvar variables, such as cmdline and
+// memstats, alongside the server's counters in the format of /debug/vars
+func (s *Server) handleVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprintf(w, "{\n")
+	first := true
+	write := func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	}
+	expvar.Do(write)
+	s.vars.Do(func(kv expvar.KeyValue) {
+		if expvar.Get(kv.Key) == nil {
+			write(kv)
+		}
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// rssFeed is the root element of an RSS 2.0 document
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	AtomLinks     []atomLink `xml:"atom:link"`
+	Items         []rssItem  `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type rssItem struct {
+	Title     string        `xml:"title"`
+	Link      string        `xml:"link"`
+	GUID      rssGUID       `xml:"guid"`
+	PubDate   string        `xml:"pubDate,omitempty"`
+	Enclosure *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// handleFeed renders the current meme pool as an RSS feed
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	if err := s.memeService.FetchMemes(r.Context()); err != nil {
+		s.logger.Printf("Feed refresh failed, serving cached pool: %v", err)
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s%s", scheme, r.Host, s.basePath)
+
+	// WebSub subscribers need the hub and the exact topic URL it publishes
+	self := baseURL + r.URL.Path
+	if s.websubHub != "" {
+		self = s.feedTopic(r)
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, s.websubHub))
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, self))
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       "Meme Fetcher",
+			Link:        baseURL + "/",
+			Description: "The current meme pool streamed by Meme Fetcher",
+			AtomLinks: []atomLink{{
+				Href: self,
+				Rel:  "self",
+				Type: "application/rss+xml",
+			}},
+		},
+	}
+	if s.websubHub != "" {
+		feed.Channel.AtomLinks = append(feed.Channel.AtomLinks, atomLink{Href: s.websubHub, Rel: "hub"})
+	}
+
+	if lastFetch, _ := s.memeService.FetchStatus(); !lastFetch.IsZero() {
+		feed.Channel.LastBuildDate = lastFetch.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, meme := range s.memeService.GetMemes() {
+		link := meme.Permalink
+		if link == "" {
+			link = meme.URL
+		}
+
+		item := rssItem{
+			Title: meme.Title,
+			Link:  link,
+			GUID:  rssGUID{Value: link, IsPermaLink: true},
+		}
+		if meme.CreatedUTC > 0 {
+			item.PubDate = time.Unix(meme.CreatedUTC, 0).UTC().Format(time.RFC1123Z)
+		}
+		if mimeType := imageMimeType(meme.URL); mimeType != "" {
+			item.Enclosure = &rssEnclosure{URL: meme.URL, Length: meme.Size, Type: mimeType}
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		s.logger.Printf("Error encoding feed: %v", err)
+	}
+}
+
+// imageMimeType guesses the media type of an image URL from its extension
+func imageMimeType(url string) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0]))
+	if ext == "" {
+		return ""
+	}
+
+	mimeType := mime.TypeByExtension(ext)
+	if !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "video/") {
+		return ""
+	}
+	return mimeType
+}
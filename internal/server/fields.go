@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// memeEvents are the stream events whose data carries memes
+var memeEvents = map[string]bool{"": true, "trending": true, "motd": true}
+
+// fieldSelection keeps only the named fields of memes, nil to keep all
+type fieldSelection map[string]bool
+
+// parseFields reads a comma-separated ?fields= list
+func parseFields(raw string) fieldSelection {
+	var fields fieldSelection
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if fields == nil {
+				fields = make(fieldSelection)
+			}
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// project trims encoded memes to the selected fields: each element of an
+// array, an object itself, or the meme nested under "meme" (as in motd).
+// Data that is not JSON, such as template output, is returned unchanged
+func (f fieldSelection) project(data []byte) []byte {
+	if f == nil {
+		return data
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return data
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return data
+		}
+		for i, item := range items {
+			items[i] = f.project(item)
+		}
+		projected, err := json.Marshal(items)
+		if err != nil {
+			return data
+		}
+		return projected
+	case '{':
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &object); err != nil {
+			return data
+		}
+		if meme, ok := object["meme"]; ok {
+			object["meme"] = f.project(meme)
+		} else {
+			for name := range object {
+				if !f[name] {
+					delete(object, name)
+				}
+			}
+		}
+		projected, err := json.Marshal(object)
+		if err != nil {
+			return data
+		}
+		return projected
+	}
+	return data
+}
+
+// writeMemes encodes a response carrying memes, projected to ?fields=
+func (s *Server) writeMemes(w http.ResponseWriter, r *http.Request, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(parseFields(r.URL.Query().Get("fields")).project(data))
+	w.Write([]byte("\n"))
+}
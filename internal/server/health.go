@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// readiness is the body of GET /readyz
+type readiness struct {
+	Status    string                       `json:"status"` // ready or not_ready
+	PoolSize  int                          `json:"pool_size"`
+	Providers []memeservice.ProviderHealth `json:"providers"`
+}
+
+// handleReadyz reports whether the server can serve memes: the pool is filled
+// and at least one provider is not unhealthy
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	// Fill the pool on first probe; failures show up in provider health
+	s.memeService.FetchMemes(r.Context())
+
+	resp := readiness{
+		Status:    "not_ready",
+		PoolSize:  s.memeService.PoolSize(),
+		Providers: s.memeService.ProviderHealth(),
+	}
+
+	status := http.StatusServiceUnavailable
+	for _, provider := range resp.Providers {
+		if provider.Status != "unhealthy" && resp.PoolSize > 0 {
+			resp.Status = "ready"
+			status = http.StatusOK
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
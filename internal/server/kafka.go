@@ -0,0 +1,12 @@
+package server
+
+import (
+	kafkasink "github.com/elt0nxale/meme-tunnel-streamer/internal/kafkasink"
+)
+
+// SetKafkaSink mirrors every broadcast event to k and reports its counters
+// in /api/stats
+func (s *Server) SetKafkaSink(k *kafkasink.Sink) {
+	s.kafka = k
+	s.broadcaster.AddSink(k)
+}
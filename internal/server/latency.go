@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+)
+
+const (
+	// pingInterval is the delay between latency probes on a stream
+	pingInterval = 15 * time.Second
+
+	// maxPendingPings bounds how many unanswered pings a stream remembers
+	maxPendingPings = 8
+)
+
+// pingPayload is sent as `event: ping` and echoed back to the pong endpoint
+type pingPayload struct {
+	ID     int64 `json:"id"`
+	SentAt int64 `json:"sent_at"` // Unix milliseconds
+}
+
+// sendPing emits a ping event and remembers when it was sent
+func (s *Server) sendPing(st *stream) bool {
+	now := s.now()
+
+	st.pingMu.Lock()
+	st.pingSeq++
+	ping := pingPayload{ID: st.pingSeq, SentAt: now.UnixMilli()}
+	st.pings[ping.ID] = now
+
+	// Forget the oldest pings the client never answered
+	for id := range st.pings {
+		if id <= ping.ID-maxPendingPings {
+			delete(st.pings, id)
+		}
+	}
+	st.pingMu.Unlock()
+
+	data, err := json.Marshal(ping)
+	if err != nil {
+		s.logger.Printf("Error encoding ping for %s: %v", st.id, err)
+		return false
+	}
+
+	return s.sendEvent(st, broadcaster.Event{Name: "ping", Data: data})
+}
+
+// handlePong records the round-trip time of an acknowledged ping
+func (s *Server) handlePong(w http.ResponseWriter, r *http.Request) {
+	st, ok := s.ownedStream(w, r)
+	if !ok {
+		return
+	}
+	connID := st.id
+
+	var pong pingPayload
+	if err := json.NewDecoder(r.Body).Decode(&pong); err != nil {
+		apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("invalid pong: %v", err)))
+		return
+	}
+
+	st.pingMu.Lock()
+	sentAt, pending := st.pings[pong.ID]
+	delete(st.pings, pong.ID)
+	st.pingMu.Unlock()
+
+	if !pending {
+		apierror.Write(w, r, apierror.NotFound(fmt.Sprintf("unknown ping %d", pong.ID)))
+		return
+	}
+
+	s.connectionManager.RecordLatency(connID, s.now().Sub(sentAt))
+	w.WriteHeader(http.StatusNoContent)
+}
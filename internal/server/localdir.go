@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"os"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// localPath serves the files of the local meme directory
+const localPath = "/local/"
+
+// SetLocalDir streams the images and videos in dir as memes, served from
+// /local/<name>; call after SetBasePath, as the memes link under it
+func (s *Server) SetLocalDir(dir string) error {
+	if err := s.memeService.SetLocalDir(dir, s.basePath+localPath); err != nil {
+		return err
+	}
+	s.localDir = dir
+	return nil
+}
+
+// handleLocalFile serves a file of the local meme directory, leaving out
+// sidecar titles and anything else the provider does not stream
+func (s *Server) handleLocalFile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !memeservice.IsLocalFile(name) {
+		apierror.Write(w, r, apierror.NotFound("file not found"))
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeFileFS(w, r, os.DirFS(s.localDir), name)
+}
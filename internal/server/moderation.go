@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	submissions "github.com/elt0nxale/meme-tunnel-streamer/internal/submissions"
+)
+
+// maxModerationBody bounds the body of POST /admin/moderation
+const maxModerationBody = 16 << 10
+
+// moderationActions maps the actions of POST /admin/moderation to submission statuses
+var moderationActions = map[string]string{
+	"approve": submissions.StatusApproved,
+	"reject":  submissions.StatusRejected,
+}
+
+// handleModerationQueue lists submissions awaiting review, or those in
+// another ?status= (approved, rejected, or all)
+func (s *Server) handleModerationQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = submissions.StatusPending
+	case "all":
+		status = ""
+	case submissions.StatusPending, submissions.StatusApproved, submissions.StatusRejected:
+	default:
+		apierror.Write(w, r, apierror.BadRequest("status must be pending, approved, rejected or all"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.submissions.List(status))
+}
+
+// handleModerate approves a submission into the pool, or rejects it (taking
+// it out of the pool if it was approved), from {"id", "action", "reason"}
+func (s *Server) handleModerate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID     string `json:"id"`
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxModerationBody)).Decode(&body); err != nil || body.ID == "" {
+		apierror.Write(w, r, apierror.BadRequest("body must be JSON with id and action"))
+		return
+	}
+	status, ok := moderationActions[body.Action]
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("action must be approve or reject"))
+		return
+	}
+
+	sub, err := s.submissions.Moderate(body.ID, status, s.requestActor(r), body.Reason)
+	s.auditRequest(r, "submission."+body.Action, map[string]string{"id": body.ID, "reason": body.Reason}, err)
+	switch {
+	case errors.Is(err, submissions.ErrNotFound):
+		apierror.Write(w, r, apierror.NotFound(err.Error()))
+		return
+	case errors.Is(err, submissions.ErrRejected):
+		apierror.Write(w, r, apierror.New(http.StatusConflict, "already_rejected", err.Error()))
+		return
+	case err != nil && sub.Meme.ID == "":
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Re-approving is a no-op for the pool, so drop any copy before adding
+	s.memeService.RemoveMemes(sub.Meme.Key())
+	if sub.Status == submissions.StatusApproved {
+		s.memeService.AddMemes(sub.Meme)
+	}
+	if err != nil {
+		s.logger.Printf("Moderation of %s: %v", sub.Meme.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// motdResponse is the body of GET /api/motd and motd events
+type motdResponse struct {
+	Date string           `json:"date"`
+	Meme memeservice.Meme `json:"meme"`
+}
+
+// SetMotdLocation sets the timezone whose midnight starts a new meme of the day
+func (s *Server) SetMotdLocation(loc *time.Location) {
+	s.motdLocation = loc
+}
+
+// motdDate returns today's calendar date in the meme-of-the-day timezone
+func (s *Server) motdDate(now time.Time) string {
+	return now.In(s.motdLocation).Format(time.DateOnly)
+}
+
+// handleMotd serves today's meme of the day
+func (s *Server) handleMotd(w http.ResponseWriter, r *http.Request) {
+	if date, meme, ok := s.motd(w, r); ok {
+		s.writeMemes(w, r, motdResponse{Date: date, Meme: meme})
+	}
+}
+
+// motd returns today's date and meme of the day, writing the error response
+// when the pool cannot provide one
+func (s *Server) motd(w http.ResponseWriter, r *http.Request) (string, memeservice.Meme, bool) {
+	if err := s.memeService.FetchMemes(r.Context()); err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", err))
+		return "", memeservice.Meme{}, false
+	}
+
+	date := s.motdDate(s.now())
+	meme, ok := s.memeService.MemeOfTheDay(date)
+	if !ok {
+		apierror.Write(w, r, apierror.New(http.StatusServiceUnavailable, "pool_empty", "no memes available yet"))
+		return "", memeservice.Meme{}, false
+	}
+	return date, meme, true
+}
+
+// BroadcastMotd announces the new meme of the day to every stream at each
+// midnight in the configured timezone until ctx is cancelled
+func (s *Server) BroadcastMotd(ctx context.Context) {
+	go func() {
+		for {
+			now := s.now().In(s.motdLocation)
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, s.motdLocation)
+
+			timer := time.NewTimer(midnight.Sub(s.now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := s.memeService.FetchMemes(ctx); err != nil {
+				s.logger.Printf("Meme of the day refresh failed: %v", err)
+			}
+
+			date := s.motdDate(midnight)
+			meme, ok := s.memeService.MemeOfTheDay(date)
+			if !ok {
+				continue
+			}
+
+			data, err := json.Marshal(motdResponse{Date: date, Meme: meme})
+			if err != nil {
+				s.logger.Printf("Error encoding motd event: %v", err)
+				continue
+			}
+			s.broadcaster.Broadcast(broadcaster.Event{Name: "motd", Data: data})
+			s.pushMeme("motd", "Meme of the day", meme)
+			s.logger.Printf("Meme of the day for %s: %s", date, meme.Title)
+		}
+	}()
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	connectionmanager "github.com/elt0nxale/meme-tunnel-streamer/internal/connectionmanager"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// Option configures a Server at construction, e.g. to inject fakes in tests
+type Option func(*Server)
+
+// WithMemeService sets the meme pool streams draw from
+func WithMemeService(memeService *memeservice.Service) Option {
+	return func(s *Server) { s.memeService = memeService }
+}
+
+// WithConnectionManager replaces the connection log, e.g. to keep more than 50 connections
+func WithConnectionManager(manager *connectionmanager.Manager) Option {
+	return func(s *Server) { s.connectionManager = manager }
+}
+
+// WithBroadcaster replaces the event hub, e.g. to change its per-stream buffer
+func WithBroadcaster(b *broadcaster.Broadcaster) Option {
+	return func(s *Server) { s.broadcaster = b }
+}
+
+// WithLogger sends the server's logs somewhere other than the standard logger
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) { s.logger = logger }
+}
+
+// WithClock replaces time.Now for stats, quotas, quiet hours, the meme of the
+// day and recently-sent suppression
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) { s.now = now }
+}
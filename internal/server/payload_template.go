@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// payloadFuncs are available to payload templates on top of the built-ins
+var payloadFuncs = template.FuncMap{
+	// json encodes a value, e.g. {{json .Title}} for a quoted, escaped string
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+// ParsePayloadTemplate parses a text/template rendering each meme's event
+// data, checking it against an empty meme so unknown fields fail early
+func ParsePayloadTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("payload").Funcs(payloadFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, memePayload{}); err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// SetPayloadTemplate renders meme events with tmpl instead of the default JSON
+func (s *Server) SetPayloadTemplate(tmpl *template.Template) {
+	s.payloadTemplate = tmpl
+}
+
+// encodeMeme renders a meme event's data
+func (s *Server) encodeMeme(payload memePayload) ([]byte, error) {
+	if s.payloadTemplate == nil {
+		return json.Marshal(payload)
+	}
+
+	var buf bytes.Buffer
+	if err := s.payloadTemplate.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	// A template file's final newline is not part of the data
+	return bytes.TrimRight(buf.Bytes(), "\r\n"), nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	webpush "github.com/elt0nxale/meme-tunnel-streamer/internal/webpush"
+)
+
+const (
+	// pushTTL is how long push services hold a notification for an offline browser
+	pushTTL = 6 * time.Hour
+
+	// maxSubscriptionBytes caps the body of a subscribe request
+	maxSubscriptionBytes = 4 << 10
+)
+
+// PushEvents are the events that can trigger Web Push notifications
+var PushEvents = []string{"trending", "motd"}
+
+// SetPusher enables Web Push for the given events, e.g. trending and motd
+func (s *Server) SetPusher(p *webpush.Pusher, events []string) {
+	s.pusher = p
+	s.pushEvents = events
+}
+
+// handlePushKey returns the VAPID public key the client page subscribes with
+func (s *Server) handlePushKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"public_key": s.pusher.PublicKey(),
+		"events":     s.pushEvents,
+	}); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
+
+// handlePushSubscribe stores a browser's PushSubscription for the identified
+// caller; anonymous clients could otherwise fill the store
+func (s *Server) handlePushSubscribe(w http.ResponseWriter, r *http.Request) {
+	actor := s.requestActor(r)
+	if actor == "anonymous" {
+		unauthorized(w, r, "log in or use an api key to subscribe")
+		return
+	}
+
+	var sub webpush.Subscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBytes)).Decode(&sub); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid subscription"))
+		return
+	}
+	if err := sub.Validate(); err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+	sub.Owner = actor
+	if err := s.pusher.Subscribe(sub); err != nil {
+		switch {
+		case errors.Is(err, webpush.ErrSubscriptionLimit):
+			apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, "subscription_limit", err.Error()))
+		case errors.Is(err, webpush.ErrNotOwner):
+			apierror.Write(w, r, apierror.Forbidden(err.Error()))
+		default:
+			apierror.Write(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handlePushUnsubscribe forgets a subscription by its endpoint; only the caller
+// that stored it, or the admin token, may remove it
+func (s *Server) handlePushUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	actor := s.requestActor(r)
+	if actor == "anonymous" {
+		unauthorized(w, r, "log in or use an api key to unsubscribe")
+		return
+	}
+
+	var body struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBytes)).Decode(&body); err != nil || body.Endpoint == "" {
+		apierror.Write(w, r, apierror.BadRequest("endpoint is required"))
+		return
+	}
+	owner := actor
+	if actor == "admin" {
+		owner = ""
+	}
+	if err := s.pusher.Unsubscribe(body.Endpoint, owner); err != nil {
+		apierror.Write(w, r, apierror.Forbidden(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pushMeme notifies every subscribed browser about a meme, if event is enabled
+func (s *Server) pushMeme(event, title string, meme memeservice.Meme) {
+	if s.pusher == nil || !slices.Contains(s.pushEvents, event) {
+		return
+	}
+
+	n := webpush.Notification{
+		Title: title,
+		Body:  meme.Title,
+		URL:   s.basePath + "/",
+		Tag:   event,
+	}
+	if meme.MediaType != memeservice.MediaVideo {
+		n.Image = meme.URL
+	}
+
+	go func() {
+		sent, failed := s.pusher.Broadcast(context.Background(), n, pushTTL)
+		s.logger.Printf("Pushed %s notification: %d sent, %d failed", event, sent, failed)
+	}()
+}
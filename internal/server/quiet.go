@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+)
+
+// quietCheckInterval is how often quiet hours are re-evaluated
+const quietCheckInterval = 30 * time.Second
+
+// QuietHours is a daily window during which streams pause or slow down and
+// upstream refreshes stop
+type QuietHours struct {
+	Start, End   time.Duration // Offsets from midnight; End before Start spans midnight
+	Location     *time.Location
+	Slow         bool          // Slow streams down instead of pausing them
+	SlowInterval time.Duration // Delay between memes while slowed
+}
+
+// ParseQuietHours parses a "22:00-07:00" window
+func ParseQuietHours(spec string) (QuietHours, error) {
+	startRaw, endRaw, found := strings.Cut(spec, "-")
+	if !found {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", spec)
+	}
+
+	start, err := parseTimeOfDay(startRaw)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	end, err := parseTimeOfDay(endRaw)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	if start == end {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: start equals end", spec)
+	}
+
+	return QuietHours{Start: start, End: end, Location: time.UTC}, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into an offset from midnight
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", raw)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active reports whether t falls inside the window
+func (q QuietHours) Active(t time.Time) bool {
+	t = t.In(q.Location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// remaining returns how long until the window closes, for t inside it
+func (q QuietHours) remaining(t time.Time) time.Duration {
+	t = t.In(q.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.Location)
+
+	until := q.End - t.Sub(midnight)
+	if until <= 0 {
+		until += 24 * time.Hour
+	}
+	return until
+}
+
+// quietStatus is the state quiet hours impose on streams
+func (q QuietHours) quietStatus() string {
+	if q.Slow {
+		return "slowed"
+	}
+	return "paused"
+}
+
+// SetQuietHours enables quiet hours; call RunQuietHours to start enforcing them
+func (s *Server) SetQuietHours(q QuietHours) {
+	s.quietHours = &q
+}
+
+// RunQuietHours switches quiet mode on and off as the window opens and closes
+// until ctx is cancelled
+func (s *Server) RunQuietHours(ctx context.Context) {
+	if s.quietHours == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(quietCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			s.setQuiet(s.quietHours.Active(s.now()))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// setQuiet applies a quiet hours transition and tells every stream about it
+func (s *Server) setQuiet(quiet bool) {
+	if s.quiet.Swap(quiet) == quiet {
+		return
+	}
+
+	s.memeService.SetRefreshPaused(quiet)
+	if quiet {
+		s.logger.Printf("Quiet hours started: streams %s, refreshes stopped", s.quietHours.quietStatus())
+	} else {
+		s.logger.Printf("Quiet hours ended: streams resumed")
+	}
+
+	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+
+	for connID, st := range s.streams {
+		status := s.streamStatus(st)
+		status.Reason = "quiet_hours"
+		if data, err := json.Marshal(status); err == nil {
+			s.broadcaster.Send(connID, broadcaster.Event{Name: "status", Data: data})
+		}
+	}
+}
+
+// quietPaused reports whether quiet hours currently hold back timed memes
+func (s *Server) quietPaused() bool {
+	return s.quiet.Load() && !s.quietHours.Slow
+}
+
+// memeIntervalNow is the delay between timed memes, stretched during slowed quiet hours
+func (s *Server) memeIntervalNow() time.Duration {
+	return s.quietInterval(time.Duration(s.memeInterval.Load()))
+}
+
+// quietInterval stretches a delay between memes during slowed quiet hours
+func (s *Server) quietInterval(interval time.Duration) time.Duration {
+	if s.quiet.Load() && s.quietHours.Slow {
+		interval = max(interval, s.quietHours.SlowInterval)
+	}
+	return interval
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	connectionmanager "github.com/elt0nxale/meme-tunnel-streamer/internal/connectionmanager"
+)
+
+// SetAPIKeys configures the keys accepted on public API routes; none disables key auth
+func (s *Server) SetAPIKeys(keys []auth.APIKey) {
+	s.setKeys(keys, "startup")
+}
+
+// setKeys replaces the API keys, auditing the change when the key set differs
+func (s *Server) setKeys(keys []auth.APIKey, actor string) {
+	previous := s.keys.Keys()
+	s.keys.Set(keys)
+
+	if slices.Equal(previous, keys) {
+		return
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Name
+	}
+	s.RecordAudit(actor, "keys.update", map[string]string{"keys": strings.Join(names, ",")}, nil)
+}
+
+// streamRetryAfter is how long clients are asked to wait when all of a key's stream slots are taken
+const streamRetryAfter = 30 * time.Second
+
+// acquireStreamQuota reserves a stream for the request's key, writing a 429 with
+// Retry-After when the key is over quota; the returned release func must be
+// called when the stream ends
+func (s *Server) acquireStreamQuota(w http.ResponseWriter, r *http.Request, key auth.APIKey) (func(), bool) {
+	if key.Name == "" {
+		return func() {}, true
+	}
+
+	if remaining := s.setRateLimitHeaders(w, key); remaining == 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(connectionmanager.QuotaReset(s.now()).Sub(s.now())))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, "quota_exhausted", "daily event quota exhausted"))
+		return nil, false
+	}
+	if !s.connectionManager.AcquireStream(key.Name, key.MaxStreams) {
+		w.Header().Set("Retry-After", retryAfterSeconds(streamRetryAfter))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, "stream_quota_exceeded", "concurrent stream quota exceeded"))
+		return nil, false
+	}
+
+	return func() { s.connectionManager.ReleaseStream(key.Name) }, true
+}
+
+// setRateLimitHeaders reports a key's daily event quota in X-RateLimit-* headers
+// and returns the remaining events, or -1 when the key is unlimited
+func (s *Server) setRateLimitHeaders(w http.ResponseWriter, key auth.APIKey) int {
+	remaining := s.connectionManager.KeyEventsRemaining(key.Name, key.EventsPerDay)
+	if remaining < 0 {
+		return remaining
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.EventsPerDay))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(connectionmanager.QuotaReset(s.now()).Unix(), 10))
+	return remaining
+}
+
+// retryAfterSeconds formats a delay for Retry-After, rounding up to whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
+
+// handleKeyUsage reports per-key quota consumption
+func (s *Server) handleKeyUsage(w http.ResponseWriter, r *http.Request) {
+	limits := make(map[string]auth.APIKey)
+	for _, key := range s.keys.Keys() {
+		limits[key.Name] = key
+	}
+
+	usage := s.connectionManager.KeyUsageReport(func(name string) (int, int) {
+		key := limits[name]
+		return key.MaxStreams, key.EventsPerDay
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+)
+
+// SetOIDC gates debugger and admin routes behind OpenID Connect login
+func (s *Server) SetOIDC(o *auth.OIDC) {
+	s.oidc = o
+}
+
+// requireRole enforces a route group's role. The admin token always acts as admin,
+// then a login session, then an API key. Without API keys configured, viewer
+// routes stay open; so do debugger routes unless OIDC login is enabled. Admin
+// routes always need an identity.
+func (s *Server) requireRole(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminToken(r) {
+			next(w, r)
+			return
+		}
+
+		if s.oidc != nil {
+			if session, ok := s.oidc.SessionFromRequest(r); ok {
+				if !session.Role.Allows(role) {
+					apierror.Write(w, r, apierror.Forbidden("role "+session.Role.String()+" cannot access this route"))
+					return
+				}
+				next(w, r.WithContext(auth.NewSessionContext(r.Context(), session)))
+				return
+			}
+		}
+
+		if s.keys.Enabled() {
+			key, ok := s.keys.Lookup(r)
+			if ok {
+				if !key.Role.Allows(role) {
+					apierror.Write(w, r, apierror.Forbidden("api key role "+key.Role.String()+" cannot access this route"))
+					return
+				}
+				next(w, r.WithContext(auth.NewContext(r.Context(), key)))
+				return
+			}
+		} else if role == auth.RoleViewer || (role == auth.RoleDebugger && s.oidc == nil) {
+			next(w, r)
+			return
+		}
+
+		switch {
+		case s.oidc != nil && role != auth.RoleViewer && r.Method == http.MethodGet &&
+			strings.Contains(r.Header.Get("Accept"), "text/html"):
+			// Send browsers through the identity provider and back
+			http.Redirect(w, r, s.basePath+"/auth/login?next="+url.QueryEscape(r.RequestURI), http.StatusFound)
+		case s.keys.Enabled():
+			unauthorized(w, r, "missing or invalid api key")
+		case s.adminToken == "" && s.oidc == nil:
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, "not_configured", "admin token not configured"))
+		default:
+			unauthorized(w, r, "unauthorized")
+		}
+	}
+}
+
+// isAdminToken reports whether the request presents the configured admin token
+func (s *Server) isAdminToken(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// unauthorized writes a 401 with a bearer challenge
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="meme-fetcher"`)
+	apierror.Write(w, r, apierror.Unauthorized(message))
+}
+
+// requireAdminToken restricts a route to holders of the admin token, for
+// operations on the host itself that no API key or login session may trigger
+func (s *Server) requireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case s.isAdminToken(r):
+			next(w, r)
+		case s.adminToken == "":
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, "not_configured", "admin token not configured"))
+		default:
+			unauthorized(w, r, "admin token required")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// recentEntry is the send history of a single subscriber
+type recentEntry struct {
+	keys     []string
+	sentAt   []time.Time
+	lastSeen time.Time
+}
+
+// recentHistory remembers the last memes sent to each subscriber so they are not
+// repeated within the suppression window, even across reconnects
+type recentHistory struct {
+	mu      sync.Mutex
+	entries map[string]*recentEntry
+	size    int
+	window  time.Duration
+	now     func() time.Time
+}
+
+// newRecentHistory creates a history tracking up to size memes per subscriber for window
+func newRecentHistory(size int, window time.Duration) *recentHistory {
+	return &recentHistory{
+		entries: make(map[string]*recentEntry),
+		size:    size,
+		window:  window,
+		now:     time.Now,
+	}
+}
+
+// Configure changes the history limits while keeping what has been recorded
+func (h *recentHistory) Configure(size int, window time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.size = size
+	h.window = window
+}
+
+// Exclusions returns the meme keys sent to a subscriber within the window
+func (h *recentHistory) Exclusions(subscriber string) map[string]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, exists := h.entries[subscriber]
+	if !exists {
+		return nil
+	}
+
+	cutoff := h.now().Add(-h.window)
+	exclude := make(map[string]bool, len(entry.keys))
+	for i, key := range entry.keys {
+		if entry.sentAt[i].After(cutoff) {
+			exclude[key] = true
+		}
+	}
+	return exclude
+}
+
+// Record notes that a meme was sent to a subscriber
+func (h *recentHistory) Record(subscriber, key string) {
+	if h.size <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.prune(now)
+
+	entry, exists := h.entries[subscriber]
+	if !exists {
+		entry = &recentEntry{}
+		h.entries[subscriber] = entry
+	}
+
+	entry.keys = append(entry.keys, key)
+	entry.sentAt = append(entry.sentAt, now)
+	if len(entry.keys) > h.size {
+		entry.keys = entry.keys[len(entry.keys)-h.size:]
+		entry.sentAt = entry.sentAt[len(entry.sentAt)-h.size:]
+	}
+	entry.lastSeen = now
+}
+
+// prune drops subscribers whose history has aged out of the window
+func (h *recentHistory) prune(now time.Time) {
+	cutoff := now.Add(-h.window)
+	for subscriber, entry := range h.entries {
+		if entry.lastSeen.Before(cutoff) {
+			delete(h.entries, subscriber)
+		}
+	}
+}
+
+// Purge forgets subscribers last sent a meme before cutoff, regardless of the
+// window, returning how many were forgotten
+func (h *recentHistory) Purge(cutoff time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	purged := 0
+	for subscriber, entry := range h.entries {
+		if entry.lastSeen.Before(cutoff) {
+			delete(h.entries, subscriber)
+			purged++
+		}
+	}
+	return purged
+}
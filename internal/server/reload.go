@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	config "github.com/elt0nxale/meme-tunnel-streamer/internal/config"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// ApplyConfig updates reloadable settings without touching active streams. A
+// list present in the file replaces the current one even when empty, so keys
+// can be revoked and sources dropped; absent lists are left alone
+func (s *Server) ApplyConfig(cfg *config.Config) error {
+	// Validate everything before changing anything
+	var sources []memeservice.Source
+	for _, spec := range deref(cfg.Sources) {
+		source, err := memeservice.ParseSource(spec)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+
+	var mastodon []memeservice.MastodonSource
+	for _, spec := range deref(cfg.Mastodon) {
+		source, err := memeservice.ParseMastodonSource(spec)
+		if err != nil {
+			return err
+		}
+		mastodon = append(mastodon, source)
+	}
+
+	var custom []memeservice.CustomSource
+	for _, c := range deref(cfg.Custom) {
+		if slices.ContainsFunc(custom, func(s memeservice.CustomSource) bool { return s.Name == c.Name }) {
+			return fmt.Errorf("duplicate custom source %q", c.Name)
+		}
+		header := http.Header{}
+		for name, value := range c.Headers {
+			header.Set(name, value)
+		}
+		source, err := memeservice.NewCustomSource(c.Name, c.URL, header, c.TTL, memeservice.CustomFields{
+			Items:     c.Items,
+			ID:        c.ID,
+			Title:     c.Title,
+			URL:       c.Image,
+			Permalink: c.Permalink,
+			Score:     c.Score,
+			NSFW:      c.NSFW,
+		})
+		if err != nil {
+			return err
+		}
+		custom = append(custom, source)
+	}
+
+	var keys []auth.APIKey
+	for _, spec := range deref(cfg.APIKeys) {
+		key, err := auth.ParseAPIKey(spec)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	var cohorts []Cohort
+	for _, spec := range deref(cfg.Cohorts) {
+		cohort, err := ParseCohort(spec)
+		if err != nil {
+			return err
+		}
+		cohorts = append(cohorts, cohort)
+	}
+
+	filter := s.DefaultFilter()
+	if cfg.Media != nil {
+		media, err := memeservice.ParseMediaTypes(strings.Join(*cfg.Media, ","))
+		if err != nil {
+			return fmt.Errorf("invalid media: %v", err)
+		}
+		filter.MediaTypes = media
+	}
+	if cfg.Tags != nil {
+		filter.Tags = memeservice.ParseTags(strings.Join(*cfg.Tags, ","))
+	}
+	if cfg.MaxContentSize != nil {
+		maxBytes, err := memeservice.ParseByteSize(*cfg.MaxContentSize)
+		if err != nil {
+			return fmt.Errorf("invalid max_content_size: %v", err)
+		}
+		filter.MaxBytes = maxBytes
+	}
+
+	if cfg.Cohorts != nil {
+		if err := s.SetCohorts(cohorts); err != nil {
+			return err
+		}
+	}
+	if cfg.Sources != nil {
+		s.memeService.SetSources(sources)
+	}
+	if cfg.Mastodon != nil {
+		s.memeService.SetMastodonSources(mastodon)
+	}
+	if cfg.Custom != nil {
+		s.memeService.SetCustomSources(custom)
+	}
+	if cfg.APIKeys != nil {
+		s.setKeys(keys, "config")
+	}
+	s.SetDefaultFilter(filter)
+	if cfg.MemeInterval > 0 {
+		s.memeInterval.Store(int64(cfg.MemeInterval))
+	}
+	if cfg.RecentSize > 0 || cfg.RecentWindow > 0 {
+		s.recent.mu.Lock()
+		size, window := s.recent.size, s.recent.window
+		s.recent.mu.Unlock()
+
+		if cfg.RecentSize > 0 {
+			size = cfg.RecentSize
+		}
+		if cfg.RecentWindow > 0 {
+			window = cfg.RecentWindow
+		}
+		s.recent.Configure(size, window)
+	}
+
+	return nil
+}
+
+// deref returns the items of an optional config list, nil when it is absent
+func deref[T any](items *[]T) []T {
+	if items == nil {
+		return nil
+	}
+	return *items
+}
+
+// SetReloader registers the function used by POST /admin/reload
+func (s *Server) SetReloader(reload func() error) {
+	s.reload = reload
+}
+
+// handleReload reloads the config file on demand
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if s.reload == nil {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "not_configured", "no config file in use"))
+		return
+	}
+
+	err := s.reload()
+	s.auditRequest(r, "config.reload", nil, err)
+	if err != nil {
+		s.logger.Printf("Config reload failed: %v", err)
+		apierror.Write(w, r, apierror.Wrap(http.StatusUnprocessableEntity, "reload_failed", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
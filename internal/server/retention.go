@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+)
+
+// maxRetentionSweep is the longest time between retention sweeps
+const maxRetentionSweep = time.Minute
+
+// SetLogRetention keeps ended connection logs and per-subscriber send history
+// for ttl; call RunLogRetention to start purging. Zero keeps them until evicted
+func (s *Server) SetLogRetention(ttl time.Duration) {
+	s.logRetention = ttl
+}
+
+// RunLogRetention purges records older than the retention period in the
+// background until ctx is cancelled
+func (s *Server) RunLogRetention(ctx context.Context) {
+	if s.logRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(min(s.logRetention, maxRetentionSweep))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.purgeExpired()
+			}
+		}
+	}()
+}
+
+// purgeExpired drops records that have outlived the retention period
+func (s *Server) purgeExpired() {
+	cutoff := s.now().Add(-s.logRetention)
+	connections := s.connectionManager.PurgeClosed(cutoff)
+	history := s.recent.Purge(cutoff)
+	if connections > 0 || history > 0 {
+		s.logger.Printf("Retention: purged %d connection logs and %d send histories older than %v",
+			connections, history, s.logRetention)
+	}
+}
+
+// handlePurgeConnections deletes the logs of ended connections, optionally
+// only those ended more than ?older_than= ago
+func (s *Server) handlePurgeConnections(w http.ResponseWriter, r *http.Request) {
+	var cutoff time.Time
+	params := map[string]string{}
+	if raw := r.URL.Query().Get("older_than"); raw != "" {
+		age, err := time.ParseDuration(raw)
+		if err != nil || age < 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid older_than, expected a duration such as 1h"))
+			return
+		}
+		cutoff = s.now().Add(-age)
+		params["older_than"] = raw
+	}
+
+	purged := s.connectionManager.PurgeClosed(cutoff)
+	params["purged"] = strconv.Itoa(purged)
+	s.auditRequest(r, "debug.purge", params, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"purged": purged}); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+)
+
+const (
+	// DefaultSSERetry is the reconnection delay advised when nothing calls
+	// for a longer one, close to what browsers use on their own
+	DefaultSSERetry = 3 * time.Second
+
+	// DefaultSSERetryMax caps the advised reconnection delay
+	DefaultSSERetryMax = 5 * time.Minute
+
+	// DefaultSSERetryLoad is the number of active streams above which the
+	// advised delay grows with load
+	DefaultSSERetryLoad = 1000
+
+	// retryJitter spreads each advised delay by up to this fraction either
+	// way, so clients dropped together do not reconnect together
+	retryJitter = 0.2
+)
+
+// retryPolicy decides the SSE retry field sent to streams
+type retryPolicy struct {
+	base time.Duration // Delay under normal conditions, zero to leave it to clients
+	max  time.Duration // Longest delay advised
+	load int           // Active streams before the delay grows, zero to ignore load
+}
+
+// SetSSERetry advises clients how long to wait before reconnecting: base
+// normally, growing in steps of base once more than load streams are open,
+// and longer while the client's key has no stream slots to spare or quiet
+// hours are on, up to max. A zero base leaves reconnection to client defaults
+func (s *Server) SetSSERetry(base, max time.Duration, load int) error {
+	if base < 0 || max < 0 || load < 0 {
+		return fmt.Errorf("SSE retry settings cannot be negative")
+	}
+	if base > 0 && max < base {
+		return fmt.Errorf("maximum SSE retry %v is below the base %v", max, base)
+	}
+	s.retry = retryPolicy{base: base, max: max, load: load}
+	return nil
+}
+
+// retryDelay is the reconnection delay a stream should be advised now,
+// before jitter; zero when the server leaves it to clients
+func (s *Server) retryDelay(st *stream) time.Duration {
+	p := s.retry
+	if p.base <= 0 {
+		return 0
+	}
+
+	// Whole steps of the base so small swings in load are not re-sent
+	d := p.base
+	if active := s.broadcaster.Count(); p.load > 0 && active > p.load {
+		d *= time.Duration((active + p.load - 1) / p.load)
+	}
+
+	// A key using all its slots turns new streams away; wait as long as they are told to
+	if st.key.MaxStreams > 0 && s.connectionManager.KeyStreams(st.key.Name) >= st.key.MaxStreams {
+		d = max(d, streamRetryAfter)
+	}
+
+	if s.quiet.Load() {
+		if s.quietHours.Slow {
+			d = max(d, s.quietHours.SlowInterval)
+		} else {
+			// Nothing is sent until the window closes, rounded up to the minute
+			d = max(d, s.quietHours.remaining(s.now()).Truncate(time.Minute)+time.Minute)
+		}
+	}
+	return min(d, p.max)
+}
+
+// adviseRetry sets the retry field of an event when the delay a stream
+// should be advised has changed since it was last told
+func (s *Server) adviseRetry(st *stream, event *broadcaster.Event) {
+	if event.Retry > 0 {
+		return
+	}
+	d := s.retryDelay(st)
+	if d == st.retry {
+		return
+	}
+	st.retry = d
+	event.Retry = jitter(d)
+}
+
+// jitter spreads d by up to retryJitter either way
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((rand.Float64()*2-1)*retryJitter*float64(d))
+}
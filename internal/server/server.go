@@ -1,45 +1,495 @@
 package server
 
 import (
-	"embed"
+	"context"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
+	"math/rand"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	texttemplate "text/template"
 	"time"
 
-	connectionmanager "meme-fetcher/internal/connectionmanager"
-	memeservice "meme-fetcher/internal/memeservice"
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	audit "github.com/elt0nxale/meme-tunnel-streamer/internal/audit"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	blocklist "github.com/elt0nxale/meme-tunnel-streamer/internal/blocklist"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	caption "github.com/elt0nxale/meme-tunnel-streamer/internal/caption"
+	connectionmanager "github.com/elt0nxale/meme-tunnel-streamer/internal/connectionmanager"
+	imageproxy "github.com/elt0nxale/meme-tunnel-streamer/internal/imageproxy"
+	journal "github.com/elt0nxale/meme-tunnel-streamer/internal/journal"
+	kafkasink "github.com/elt0nxale/meme-tunnel-streamer/internal/kafkasink"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	s3archive "github.com/elt0nxale/meme-tunnel-streamer/internal/s3archive"
+	submissions "github.com/elt0nxale/meme-tunnel-streamer/internal/submissions"
+	webpush "github.com/elt0nxale/meme-tunnel-streamer/internal/webpush"
 )
 
+// defaultMemeInterval is the delay between memes sent to a stream
+const defaultMemeInterval = 10 * time.Second
+
+// RouteSet selects which routes a listener serves
+type RouteSet string
+
+const (
+	RoutesAll    RouteSet = "all"    // Public and admin routes
+	RoutesPublic RouteSet = "public" // Stream, client page and stream controls
+	RoutesAdmin  RouteSet = "admin"  // Statistics and debugging endpoints
+)
+
+// ParseRouteSet validates a route set name
+func ParseRouteSet(name string) (RouteSet, error) {
+	switch set := RouteSet(name); set {
+	case RoutesAll, RoutesPublic, RoutesAdmin:
+		return set, nil
+	default:
+		return "", fmt.Errorf("unknown route set %q, expected all, public or admin", name)
+	}
+}
+
+// memePayload is the JSON body of a meme event
+type memePayload struct {
+	memeservice.Meme
+	ConnID    string `json:"connID"`
+	ProxyURL  string `json:"proxy_url,omitempty"`  // Signed /img URL when the image proxy is enabled
+	ImageData string `json:"image_data,omitempty"` // Data URI of a small image with ?inline_images=1
+}
+
 type Server struct {
 	memeService       *memeservice.Service
 	connectionManager *connectionmanager.Manager
-	content           embed.FS
+	broadcaster       *broadcaster.Broadcaster
+	content           fs.FS
+	logger            *log.Logger
+	now               func() time.Time                  // Clock, time.Now unless injected
+	middleware        []Middleware                      // Applied to every route set, outermost first
+	serializers       map[string]broadcaster.Serializer // Wire formats by stream endpoint path
+	payloadTemplate   *texttemplate.Template            // Renders meme events, nil for JSON
+	stats             stats
+	tunnelURL         atomic.Value
+
+	rngMu sync.Mutex
+	rng   *rand.Rand // Seeds per-connection generators
+
+	streamsMu sync.RWMutex
+	streams   map[string]*stream
+
+	recent *recentHistory
+
+	basePath  string    // Prefix all routes are mounted under, empty for the root
+	debugPath string    // Path of the connection log dump, empty when disabled
+	apiSunset time.Time // When the unversioned /api aliases go away, zero if unannounced
+
+	submissions *submissions.Store // User-submitted memes, nil when submissions are disabled
+	maxUpload   int64              // Largest accepted image upload in bytes
+	localDir    string             // Directory streamed by the local provider, empty when unused
+
+	captions         *caption.Store // Broadcast captions, nil when POST /api/caption is disabled
+	captionTemplates string         // Directory of caption templates, empty for URLs only
+	captionClient    *http.Client   // Fetches caption images from public addresses only
+
+	blocklist       *blocklist.List // Content hashes refused in submissions, nil when unused
+	blocklistClient *http.Client    // Fetches submitted URLs from public addresses only
+
+	kafka           *kafkasink.Sink     // Mirror of broadcast events, nil when unused
+	archiver        *s3archive.Archiver // Object storage copy of the pool, nil when unused
+	archiveInterval time.Duration       // Delay between archive runs
+
+	vars *expvar.Map // Core counters served at the debug path's /vars
+
+	websubHub     string       // Hub pinged when memes enter the pool, empty when WebSub is off
+	websubTopic   string       // Public feed URL, empty to derive it from the tunnel
+	websubClient  *http.Client // Pings the hub
+	websubPending atomic.Bool  // Whether a ping is waiting to be sent
+
+	logRetention time.Duration // Age at which ended connection logs and send history are purged
+
+	memeInterval atomic.Int64 // Delay between pushed memes, reloadable
+	experiment   experiment   // A/B cohorts streams are split between, reloadable
+	reload       func() error // Reloads the config file, nil when none is used
+
+	adminToken     string         // Bearer token for sensitive admin endpoints
+	allowedOrigins []string       // Cross-site origins allowed to open WebSockets
+	keys           *auth.KeyStore // API keys for public routes, reloadable
+	audit          *audit.Log     // Trail of admin actions
+	oidc           *auth.OIDC     // Login for debugger and admin routes, nil when disabled
+
+	imageProxy *imageproxy.Proxy // Relays images through signed URLs, nil when disabled
+
+	motdLocation *time.Location // Timezone whose midnight rolls over the meme of the day
+
+	defaultFilter atomic.Pointer[memeservice.Filter] // Applied to every stream and listing, reloadable
+
+	journal *journal.Journal // On-disk log of broadcast events, nil when disabled
+
+	pusher     *webpush.Pusher // Web Push sender, nil when disabled
+	pushEvents []string        // Events pushed as notifications
+
+	quietHours *QuietHours // Daily quiet window, nil when disabled
+	quiet      atomic.Bool // Whether quiet hours are in effect
+	retry      retryPolicy // SSE reconnection delay advised to streams
 }
 
-func NewServer(content embed.FS) *Server {
-	return &Server{
-		memeService:       memeservice.NewService(),
+func NewServer(content fs.FS, opts ...Option) *Server {
+	s := &Server{
 		connectionManager: connectionmanager.NewManager(50),
+		broadcaster:       broadcaster.NewBroadcaster(16),
 		content:           content,
+		logger:            log.Default(),
+		now:               time.Now,
+		streams:           make(map[string]*stream),
+		recent:            newRecentHistory(10, 30*time.Minute),
+		keys:              auth.NewKeyStore(nil),
+		audit:             audit.NewLog(auditLogSize),
+		motdLocation:      time.UTC,
+		debugPath:         DefaultDebugPath,
+		serializers:       make(map[string]broadcaster.Serializer),
+		retry:             retryPolicy{base: DefaultSSERetry, max: DefaultSSERetryMax, load: DefaultSSERetryLoad},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.memeService == nil {
+		s.memeService = memeservice.NewService(nil, nil, memeservice.NewHTTPClient(memeservice.DefaultClientConfig()))
+	}
+	s.stats.startTime = s.now()
+	s.rng = rand.New(rand.NewSource(s.now().UnixNano()))
+	s.recent.now = s.now
+
+	s.memeInterval.Store(int64(defaultMemeInterval))
+	s.vars = s.newVars()
+
+	// Announce memes climbing the ranks to every stream
+	s.memeService.OnTrending(s.broadcastTrending)
+
+	return s
+}
+
+// SetSeed makes meme selection reproducible: the Nth connection always receives the same sequence
+func (s *Server) SetSeed(seed int64) {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+// newConnectionRand returns the random source for a connection, honouring ?seed=
+func (s *Server) newConnectionRand(r *http.Request) (*rand.Rand, error) {
+	if raw := r.URL.Query().Get("seed"); raw != "" {
+		seed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q", raw)
+		}
+		return rand.New(rand.NewSource(seed)), nil
+	}
+
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+
+	return rand.New(rand.NewSource(s.rng.Int63())), nil
+}
+
+// SetRecentWindow configures how many memes per subscriber are suppressed and for how long
+func (s *Server) SetRecentWindow(size int, window time.Duration) {
+	s.recent.Configure(size, window)
+}
+
+// SetImageProxy serves meme images through signed, expiring /img URLs
+func (s *Server) SetImageProxy(proxy *imageproxy.Proxy) {
+	s.imageProxy = proxy
+}
+
+// SetDefaultFilter restricts the memes every stream and listing may receive
+func (s *Server) SetDefaultFilter(filter memeservice.Filter) {
+	s.defaultFilter.Store(&filter)
+}
+
+// DefaultFilter returns the server-wide filter
+func (s *Server) DefaultFilter() memeservice.Filter {
+	if filter := s.defaultFilter.Load(); filter != nil {
+		return *filter
+	}
+	return memeservice.Filter{}
+}
+
+// requestFilter combines the server-wide filter with ?tags= and ?media=
+func (s *Server) requestFilter(r *http.Request) (memeservice.Filter, error) {
+	filter, err := memeservice.FilterFromQuery(r.URL.Query())
+	if err != nil {
+		return memeservice.Filter{}, err
+	}
+	return s.DefaultFilter().Narrow(filter), nil
+}
+
+// proxyURL returns the signed image proxy URL for an upstream image
+func (s *Server) proxyURL(upstream string) string {
+	return s.basePath + "/img?" + s.imageProxy.Sign(upstream)
+}
+
+// SetBasePath mounts all routes under a sub-path such as /memes-app
+func (s *Server) SetBasePath(basePath string) {
+	s.basePath = "/" + strings.Trim(basePath, "/")
+	if s.basePath == "/" {
+		s.basePath = ""
+	}
+}
+
+// RedactHeaders masks the values of more request headers in connection logs,
+// on top of connectionmanager.DefaultRedactedHeaders
+func (s *Server) RedactHeaders(names ...string) {
+	s.connectionManager.RedactHeaders(names...)
+}
+
+// ArchiveConnections writes the log of every ended connection to w as JSON lines
+func (s *Server) ArchiveConnections(w io.Writer) {
+	s.connectionManager.SetArchive(w)
+}
+
+// CaptureFrames records the last maxFrames writes (up to maxBytes) to every
+// stream for GET <debug path>/connections/{connID}/frames; zero disables it
+func (s *Server) CaptureFrames(maxFrames int, maxBytes int64) {
+	s.connectionManager.SetFrameCapture(maxFrames, maxBytes)
+}
+
+// SetDebugSampleRate logs headers and events of only a random fraction of
+// connections, from 0 to 1, counting the rest in /api/stats
+func (s *Server) SetDebugSampleRate(rate float64) error {
+	return s.connectionManager.SetSampleRate(rate)
+}
+
+// frameCapture returns the recorder for a stream's writes, nil when disabled
+func (s *Server) frameCapture(connID, transport string) func([]byte) {
+	if !s.connectionManager.CapturingFrames() {
+		return nil
+	}
+	return func(data []byte) { s.connectionManager.CaptureFrame(connID, transport, data) }
+}
+
+// SetSerializer makes streams opened on endpoint (a path such as /memes,
+// without the base path) write events through ser instead of the default format
+func (s *Server) SetSerializer(endpoint string, ser broadcaster.Serializer) {
+	s.serializers[endpoint] = ser
+}
+
+// serializerFor returns the serializer of the endpoint a stream was opened on,
+// nil for the default format
+func (s *Server) serializerFor(r *http.Request) broadcaster.Serializer {
+	if ser, ok := s.serializers[r.URL.Path]; ok {
+		return ser
 	}
+	return nil
 }
 
-// SetupRoutes configures HTTP routes
-func (s *Server) SetupRoutes() *http.ServeMux {
+// DefaultDebugPath is where the connection log dump is served unless moved
+const DefaultDebugPath = "/debug"
+
+// SetDebugPath moves the connection log dump and state dump (under /state) to
+// another path, or removes both when path is empty
+func (s *Server) SetDebugPath(path string) {
+	if path == "" {
+		s.debugPath = ""
+		return
+	}
+	s.debugPath = "/" + strings.Trim(path, "/")
+}
+
+// Middleware wraps the server's routes, e.g. for auth, logging or CORS
+type Middleware func(http.Handler) http.Handler
+
+// Use appends middleware applied by SetupRoutes and SetupRouteSet; the first
+// registered runs first, and each sees the full path including the base path
+func (s *Server) Use(mw ...Middleware) {
+	s.middleware = append(s.middleware, mw...)
+}
+
+// SetupRoutes configures all HTTP routes
+func (s *Server) SetupRoutes() http.Handler {
+	return s.SetupRouteSet(RoutesAll)
+}
+
+// SetupRouteSet configures the HTTP routes belonging to a route set, wrapped in
+// the registered middleware
+func (s *Server) SetupRouteSet(set RouteSet) http.Handler {
+	var handler http.Handler = s.routeSet(set)
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	return handler
+}
+
+// routeSet builds the mux for a route set, mounted under the base path
+func (s *Server) routeSet(set RouteSet) *http.ServeMux {
 	mux := http.NewServeMux()
 
+	// Readiness probe on every listener
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+
+	if set != RoutesAdmin {
+		s.setupPublicRoutes(mux)
+	}
+	if set != RoutesPublic {
+		s.setupAdminRoutes(mux)
+	}
+
+	if s.basePath == "" {
+		return mux
+	}
+
+	// Mount everything under the base path
+	prefixed := http.NewServeMux()
+	prefixed.Handle(s.basePath+"/", http.StripPrefix(s.basePath, mux))
+	prefixed.Handle(s.basePath, http.RedirectHandler(s.basePath+"/", http.StatusMovedPermanently))
+	return prefixed
+}
+
+// setupPublicRoutes registers the stream and the client page
+func (s *Server) setupPublicRoutes(mux *http.ServeMux) {
 	// SSE endpoint
-	mux.HandleFunc("/memes", s.handleMemeSSE)
+	mux.HandleFunc("/memes", s.requireRole(auth.RoleViewer, s.handleMemeSSE))
+
+	// SSE grammar test stream for client libraries
+	mux.HandleFunc("GET /memes/conformance", s.requireRole(auth.RoleViewer, s.handleConformance))
+
+	// Meme pool listing
+	s.handleV1(mux, "GET", "/memes", s.requireRole(auth.RoleViewer, s.handleMemes))
+
+	// User meme submissions and their uploaded images
+	if s.submissions != nil {
+		s.handleV1(mux, "POST", "/memes", s.requireRole(auth.RoleViewer, s.handleSubmit))
+		mux.HandleFunc("GET "+uploadsPath+"{name}", s.requireRole(auth.RoleViewer, s.handleUpload))
+	}
+
+	// Meme captions and the broadcast ones
+	if s.captions != nil {
+		s.handleV1(mux, "POST", "/caption", s.requireRole(auth.RoleViewer, s.handleCaption))
+		mux.HandleFunc("GET "+captionsPath+"{name}", s.requireRole(auth.RoleViewer, s.handleCaptionImage))
+	}
+
+	// Files of the local meme directory
+	if s.localDir != "" {
+		mux.HandleFunc("GET "+localPath+"{name}", s.requireRole(auth.RoleViewer, s.handleLocalFile))
+	}
+
+	// Meme of the day
+	s.handleV1(mux, "GET", "/motd", s.requireRole(auth.RoleViewer, s.handleMotd))
 
-	// Debug logs endpoint
-	mux.HandleFunc("/debug", s.connectionManager.DebugHandler)
+	// Trending memes endpoint
+	s.handleV1(mux, "GET", "/trending", s.requireRole(auth.RoleViewer, s.handleTrending))
+
+	// Replay of journaled broadcast events
+	if s.journal != nil {
+		s.handleV1(mux, "GET", "/events", s.requireRole(auth.RoleViewer, s.handleEvents))
+	}
+
+	// Web Push subscriptions
+	if s.pusher != nil {
+		s.handleV1(mux, "GET", "/push/key", s.requireRole(auth.RoleViewer, s.handlePushKey))
+		s.handleV1(mux, "POST", "/push/subscribe", s.requireRole(auth.RoleViewer, s.handlePushSubscribe))
+		s.handleV1(mux, "POST", "/push/unsubscribe", s.requireRole(auth.RoleViewer, s.handlePushUnsubscribe))
+	}
+
+	// Per-stream controls
+	s.handleV1(mux, "POST", "/streams/{connID}/pause", s.requireRole(auth.RoleViewer, s.handlePause))
+	s.handleV1(mux, "POST", "/streams/{connID}/resume", s.requireRole(auth.RoleViewer, s.handleResume))
+	s.handleV1(mux, "GET", "/streams/{connID}/next", s.requireRole(auth.RoleViewer, s.handleNext))
+	s.handleV1(mux, "POST", "/streams/{connID}/pong", s.requireRole(auth.RoleViewer, s.handlePong))
+
+	// Enriched meme schema; its stream converts events with v2Schema
+	mux.HandleFunc("GET "+apiV2+"/memes", s.requireRole(auth.RoleViewer, s.handleMemesV2))
+	mux.HandleFunc("GET "+apiV2+"/motd", s.requireRole(auth.RoleViewer, s.handleMotdV2))
+	mux.HandleFunc("GET "+apiV2+"/trending", s.requireRole(auth.RoleViewer, s.handleTrendingV2))
+	mux.HandleFunc(apiV2+"/stream", s.requireRole(auth.RoleViewer, s.handleMemeSSE))
+
+	// Image proxy; the signature authorizes the request, so no role is required
+	if s.imageProxy != nil {
+		mux.Handle("GET /img", s.imageProxy)
+	}
+
+	// RSS feed of the meme pool
+	mux.HandleFunc("GET /feed.xml", s.requireRole(auth.RoleViewer, s.handleFeed))
+
+	// Page script, kept out of the page so the CSP can forbid inline scripts
+	mux.HandleFunc("GET /app.js", s.serveAsset("app.js", "text/javascript"))
+
+	// Progressive web app: service worker, manifest and icon
+	mux.HandleFunc("GET /sw.js", s.serveAsset("sw.js", "text/javascript"))
+	mux.HandleFunc("GET /manifest.webmanifest", s.serveAsset("manifest.webmanifest", "application/manifest+json"))
+	mux.HandleFunc("GET /icon.svg", s.serveAsset("icon.svg", "image/svg+xml"))
 
 	// Client page with embedded template
 	mux.HandleFunc("/", s.serveIndex)
+}
+
+// setupAdminRoutes registers the statistics and debugging endpoints
+func (s *Server) setupAdminRoutes(mux *http.ServeMux) {
+	// Identity provider login
+	if s.oidc != nil {
+		mux.HandleFunc("GET /auth/login", s.oidc.HandleLogin)
+		mux.HandleFunc("GET /auth/callback", s.oidc.HandleCallback)
+		mux.HandleFunc("POST /auth/logout", s.oidc.HandleLogout)
+	}
+
+	// Server statistics endpoint
+	s.handleV1(mux, "GET", "/stats", s.requireRole(auth.RoleDebugger, s.handleStats))
+
+	if s.debugPath != "" {
+		// Debug logs endpoint
+		mux.HandleFunc(s.debugPath, s.requireRole(auth.RoleDebugger, s.connectionManager.DebugHandler))
+
+		// Search over connection logs
+		mux.HandleFunc("GET "+s.debugPath+"/search", s.requireRole(auth.RoleDebugger, s.connectionManager.SearchHandler))
 
-	return mux
+		// Bytes written to a stream, when frame capture is enabled
+		mux.HandleFunc("GET "+s.debugPath+"/connections/{connID}/frames", s.requireRole(auth.RoleDebugger, s.connectionManager.FramesHandler))
+
+		// Captured frames streamed again to a new client
+		mux.HandleFunc("GET "+s.debugPath+"/connections/{connID}/replay", s.requireRole(auth.RoleDebugger, s.connectionManager.ReplayHandler))
+
+		// Request that opened a connection, for reproduction
+		mux.HandleFunc("GET "+s.debugPath+"/connections/{connID}/export", s.requireRole(auth.RoleDebugger, s.connectionManager.ExportHandler))
+
+		// Aggregates over connection logs
+		mux.HandleFunc("GET "+s.debugPath+"/analytics", s.requireRole(auth.RoleDebugger, s.connectionManager.AnalyticsHandler))
+
+		// Core counters and runtime stats in expvar format
+		mux.HandleFunc("GET "+s.debugPath+"/vars", s.requireRole(auth.RoleDebugger, s.handleVars))
+
+		// Full internal state dump
+		mux.HandleFunc("GET "+s.debugPath+"/state", s.requireRole(auth.RoleAdmin, s.handleState))
+
+		// Purge of ended connection logs
+		mux.HandleFunc("DELETE "+s.debugPath+"/connections", s.requireRole(auth.RoleAdmin, s.handlePurgeConnections))
+	}
+
+	// Config reload
+	mux.HandleFunc("POST /admin/reload", s.requireAdminToken(s.handleReload))
+
+	// Per-key quota usage
+	mux.HandleFunc("GET /admin/keys/usage", s.requireRole(auth.RoleAdmin, s.handleKeyUsage))
+
+	// Stream and pool management
+	mux.HandleFunc("POST /admin/streams/{connID}/kick", s.requireRole(auth.RoleAdmin, s.handleKick))
+	mux.HandleFunc("POST /admin/refresh", s.requireRole(auth.RoleAdmin, s.handleRefresh))
+
+	// Audit trail of admin actions
+	mux.HandleFunc("GET /admin/audit", s.requireRole(auth.RoleAdmin, s.handleAudit))
+
+	// Review of user-submitted memes before they enter the pool
+	if s.submissions != nil {
+		mux.HandleFunc("GET /admin/moderation", s.requireRole(auth.RoleAdmin, s.handleModerationQueue))
+		mux.HandleFunc("POST /admin/moderation", s.requireRole(auth.RoleAdmin, s.handleModerate))
+	}
 }
 
 // handleMemeSSE manages Server-Sent Events for meme streaming
@@ -47,21 +497,70 @@ func (s *Server) handleMemeSSE(w http.ResponseWriter, r *http.Request) {
 	// Register connection and get unique ID
 	connID := s.connectionManager.AddConnection(r)
 	s.connectionManager.AddConnectionEvent(connID, "Connection Established")
+	s.stats.totalConnections.Add(1)
 
-	// Log request details for debugging
-	log.Printf("SSE Connection Received: %s %s (ID: %s)", r.Method, r.URL.Path, connID)
-	log.Println("Request Headers:")
-	for k, v := range r.Header {
-		log.Printf("%s: %v", k, v)
-		s.connectionManager.AddConnectionEvent(connID,
-			fmt.Sprintf("Header: %s = %v", k, v))
+	// Correlate reconnects of the same client
+	client := s.clientToken(w, r)
+	s.connectionManager.SetClient(connID, client)
+	defer s.connectionManager.CloseConnection(connID)
+
+	// Log request details for debugging, for sampled connections only
+	if s.connectionManager.Sampled(connID) {
+		s.logger.Printf("SSE Connection Received: %s %s (ID: %s)", r.Method, r.URL.Path, connID)
+		s.logger.Println("Request Headers:")
+		for k, v := range s.connectionManager.Redacted(r.Header) {
+			s.logger.Printf("%s: %v", k, v)
+			s.connectionManager.AddConnectionEvent(connID,
+				fmt.Sprintf("Header: %s = %v", k, v))
+		}
+	}
+
+	// Enforce the key's concurrent stream quota
+	key, _ := auth.FromContext(r.Context())
+	release, ok := s.acquireStreamQuota(w, r, key)
+	if !ok {
+		s.connectionManager.AddConnectionEvent(connID, "Rejected: API key over quota")
+		return
+	}
+	defer release()
+
+	// Per-stream meme filter from ?tags= and ?media=
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		s.connectionManager.AddConnectionEvent(connID, err.Error())
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	// Per-stream payload shaping from ?encoding= and ?inline_images=
+	payload, err := parsePayloadOptions(r.URL.Query())
+	if err != nil {
+		s.connectionManager.AddConnectionEvent(connID, err.Error())
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	// Per-connection random source
+	rng, err := s.newConnectionRand(r)
+	if err != nil {
+		s.connectionManager.AddConnectionEvent(connID, err.Error())
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
 	}
 
 	// Ensure fresh meme data
-	if err := s.memeService.FetchMemes(); err != nil {
+	if err := s.memeService.FetchMemes(r.Context()); err != nil {
 		s.connectionManager.AddConnectionEvent(connID,
 			fmt.Sprintf("Meme Fetch Error: %v", err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", err))
+		return
+	}
+
+	// WebSocket clients get the same stream over a different transport
+	if isWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, connID, func(r *http.Request, t transport) {
+			s.runStream(r, connID, client, key, filter, payload, rng, t)
+		})
 		return
 	}
 
@@ -74,54 +573,312 @@ func (s *Server) handleMemeSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		s.connectionManager.AddConnectionEvent(connID, "Streaming unsupported")
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "streaming_unsupported", "streaming unsupported"))
 		return
 	}
 	flusher.Flush()
 
+	s.runStream(r, connID, client, key, filter, payload, rng, &sseTransport{w: w, flusher: flusher, capture: s.frameCapture(connID, connectionmanager.TransportSSE)})
+}
+
+// runStream delivers memes and broadcasts to a connected client until it leaves
+func (s *Server) runStream(r *http.Request, connID, client string, key auth.APIKey, filter memeservice.Filter, payload payloadOptions, rng *rand.Rand, t transport) {
+	// Admins can end the stream early via kick
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	st := &stream{
+		id:         connID,
+		cancel:     cancel,
+		transport:  t,
+		rng:        rng,
+		manual:     r.URL.Query().Get("mode") == "manual",
+		next:       make(chan struct{}, 1),
+		pings:      make(map[int64]time.Time),
+		key:        key,
+		filter:     filter,
+		payload:    payload,
+		schema:     schemaFor(r),
+		serializer: s.serializerFor(r),
+
+		owner:      streamOwner(r, client),
+		subscriber: subscriberKey(r, connID),
+	}
+	s.registerStream(st)
+	defer s.unregisterStream(connID)
+
+	// Experiment cohort, kept for the whole stream
+	if cohort, ok := s.experiment.assign(client); ok {
+		st.cohort = &cohort
+		s.connectionManager.AddConnectionEvent(connID, "Cohort: "+cohort.Name)
+		s.experiment.started(cohort.Name)
+		started := s.now()
+		defer func() { s.experiment.ended(cohort.Name, s.now().Sub(started)) }()
+	}
+
+	// Subscribe to server-wide broadcasts
+	events := s.broadcaster.Subscribe(connID)
+	defer s.broadcaster.Unsubscribe(connID)
+
 	// Create channel for closing connection
-	closeChan := r.Context().Done()
+	closeChan := ctx.Done()
+
+	interval := s.streamInterval(st)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+
+	// Send the first meme immediately unless the client pulls memes itself, in
+	// which case it needs the connection ID to ask for the next one
+	if st.manual {
+		s.connectionManager.AddConnectionEvent(connID, "Manual mode: waiting for next requests")
+		status := s.streamStatus(st)
+		status.Reason = "manual"
+		data, err := json.Marshal(status)
+		if err != nil {
+			s.logger.Printf("Error encoding status event for %s: %v", connID, err)
+			return
+		}
+		if !s.sendEvent(st, broadcaster.Event{Name: "status", Data: data}) {
+			return
+		}
+	} else if !s.sendMeme(st) {
+		return
+	}
 
 	// Meme streaming loop
 	for {
 		select {
 		case <-closeChan:
 			s.connectionManager.AddConnectionEvent(connID, "Client connection closed")
-			log.Printf("Connection %s closed", connID)
+			if s.connectionManager.Sampled(connID) {
+				s.logger.Printf("Connection %s closed", connID)
+			}
 			return
-		default:
-			meme := s.memeService.GetRandomMeme()
-
-			// Prepare SSE message
-			message := fmt.Sprintf("data: {\"title\": %q, \"url\": %q, \"connID\": %q}\n\n",
-				meme.Title, meme.URL, connID)
-
-			// Write event
-			_, err := fmt.Fprint(w, message)
-			if err != nil {
-				s.connectionManager.AddConnectionEvent(connID,
-					fmt.Sprintf("Event Send Error: %v", err))
-				log.Printf("Error sending event for %s: %v", connID, err)
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Seq > 0 {
+				s.trackBroadcastSeq(st, event.Seq)
+				event.ID = broadcastEventID(st, event.Seq)
+			}
+			if !s.sendEvent(st, event) {
+				return
+			}
+		case <-pingTicker.C:
+			if !s.sendPing(st) {
+				return
+			}
+		case <-st.next:
+			if !s.sendMeme(st) {
+				return
+			}
+		case <-ticker.C:
+			// Pick up interval changes from config reloads and quiet hours
+			if current := s.streamInterval(st); current != interval {
+				interval = current
+				ticker.Reset(interval)
+			}
+			if st.manual || st.paused.Load() || s.quietPaused() {
+				continue
+			}
+			if !s.sendMeme(st) {
 				return
 			}
+		}
+	}
+}
+
+// sendMeme writes a random meme to the stream, returning false if the stream should end
+func (s *Server) sendMeme(st *stream) bool {
+	// Charge the meme against the key's daily quota
+	if st.key.Name != "" && !s.connectionManager.AddKeyEvent(st.key.Name, st.key.EventsPerDay) {
+		s.connectionManager.AddConnectionEvent(st.id, "Daily event quota exhausted")
+		// Ask EventSource to reconnect once the quota resets
+		s.sendEvent(st, broadcaster.Event{
+			Name:  "quota",
+			Data:  []byte(`{"error":"daily event quota exhausted"}`),
+			Retry: connectionmanager.QuotaReset(s.now()).Sub(s.now()),
+		})
+		return false
+	}
+
+	var ranker memeservice.Ranker
+	if st.cohort != nil {
+		ranker = st.cohort.ranker
+		s.experiment.sent(st.cohort.Name)
+	}
+	meme := s.memeService.GetRankedMeme(ranker, st.rng, s.recent.Exclusions(st.subscriber), st.filter)
+	s.recent.Record(st.subscriber, meme.Key())
+	st.sent++
+
+	// Prepare SSE message
+	payload := memePayload{Meme: meme, ConnID: st.id}
+	if st.payload.inlineImages {
+		payload.ImageData = s.inlineImage(meme)
+	}
+	if s.imageProxy != nil && meme.URL != "" && !s.isSelfHosted(meme.URL) {
+		payload.ProxyURL = s.proxyURL(meme.URL)
+
+		// Copy before signing so the shared pool is left untouched
+		payload.Images = slices.Clone(meme.Images)
+		for i := range payload.Images {
+			payload.Images[i].ProxyURL = s.proxyURL(payload.Images[i].URL)
+		}
+	}
+
+	data, err := s.encodeMeme(payload)
+	if err != nil {
+		s.connectionManager.AddConnectionEvent(st.id,
+			fmt.Sprintf("Event Encode Error: %v", err))
+		s.logger.Printf("Error encoding event for %s: %v", st.id, err)
+		return false
+	}
+
+	return s.sendEvent(st, broadcaster.Event{
+		ID:   fmt.Sprintf("%s:%d", st.subscriber, st.sent),
+		Data: data,
+	})
+}
+
+// sendEvent writes a single event to the stream's transport, returning false on failure
+func (s *Server) sendEvent(st *stream, event broadcaster.Event) bool {
+	var err error
+	if st.schema != nil {
+		if event, err = st.schema.Serialize(event); err != nil {
+			s.connectionManager.AddConnectionEvent(st.id,
+				fmt.Sprintf("Event Schema Error: %v", err))
+			s.logger.Printf("Error converting event for %s: %v", st.id, err)
+			return false
+		}
+	}
+
+	if memeEvents[event.Name] {
+		event.Data = st.payload.fields.project(event.Data)
+	}
+
+	if st.serializer != nil {
+		if event, err = st.serializer.Serialize(event); err != nil {
+			s.connectionManager.AddConnectionEvent(st.id,
+				fmt.Sprintf("Event Serialize Error: %v", err))
+			s.logger.Printf("Error serializing event for %s: %v", st.id, err)
+			return false
+		}
+	}
+
+	// Shared encodings only match streams that send broadcasts unchanged
+	if !st.sendsAsIs() {
+		event.Rendered = nil
+	}
+
+	event.Data = st.payload.encodeData(event.Data)
+	s.adviseRetry(st, &event)
+	n, err := st.transport.send(event)
+	s.stats.bytesSent.Add(int64(n))
+	if err != nil {
+		s.connectionManager.AddConnectionEvent(st.id,
+			fmt.Sprintf("Event Send Error: %v", err))
+		s.logger.Printf("Error sending event for %s: %v", st.id, err)
+		return false
+	}
+	s.stats.eventsSent.Add(1)
+	return true
+}
+
+// broadcastTrending announces memes whose rank jumped significantly
+func (s *Server) broadcastTrending(jumps []memeservice.Trending) {
+	data, err := json.Marshal(jumps)
+	if err != nil {
+		s.logger.Printf("Error encoding trending event: %v", err)
+		return
+	}
+
+	s.broadcaster.Broadcast(broadcaster.Event{Name: "trending", Data: data})
+	if len(jumps) > 0 {
+		s.pushMeme("trending", "Trending meme", jumps[0].Meme)
+	}
+}
+
+// handleMemes lists the current pool, optionally filtered by ?tags=
+func (s *Server) handleMemes(w http.ResponseWriter, r *http.Request) {
+	if memes, ok := s.poolMemes(w, r); ok {
+		s.writeMemes(w, r, memes)
+	}
+}
 
-			// Flush to client
-			flusher.Flush()
+// poolMemes refreshes the pool and lists the memes matching the request's
+// filter, writing the error response when it cannot
+func (s *Server) poolMemes(w http.ResponseWriter, r *http.Request) ([]memeservice.Meme, bool) {
+	if err := s.memeService.FetchMemes(r.Context()); err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadGateway, "upstream_error", err))
+		return nil, false
+	}
 
-			// Wait before next meme
-			time.Sleep(10 * time.Second)
+	filter, err := s.requestFilter(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return nil, false
+	}
+
+	return s.memeService.ListMemes(filter), true
+}
+
+// handleTrending lists the memes gaining score fastest
+func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
+	if trending, ok := s.trending(w, r); ok {
+		s.writeMemes(w, r, trending)
+	}
+}
+
+// trending lists up to ?limit= (default 10) trending memes, writing the
+// error response when the limit is invalid
+func (s *Server) trending(w http.ResponseWriter, r *http.Request) ([]memeservice.Trending, bool) {
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			apierror.Write(w, r, apierror.BadRequest("invalid limit"))
+			return nil, false
 		}
+		limit = parsed
 	}
+
+	return s.memeService.GetTrending(limit), true
+}
+
+// indexData is passed to the client page template
+type indexData struct {
+	BasePath  string
+	DebugPath string // Empty when the debug dump is disabled
 }
 
 // serveIndex serves the embedded HTML template
 func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFS(s.content, "web/index.html")
+	tmpl, err := template.ParseFS(s.content, "index.html")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/html")
-	tmpl.Execute(w, nil)
+	tmpl.Execute(w, indexData{BasePath: s.basePath, DebugPath: s.debugPath})
+}
+
+// serveAsset serves an embedded non-HTML file, filling in the base path
+func (s *Server) serveAsset(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := texttemplate.ParseFS(s.content, name)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// Revalidate so service worker updates are picked up promptly
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		tmpl.Execute(w, indexData{BasePath: s.basePath, DebugPath: s.debugPath})
+	}
 }
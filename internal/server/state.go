@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// stateEventTail is how many recent events per connection the state dump includes
+const stateEventTail = 10
+
+// StateDump is a full snapshot of internal state for diagnosing stuck streams
+type StateDump struct {
+	Stats       StatsResponse       `json:"stats"`
+	Goroutines  int                 `json:"goroutines"`
+	HeapAlloc   uint64              `json:"heap_alloc_bytes"`
+	NumGC       uint32              `json:"num_gc"`
+	Subscribers []subscriberState   `json:"subscribers"`
+	Pool        []memeservice.Meme  `json:"pool"`
+	EventTails  map[string][]string `json:"event_tails"`
+}
+
+// subscriberState describes a single active stream
+type subscriberState struct {
+	ConnID     string `json:"connID"`
+	Subscriber string `json:"subscriber"`
+	Paused     bool   `json:"paused"`
+	Manual     bool   `json:"manual"`
+}
+
+// State collects a snapshot of the server's internal state
+func (s *Server) State() StateDump {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	dump := StateDump{
+		Stats:      s.Stats(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		NumGC:      mem.NumGC,
+		Pool:       s.memeService.GetMemes(),
+		EventTails: s.connectionManager.EventTails(stateEventTail),
+	}
+
+	s.streamsMu.RLock()
+	for _, st := range s.streams {
+		dump.Subscribers = append(dump.Subscribers, subscriberState{
+			ConnID:     st.id,
+			Subscriber: st.subscriber,
+			Paused:     st.paused.Load(),
+			Manual:     st.manual,
+		})
+	}
+	s.streamsMu.RUnlock()
+
+	sort.Slice(dump.Subscribers, func(i, j int) bool {
+		return dump.Subscribers[i].ConnID < dump.Subscribers[j].ConnID
+	})
+	return dump
+}
+
+// SetAdminToken sets the bearer token protecting sensitive admin endpoints
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetAllowedOrigins sets the cross-site origins whose pages may open WebSocket
+// streams; same-site pages and clients sending no Origin always may
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = origins
+}
+
+// handleState serves the internal state dump
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.State()); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
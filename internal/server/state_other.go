@@ -0,0 +1,10 @@
+//go:build !unix
+
+package server
+
+import (
+	"context"
+)
+
+// DumpStateOnSignal is a no-op on platforms without SIGUSR1
+func (s *Server) DumpStateOnSignal(ctx context.Context) {}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// DumpStateOnSignal logs the full internal state whenever SIGUSR1 is received
+func (s *Server) DumpStateOnSignal(ctx context.Context) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(usr1)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr1:
+				data, err := json.MarshalIndent(s.State(), "", "  ")
+				if err != nil {
+					s.logger.Printf("State dump failed: %v", err)
+					continue
+				}
+				s.logger.Printf("State dump:\n%s", data)
+			}
+		}
+	}()
+}
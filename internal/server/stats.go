@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	connectionmanager "github.com/elt0nxale/meme-tunnel-streamer/internal/connectionmanager"
+	kafkasink "github.com/elt0nxale/meme-tunnel-streamer/internal/kafkasink"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	s3archive "github.com/elt0nxale/meme-tunnel-streamer/internal/s3archive"
+)
+
+// stats holds server-wide counters updated by the streaming handlers
+type stats struct {
+	startTime        time.Time
+	totalConnections atomic.Int64
+	eventsSent       atomic.Int64
+	bytesSent        atomic.Int64
+}
+
+// StatsResponse is the machine-readable summary served by /api/stats
+type StatsResponse struct {
+	Uptime           string     `json:"uptime"`
+	UptimeSeconds    float64    `json:"uptime_seconds"`
+	TotalConnections int64      `json:"total_connections"`
+	ActiveStreams    int        `json:"active_streams"`
+	EventsSent       int64      `json:"events_sent"`
+	BytesSent        int64      `json:"bytes_sent"`
+	PoolSize         int        `json:"pool_size"`
+	LastFetch        *time.Time `json:"last_fetch,omitempty"`
+	LastFetchStatus  string     `json:"last_fetch_status"`
+	TunnelURL        string     `json:"tunnel_url,omitempty"`
+	BroadcastSeq     uint64     `json:"broadcast_seq"`          // Sequence number of the latest broadcast event
+	SSERetryMs       int64      `json:"sse_retry_ms,omitempty"` // Reconnection delay advised to streams now, before jitter
+
+	Blocklist *BlocklistStats  `json:"blocklist,omitempty"` // Set when a content blocklist is loaded
+	Cohorts   []CohortStats    `json:"cohorts,omitempty"`   // Experiment cohorts, current and past
+	Kafka     *kafkasink.Stats `json:"kafka,omitempty"`     // Set when events are mirrored to Kafka
+	Archive   *s3archive.Stats `json:"archive,omitempty"`   // Set when the pool is archived to object storage
+
+	DebugSampling *connectionmanager.SamplingStats `json:"debug_sampling,omitempty"` // Set when only some connections are logged
+
+	Providers []memeservice.ProviderHealth `json:"providers"`
+	Endpoints []memeservice.ProviderHealth `json:"endpoints"` // Reddit host and mirrors, in fallback order
+}
+
+// BlocklistStats summarizes the content blocklist
+type BlocklistStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"` // Images refused since startup, fetched or submitted
+}
+
+// SetTunnelURL records the public tunnel URL reported in stats
+func (s *Server) SetTunnelURL(url string) {
+	s.tunnelURL.Store(url)
+}
+
+// Stats builds a snapshot of the server statistics
+func (s *Server) Stats() StatsResponse {
+	uptime := s.now().Sub(s.stats.startTime)
+	resp := StatsResponse{
+		Uptime:           uptime.Round(time.Second).String(),
+		UptimeSeconds:    uptime.Seconds(),
+		TotalConnections: s.stats.totalConnections.Load(),
+		ActiveStreams:    s.broadcaster.Count(),
+		EventsSent:       s.stats.eventsSent.Load(),
+		BytesSent:        s.stats.bytesSent.Load(),
+		PoolSize:         s.memeService.PoolSize(),
+		LastFetchStatus:  "ok",
+		Providers:        s.memeService.ProviderHealth(),
+		Endpoints:        s.memeService.EndpointHealth(),
+		BroadcastSeq:     s.broadcaster.LastSeq(),
+		SSERetryMs:       s.retryDelay(&stream{}).Milliseconds(),
+	}
+
+	lastFetch, err := s.memeService.FetchStatus()
+	if !lastFetch.IsZero() {
+		resp.LastFetch = &lastFetch
+	}
+	switch {
+	case err != nil:
+		resp.LastFetchStatus = err.Error()
+	case lastFetch.IsZero():
+		resp.LastFetchStatus = "pending"
+	}
+
+	resp.Cohorts = s.experiment.stats()
+	if sampling := s.connectionManager.SamplingStats(); sampling.Rate < 1 {
+		resp.DebugSampling = &sampling
+	}
+	if s.blocklist != nil {
+		resp.Blocklist = &BlocklistStats{Entries: s.blocklist.Len(), Hits: s.blocklist.Hits()}
+	}
+	if s.kafka != nil {
+		kafka := s.kafka.Stats()
+		resp.Kafka = &kafka
+	}
+	if s.archiver != nil {
+		archive := s.archiver.Stats()
+		resp.Archive = &archive
+	}
+
+	if url, ok := s.tunnelURL.Load().(string); ok {
+		resp.TunnelURL = url
+	}
+	return resp
+}
+
+// handleStats serves the server statistics as JSON
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Stats()); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// stream holds the state of a single SSE or WebSocket connection
+type stream struct {
+	id         string
+	transport  transport // SSE or WebSocket writer
+	cancel     func()    // Ends the stream
+	rng        *rand.Rand
+	paused     atomic.Bool
+	manual     bool                   // Only send memes when requested via next
+	next       chan struct{}          // Signals an on-demand meme
+	key        auth.APIKey            // Key the stream authenticated with, empty when keys are disabled
+	filter     memeservice.Filter     // Restricts which memes are sent, e.g. by ?tags=
+	payload    payloadOptions         // How event data is encoded
+	schema     broadcaster.Serializer // Converts meme events to the endpoint's schema, nil for v1
+	serializer broadcaster.Serializer // Wire format of the endpoint the stream was opened on, nil for the default
+
+	owner      string  // Key, login or client token allowed to control the stream
+	subscriber string  // Identity used for recently-sent suppression
+	cohort     *Cohort // Experiment cohort, nil when no experiment runs
+	sent       int     // Memes sent on this connection
+	lastSeq    uint64  // Sequence number of the last broadcast event received
+
+	retry time.Duration // Reconnection delay last advised, before jitter
+
+	pingMu  sync.Mutex
+	pingSeq int64
+	pings   map[int64]time.Time // Outstanding pings by ID
+}
+
+// sendsAsIs reports whether the stream writes events without changing their
+// name or data, so it can share the encodings of broadcast events
+func (st *stream) sendsAsIs() bool {
+	return st.schema == nil && st.serializer == nil && st.payload.fields == nil && !st.payload.base64
+}
+
+// streamStatus is the body of status events and control responses
+type streamStatus struct {
+	ConnID string `json:"connID"`
+	State  string `json:"state"`            // streaming, paused or slowed
+	Reason string `json:"reason,omitempty"` // What caused the change, e.g. quiet_hours
+}
+
+// clientCookie carries the token that correlates a browser's reconnects
+const clientCookie = "mf_client"
+
+// clientToken returns the stable token of the client behind a stream: ?client=
+// wins, then the cookie, and otherwise a new token is issued as a cookie
+func (s *Server) clientToken(w http.ResponseWriter, r *http.Request) string {
+	if token := r.URL.Query().Get("client"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(clientCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	b := make([]byte, 12)
+	if _, err := cryptorand.Read(b); err != nil {
+		return ""
+	}
+	token := hex.EncodeToString(b)
+
+	path := s.basePath
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     clientCookie,
+		Value:    token,
+		Path:     path,
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// streamOwner identifies who may pause, advance or answer the pings of a
+// stream: its API key, then its login, then its client token
+func streamOwner(r *http.Request, client string) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + key.Name
+	}
+	if session, ok := auth.SessionFromContext(r.Context()); ok {
+		return "user:" + session.Subject
+	}
+	if client != "" {
+		return "client:" + client
+	}
+	return ""
+}
+
+// requestClient returns the client token a control request carries, without
+// issuing one
+func requestClient(r *http.Request) string {
+	if token := r.URL.Query().Get("client"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(clientCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// ownedStream returns the stream named in the path if the caller owns it or
+// holds the admin token. Other callers get a 404 so IDs cannot be probed
+func (s *Server) ownedStream(w http.ResponseWriter, r *http.Request) (*stream, bool) {
+	connID := r.PathValue("connID")
+	st, exists := s.getStream(connID)
+	if exists && !s.isAdminToken(r) {
+		owner := streamOwner(r, requestClient(r))
+		exists = st.owner != "" && st.owner == owner
+	}
+	if !exists {
+		apierror.Write(w, r, apierror.NotFound(fmt.Sprintf("stream %s not found", connID)))
+		return nil, false
+	}
+	return st, true
+}
+
+// subscriberKey identifies the client behind a connection so its history survives
+// reconnects: an explicit ?client= token wins, then the prefix of Last-Event-ID
+func subscriberKey(r *http.Request, connID string) string {
+	if token := r.URL.Query().Get("client"); token != "" {
+		return token
+	}
+
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		lastID, _, _ = strings.Cut(lastID, "/")
+		if i := strings.LastIndex(lastID, ":"); i > 0 {
+			return lastID[:i]
+		}
+	}
+
+	return connID
+}
+
+// broadcastEventID keeps the subscriber:count form of meme IDs, so reconnects
+// still find their history, and appends the global sequence number
+func broadcastEventID(st *stream, seq uint64) string {
+	return fmt.Sprintf("%s:%d/%d", st.subscriber, st.sent, seq)
+}
+
+// trackBroadcastSeq logs broadcast events a slow stream missed
+func (s *Server) trackBroadcastSeq(st *stream, seq uint64) {
+	if st.lastSeq > 0 && seq > st.lastSeq+1 {
+		s.connectionManager.AddConnectionEvent(st.id,
+			fmt.Sprintf("Missed broadcast events %d-%d", st.lastSeq+1, seq-1))
+	}
+	st.lastSeq = seq
+}
+
+// registerStream makes a stream reachable by the control endpoints
+func (s *Server) registerStream(st *stream) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	s.streams[st.id] = st
+}
+
+// unregisterStream removes a finished stream
+func (s *Server) unregisterStream(connID string) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	delete(s.streams, connID)
+}
+
+// getStream looks up an active stream by connection ID
+func (s *Server) getStream(connID string) (*stream, bool) {
+	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+
+	st, exists := s.streams[connID]
+	return st, exists
+}
+
+// handlePause stops meme delivery to a stream without disconnecting it
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// handleResume restarts meme delivery to a paused stream
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+// setPaused toggles a stream's paused state and notifies its client
+func (s *Server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	st, ok := s.ownedStream(w, r)
+	if !ok {
+		return
+	}
+	connID := st.id
+
+	changed := st.paused.Swap(paused) != paused
+	status := s.streamStatus(st)
+
+	if changed {
+		s.connectionManager.AddConnectionEvent(connID, fmt.Sprintf("Stream %s", status.State))
+
+		// Tell the client so its UI reflects the new state
+		if data, err := json.Marshal(status); err == nil {
+			s.broadcaster.Send(connID, broadcaster.Event{Name: "status", Data: data})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+}
+
+// streamStatus describes a stream's delivery state, taking quiet hours into account
+func (s *Server) streamStatus(st *stream) streamStatus {
+	status := streamStatus{ConnID: st.id, State: "streaming"}
+	switch {
+	case st.paused.Load():
+		status.State = "paused"
+	case s.quiet.Load():
+		status.State = s.quietHours.quietStatus()
+	}
+	return status
+}
+
+// handleNext asks a stream to send its next meme immediately
+func (s *Server) handleNext(w http.ResponseWriter, r *http.Request) {
+	st, ok := s.ownedStream(w, r)
+	if !ok {
+		return
+	}
+	connID := st.id
+
+	// Coalesce requests arriving faster than the stream can send
+	select {
+	case st.next <- struct{}{}:
+		s.connectionManager.AddConnectionEvent(connID, "Next meme requested")
+	default:
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
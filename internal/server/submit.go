@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	blocklist "github.com/elt0nxale/meme-tunnel-streamer/internal/blocklist"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	netguard "github.com/elt0nxale/meme-tunnel-streamer/internal/netguard"
+	submissions "github.com/elt0nxale/meme-tunnel-streamer/internal/submissions"
+)
+
+// errBlockedContent refuses submissions matching the content blocklist
+var errBlockedContent = apierror.New(http.StatusUnprocessableEntity, "blocked_content", "image matches the content blocklist")
+
+// maxTitleLength bounds submitted titles, in characters, like Reddit's
+const maxTitleLength = 300
+
+// maxSubmissionForm bounds the non-file parts of a multipart submission
+const maxSubmissionForm = 64 << 10
+
+// uploadsPath serves uploaded submission images
+const uploadsPath = "/uploads/"
+
+// SetSubmissions enables POST /api/memes and the moderation queue, storing
+// uploads of at most maxUpload bytes in store, and adds approved memes to the pool
+func (s *Server) SetSubmissions(store *submissions.Store, maxUpload int64) {
+	s.submissions = store
+	s.maxUpload = maxUpload
+	s.memeService.AddMemes(store.Memes()...)
+}
+
+// handleSubmit queues a user's meme for moderation, given as a JSON body with
+// a title and url, or as a multipart form with a title and an image file
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	actor := s.requestActor(r)
+	if actor == "anonymous" {
+		apierror.Write(w, r, apierror.Unauthorized("submitting memes requires an API key or login"))
+		return
+	}
+
+	sub, err := s.readSubmission(w, r)
+	if err == nil {
+		sub.SubmittedBy = actor
+		sub, err = s.submissions.Add(sub)
+	}
+	s.auditRequest(r, "meme.submit", map[string]string{"id": sub.Meme.ID, "title": sub.Meme.Title}, err)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Queued until a moderator approves it via /admin/moderation
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// readSubmission parses and validates a submission request
+func (s *Server) readSubmission(w http.ResponseWriter, r *http.Request) (submissions.Submission, error) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		return s.readUpload(w, r)
+	}
+
+	var body struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionForm)).Decode(&body); err != nil {
+		return submissions.Submission{}, apierror.BadRequest("body must be JSON with title and url, or a multipart form with title and image")
+	}
+	title, err := validTitle(body.Title)
+	if err != nil {
+		return submissions.Submission{}, err
+	}
+	if u, err := url.Parse(body.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return submissions.Submission{}, apierror.BadRequest("url must be an http or https URL")
+	}
+	if err := s.screenURL(r, body.URL); err != nil {
+		return submissions.Submission{}, err
+	}
+
+	return submissions.Submission{Meme: memeservice.NewSubmission(title, body.URL)}, nil
+}
+
+// readUpload stores the image of a multipart submission
+func (s *Server) readUpload(w http.ResponseWriter, r *http.Request) (submissions.Submission, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload+maxSubmissionForm)
+	if err := r.ParseMultipartForm(maxSubmissionForm); err != nil {
+		return submissions.Submission{}, apierror.New(http.StatusRequestEntityTooLarge, "upload_too_large", "invalid or oversized multipart form")
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	title, err := validTitle(r.FormValue("title"))
+	if err != nil {
+		return submissions.Submission{}, err
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return submissions.Submission{}, apierror.BadRequest("image file is required")
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so SaveImage still rejects oversized files
+	data, err := io.ReadAll(io.LimitReader(file, s.maxUpload+1))
+	if err != nil {
+		return submissions.Submission{}, apierror.BadRequest("failed to read image")
+	}
+	if s.blocklist != nil {
+		if _, blocked := s.blocklist.Check(blocklist.Compute(data), "upload by "+s.requestActor(r)); blocked {
+			return submissions.Submission{}, errBlockedContent
+		}
+	}
+
+	upload, err := s.submissions.SaveImage(bytes.NewReader(data), s.maxUpload)
+	if err != nil {
+		return submissions.Submission{}, apierror.BadRequest(err.Error())
+	}
+
+	meme := memeservice.NewSubmission(title, s.basePath+uploadsPath+upload.Name)
+	meme.Width = upload.Width
+	meme.Height = upload.Height
+	meme.Size = upload.Size
+	return submissions.Submission{Meme: meme, Upload: upload.Name}, nil
+}
+
+// SetBlocklist keeps images matching bl out of submissions and, from the next
+// refresh, out of the pool
+func (s *Server) SetBlocklist(bl *blocklist.List) {
+	s.blocklist = bl
+	s.blocklistClient = netguard.NewClient(10 * time.Second)
+	s.memeService.SetBlocklist(bl)
+}
+
+// screenURL checks the image at a submitted URL against the blocklist.
+// Images that cannot be fetched are let through to the moderation queue
+func (s *Server) screenURL(r *http.Request, rawURL string) error {
+	if s.blocklist == nil {
+		return nil
+	}
+	fp, err := s.memeService.Fingerprint(r.Context(), s.blocklistClient, rawURL)
+	if err != nil {
+		s.logger.Printf("Could not screen submitted image %s: %v", rawURL, err)
+		return nil
+	}
+	if _, blocked := s.blocklist.Check(fp, "submission by "+s.requestActor(r)+" ("+rawURL+")"); blocked {
+		return errBlockedContent
+	}
+	return nil
+}
+
+// validTitle trims a submitted title and checks its length
+func validTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", apierror.BadRequest("title is required")
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return "", apierror.BadRequest("title is too long")
+	}
+	return title, nil
+}
+
+// handleUpload serves an uploaded submission image
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.submissions.ImagePath(r.PathValue("name"))
+	if !ok {
+		apierror.Write(w, r, apierror.NotFound("upload not found"))
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	http.ServeFile(w, r, path)
+}
+
+// isSelfHosted reports whether a meme URL points at an image uploaded to,
+// captioned by or served from the local directory by this server, which
+// needs no proxying
+func (s *Server) isSelfHosted(u string) bool {
+	return (s.submissions != nil && strings.HasPrefix(u, s.basePath+uploadsPath)) ||
+		(s.captions != nil && strings.HasPrefix(u, s.basePath+captionsPath)) ||
+		(s.localDir != "" && strings.HasPrefix(u, s.basePath+localPath))
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+)
+
+// transport writes events to a stream's client
+type transport interface {
+	// send writes one event, returning the bytes written
+	send(event broadcaster.Event) (int, error)
+}
+
+// framePool recycles the buffers events are framed in
+var framePool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// sseTransport frames events as Server-Sent Events
+type sseTransport struct {
+	w       io.Writer
+	flusher http.Flusher
+	capture func([]byte) // Records written bytes, nil unless frame capture is enabled
+}
+
+// send writes a single SSE event and flushes it. The event and data fields of
+// a broadcast are framed once and shared by every stream that sends it as is
+func (t *sseTransport) send(event broadcaster.Event) (int, error) {
+	buf := framePool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer framePool.Put(buf)
+
+	if event.Retry > 0 {
+		buf.WriteString("retry: ")
+		buf.WriteString(strconv.FormatInt(event.Retry.Milliseconds(), 10))
+		buf.WriteByte('\n')
+	}
+	if event.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(event.ID)
+		buf.WriteByte('\n')
+	}
+	buf.Write(event.Rendered.Get("sse", func() []byte { return sseBody(event) }))
+
+	// Write event
+	n, err := t.w.Write(buf.Bytes())
+	if t.capture != nil && n > 0 {
+		t.capture(buf.Bytes()[:n])
+	}
+	if err != nil {
+		return n, err
+	}
+
+	// Flush to client
+	t.flusher.Flush()
+	return n, nil
+}
+
+// NewSSEWriter returns a function framing events onto w exactly as SSE streams
+// do, for measuring the streaming path without HTTP connections
+func NewSSEWriter(w io.Writer) func(broadcaster.Event) (int, error) {
+	t := &sseTransport{w: w, flusher: nopFlusher{}}
+	return t.send
+}
+
+// nopFlusher stands in for the flusher of writers that buffer nothing
+type nopFlusher struct{}
+
+func (nopFlusher) Flush() {}
+
+// sseBody frames the event name and data of an event, giving each line of
+// multi-line data its own field
+func sseBody(event broadcaster.Event) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(event.Data) + len(event.Name) + 16)
+	if event.Name != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(event.Name)
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteString("data: ")
+	data := event.Data
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				i++
+			}
+			buf.WriteString("\ndata: ")
+		case '\n':
+			buf.WriteString("\ndata: ")
+		default:
+			buf.WriteByte(data[i])
+		}
+	}
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// memeV2 is the enriched meme schema of /api/v2: every picture or video of a
+// post is an entry of Media, and where it came from is grouped under Source
+type memeV2 struct {
+	ID     string    `json:"id"`
+	Title  string    `json:"title"`
+	Score  int       `json:"score"`
+	Tags   []string  `json:"tags"`
+	Media  []mediaV2 `json:"media"`
+	Source sourceV2  `json:"source"`
+
+	Info *memeservice.MemeInfo `json:"info,omitempty"` // Background on the meme format, with --meme-dataset
+}
+
+// mediaV2 is one picture or video of a meme
+type mediaV2 struct {
+	Type      string `json:"type"` // image, gif or video
+	URL       string `json:"url"`
+	ProxyURL  string `json:"proxy_url,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	DASHURL   string `json:"dash_url,omitempty"`
+	HLSURL    string `json:"hls_url,omitempty"`
+	Duration  int    `json:"duration,omitempty"`   // Seconds
+	ImageData string `json:"image_data,omitempty"` // Data URI with ?inline_images=1
+
+	Renditions []memeservice.Rendition `json:"renditions,omitempty"` // Other encodings of a GIF
+}
+
+// sourceV2 describes the post a meme was taken from
+type sourceV2 struct {
+	Subreddit string     `json:"subreddit,omitempty"`
+	Permalink string     `json:"permalink,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// trendingV2 is a rank jump in the v2 schema
+type trendingV2 struct {
+	Meme         memeV2  `json:"meme"`
+	ScoreDelta   int     `json:"score_delta"`
+	Velocity     float64 `json:"velocity"`
+	Rank         int     `json:"rank"`
+	PreviousRank int     `json:"previous_rank"`
+}
+
+// motdV2 is the meme of the day in the v2 schema
+type motdV2 struct {
+	Date string `json:"date"`
+	Meme memeV2 `json:"meme"`
+}
+
+// messageV2 is a meme stream event in the v2 schema
+type messageV2 struct {
+	ConnID string `json:"conn_id"`
+	Meme   memeV2 `json:"meme"`
+}
+
+// toMemeV2 converts a meme to the v2 schema
+func toMemeV2(m memeservice.Meme) memeV2 {
+	v := memeV2{
+		ID:    m.Key(),
+		Title: m.Title,
+		Score: m.Score,
+		Tags:  m.Tags,
+		Info:  m.Info,
+		Source: sourceV2{
+			Subreddit: m.Subreddit,
+			Permalink: m.Permalink,
+		},
+	}
+	if v.Tags == nil {
+		v.Tags = []string{}
+	}
+	if m.CreatedUTC != 0 {
+		created := time.Unix(m.CreatedUTC, 0).UTC()
+		v.Source.CreatedAt = &created
+	}
+
+	switch {
+	case m.Video != nil:
+		v.Media = []mediaV2{{
+			Type:     memeservice.MediaVideo,
+			URL:      m.Video.URL,
+			Width:    m.Video.Width,
+			Height:   m.Video.Height,
+			Size:     m.Size,
+			DASHURL:  m.Video.DASHURL,
+			HLSURL:   m.Video.HLSURL,
+			Duration: m.Video.Duration,
+		}}
+	case len(m.Images) > 0:
+		for _, img := range m.Images {
+			v.Media = append(v.Media, mediaV2{
+				Type:     mediaType(m),
+				URL:      img.URL,
+				ProxyURL: img.ProxyURL,
+				Width:    img.Width,
+				Height:   img.Height,
+			})
+		}
+		// The pool only measures the first picture
+		v.Media[0].Size = m.Size
+	default:
+		v.Media = []mediaV2{{
+			Type:       mediaType(m),
+			URL:        m.URL,
+			Width:      m.Width,
+			Height:     m.Height,
+			Size:       m.Size,
+			Renditions: m.Renditions,
+		}}
+	}
+	return v
+}
+
+// mediaType returns the type of a picture meme, image unless it is a GIF
+func mediaType(m memeservice.Meme) string {
+	if m.MediaType == memeservice.MediaGIF {
+		return memeservice.MediaGIF
+	}
+	return memeservice.MediaImage
+}
+
+// toMemesV2 converts a list of memes to the v2 schema
+func toMemesV2(memes []memeservice.Meme) []memeV2 {
+	converted := make([]memeV2, 0, len(memes))
+	for _, m := range memes {
+		converted = append(converted, toMemeV2(m))
+	}
+	return converted
+}
+
+// toTrendingV2 converts rank jumps to the v2 schema
+func toTrendingV2(jumps []memeservice.Trending) []trendingV2 {
+	converted := make([]trendingV2, 0, len(jumps))
+	for _, t := range jumps {
+		converted = append(converted, trendingV2{
+			Meme:         toMemeV2(t.Meme),
+			ScoreDelta:   t.ScoreDelta,
+			Velocity:     t.Velocity,
+			Rank:         t.Rank,
+			PreviousRank: t.PreviousRank,
+		})
+	}
+	return converted
+}
+
+// schemaFor returns the schema conversion of the endpoint a stream was opened on
+func schemaFor(r *http.Request) broadcaster.Serializer {
+	if r.URL.Path == apiV2+"/stream" {
+		return v2Schema
+	}
+	return nil
+}
+
+// v2Schema rewrites the meme events of a stream into the v2 schema; other
+// events and non-JSON data, such as template output, pass unchanged
+var v2Schema = broadcaster.SerializerFunc(func(event broadcaster.Event) (broadcaster.Event, error) {
+	if !memeEvents[event.Name] || !json.Valid(event.Data) {
+		return event, nil
+	}
+
+	var converted any
+	switch event.Name {
+	case "":
+		var payload memePayload
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			return event, fmt.Errorf("failed to decode meme event: %v", err)
+		}
+		msg := messageV2{ConnID: payload.ConnID, Meme: toMemeV2(payload.Meme)}
+		if len(msg.Meme.Media) > 0 {
+			if payload.ProxyURL != "" {
+				msg.Meme.Media[0].ProxyURL = payload.ProxyURL
+			}
+			msg.Meme.Media[0].ImageData = payload.ImageData
+		}
+		converted = msg
+	case "trending":
+		var jumps []memeservice.Trending
+		if err := json.Unmarshal(event.Data, &jumps); err != nil {
+			return event, fmt.Errorf("failed to decode trending event: %v", err)
+		}
+		converted = toTrendingV2(jumps)
+	case "motd":
+		var motd motdResponse
+		if err := json.Unmarshal(event.Data, &motd); err != nil {
+			return event, fmt.Errorf("failed to decode motd event: %v", err)
+		}
+		converted = motdV2{Date: motd.Date, Meme: toMemeV2(motd.Meme)}
+	}
+
+	data, err := json.Marshal(converted)
+	if err != nil {
+		return event, err
+	}
+	event.Data = data
+	return event, nil
+})
+
+// handleMemesV2 lists the current pool in the v2 schema
+func (s *Server) handleMemesV2(w http.ResponseWriter, r *http.Request) {
+	if memes, ok := s.poolMemes(w, r); ok {
+		s.writeMemes(w, r, toMemesV2(memes))
+	}
+}
+
+// handleTrendingV2 lists the current rank jumps in the v2 schema
+func (s *Server) handleTrendingV2(w http.ResponseWriter, r *http.Request) {
+	if trending, ok := s.trending(w, r); ok {
+		s.writeMemes(w, r, toTrendingV2(trending))
+	}
+}
+
+// handleMotdV2 returns the meme of the day in the v2 schema
+func (s *Server) handleMotdV2(w http.ResponseWriter, r *http.Request) {
+	if date, meme, ok := s.motd(w, r); ok {
+		s.writeMemes(w, r, motdV2{Date: date, Meme: toMemeV2(meme)})
+	}
+}
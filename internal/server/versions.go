@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API version prefixes. Payloads under a version never change incompatibly;
+// breaking changes go to the next version instead
+const (
+	apiV1 = "/api/v1"
+	apiV2 = "/api/v2"
+)
+
+// SetAPISunset announces, with a Sunset header on every unversioned /api
+// response, when those aliases of the /api/v1 routes will be removed
+func (s *Server) SetAPISunset(t time.Time) {
+	s.apiSunset = t
+}
+
+// handleV1 registers a route under /api/v1 and at its original unversioned
+// /api path, which is kept for existing clients but marked deprecated
+func (s *Server) handleV1(mux *http.ServeMux, method, path string, handler http.HandlerFunc) {
+	mux.HandleFunc(method+" "+apiV1+path, handler)
+	mux.HandleFunc(method+" /api"+path, s.deprecated("/api", apiV1, handler))
+}
+
+// deprecated marks the responses of a route as deprecated, linking to the
+// same path with the from prefix replaced by its successor's
+func (s *Server) deprecated(from, successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link := s.basePath + successor + strings.TrimPrefix(r.URL.Path, from)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", link))
+		if !s.apiSunset.IsZero() {
+			w.Header().Set("Sunset", s.apiSunset.UTC().Format(http.TimeFormat))
+		}
+		next(w, r)
+	}
+}
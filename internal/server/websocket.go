@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	apierror "github.com/elt0nxale/meme-tunnel-streamer/internal/apierror"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	connectionmanager "github.com/elt0nxale/meme-tunnel-streamer/internal/connectionmanager"
+	middleware "github.com/elt0nxale/meme-tunnel-streamer/internal/middleware"
+)
+
+const (
+	// defaultAckTimeout is how long an event may stay unacknowledged before it is resent
+	defaultAckTimeout = 10 * time.Second
+
+	// maxUnacked bounds the events awaiting acknowledgement before the stream is closed
+	maxUnacked = 100
+)
+
+// wsFrame is the JSON message carrying one event to a WebSocket client
+type wsFrame struct {
+	ID          string          `json:"id"`
+	Event       string          `json:"event"` // SSE event name, "message" for memes
+	Data        json.RawMessage `json:"data"`
+	RetryMs     int64           `json:"retry_ms,omitempty"`
+	Redelivered bool            `json:"redelivered,omitempty"` // Resent because no ack arrived in time
+}
+
+// wsClientMessage is a JSON message sent by a WebSocket client
+type wsClientMessage struct {
+	Ack string `json:"ack"` // ID of a received event
+}
+
+// pendingFrame is an event sent in ack mode but not yet acknowledged
+type pendingFrame struct {
+	frame    wsFrame
+	sentAt   time.Time
+	attempts int
+}
+
+// wsTransport sends events as JSON WebSocket messages, optionally resending
+// them until the client acknowledges their IDs
+type wsTransport struct {
+	conn       *websocket.Conn
+	connID     string
+	ack        bool
+	ackTimeout time.Duration
+	logEvent   func(string) // Records to the connection log
+	capture    func([]byte) // Records each message payload, nil unless frame capture is enabled
+
+	mu       sync.Mutex // Serializes writes and guards the fields below
+	frameSeq int        // Numbers events that carry no ID of their own
+	pending  map[string]*pendingFrame
+}
+
+// isWebSocketUpgrade reports whether a request asks to switch to WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// hijackWriter exposes Hijack through middleware wrappers that only implement Unwrap
+type hijackWriter struct {
+	http.ResponseWriter
+}
+
+// Hijack takes over the underlying connection
+func (hw hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(hw.ResponseWriter).Hijack()
+}
+
+// checkWebSocketOrigin refuses upgrades from cross-site pages, which browsers
+// let open WebSockets with the user's cookies regardless of CORS
+func (s *Server) checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser
+		return nil
+	}
+	if !middleware.OriginAllowed(s.allowedOrigins, origin, r.Host) {
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+
+	var err error
+	config.Origin, err = websocket.Origin(config, r)
+	return err
+}
+
+// serveWebSocket upgrades the request and runs the stream over the WebSocket;
+// ?ack=1 turns on acknowledged delivery with ?ack_timeout= (default 10s)
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, connID string, run func(*http.Request, transport)) {
+	query := r.URL.Query()
+
+	ack := false
+	if raw := query.Get("ack"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("invalid ack"))
+			return
+		}
+		ack = parsed
+	}
+
+	ackTimeout := defaultAckTimeout
+	if raw := query.Get("ack_timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < time.Second {
+			apierror.Write(w, r, apierror.BadRequest("invalid ack_timeout, expected a duration of at least 1s"))
+			return
+		}
+		ackTimeout = parsed
+	}
+
+	server := websocket.Server{Handshake: s.checkWebSocketOrigin, Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		t := &wsTransport{
+			conn:       conn,
+			connID:     connID,
+			ack:        ack,
+			ackTimeout: ackTimeout,
+			logEvent:   func(event string) { s.connectionManager.AddConnectionEvent(connID, event) },
+			capture:    s.frameCapture(connID, connectionmanager.TransportWebSocket),
+			pending:    make(map[string]*pendingFrame),
+		}
+		if ack {
+			t.logEvent(fmt.Sprintf("WebSocket with acknowledged delivery, timeout %s", ackTimeout))
+			go t.retransmit(ctx)
+		} else {
+			t.logEvent("WebSocket")
+		}
+
+		// The stream ends when the client goes away
+		go func() {
+			t.readAcks()
+			cancel()
+		}()
+
+		run(r.WithContext(ctx), t)
+	}}
+	server.ServeHTTP(hijackWriter{w}, r)
+}
+
+// send writes an event as a JSON message, tracking it until acknowledged in ack mode
+func (t *wsTransport) send(event broadcaster.Event) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ack && len(t.pending) >= maxUnacked {
+		return 0, fmt.Errorf("%d events unacknowledged", len(t.pending))
+	}
+
+	frame := wsFrame{ID: event.ID, Event: event.Name, Data: event.Data, RetryMs: event.Retry.Milliseconds()}
+	if frame.Event == "" {
+		frame.Event = "message"
+	}
+	if frame.ID == "" {
+		t.frameSeq++
+		frame.ID = fmt.Sprintf("%s#%d", t.connID, t.frameSeq)
+	}
+	if !json.Valid(frame.Data) {
+		frame.Data, _ = json.Marshal(string(event.Data))
+	}
+
+	n, err := t.write(frame)
+	if err != nil {
+		return n, err
+	}
+
+	// Latency probes are not worth resending
+	if t.ack && event.Name != "ping" {
+		t.pending[frame.ID] = &pendingFrame{frame: frame, sentAt: time.Now(), attempts: 1}
+	}
+	return n, nil
+}
+
+// write sends one frame; callers hold mu
+func (t *wsTransport) write(frame wsFrame) (int, error) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode frame: %v", err)
+	}
+	if err := websocket.Message.Send(t.conn, string(data)); err != nil {
+		return 0, err
+	}
+	if t.capture != nil {
+		t.capture(data)
+	}
+	return len(data), nil
+}
+
+// readAcks consumes client messages until the connection closes
+func (t *wsTransport) readAcks() {
+	for {
+		var raw string
+		if err := websocket.Message.Receive(t.conn, &raw); err != nil {
+			return
+		}
+
+		var msg wsClientMessage
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil || msg.Ack == "" {
+			continue
+		}
+
+		t.mu.Lock()
+		delete(t.pending, msg.Ack)
+		t.mu.Unlock()
+	}
+}
+
+// retransmit resends events whose acknowledgement is overdue, oldest first
+func (t *wsTransport) retransmit(ctx context.Context) {
+	ticker := time.NewTicker(t.ackTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.mu.Lock()
+			var due []*pendingFrame
+			for _, p := range t.pending {
+				if now.Sub(p.sentAt) >= t.ackTimeout {
+					due = append(due, p)
+				}
+			}
+			sort.Slice(due, func(i, j int) bool { return due[i].sentAt.Before(due[j].sentAt) })
+
+			for _, p := range due {
+				p.frame.Redelivered = true
+				if _, err := t.write(p.frame); err != nil {
+					t.mu.Unlock()
+					return
+				}
+				p.sentAt = now
+				p.attempts++
+				t.logEvent(fmt.Sprintf("Resent unacknowledged event %s (attempt %d)", p.frame.ID, p.attempts))
+			}
+			t.mu.Unlock()
+		}
+	}
+}
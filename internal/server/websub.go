@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	websub "github.com/elt0nxale/meme-tunnel-streamer/internal/websub"
+)
+
+const (
+	// websubDelay coalesces bursts of new memes, such as a refresh followed by
+	// approved submissions, into one hub ping
+	websubDelay = 5 * time.Second
+
+	// websubTimeout bounds a hub ping
+	websubTimeout = 10 * time.Second
+)
+
+// SetWebSub advertises hub in the RSS feed and pings it whenever memes enter
+// the pool. topic is the feed's public URL, which subscribers and the hub
+// fetch; empty uses the tunnel URL
+func (s *Server) SetWebSub(hub, topic string) error {
+	if !isHTTPURL(hub) {
+		return fmt.Errorf("invalid WebSub hub %q, expected an http or https URL", hub)
+	}
+	if topic != "" && !isHTTPURL(topic) {
+		return fmt.Errorf("invalid WebSub topic %q, expected an http or https URL", topic)
+	}
+
+	s.websubHub = hub
+	s.websubTopic = topic
+	s.websubClient = &http.Client{Timeout: websubTimeout}
+	s.memeService.OnNewMemes(func([]memeservice.Meme) { s.publishFeed() })
+	return nil
+}
+
+// isHTTPURL reports whether raw is an absolute http or https URL
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// feedTopic is the public URL of the feed: the configured topic, else the
+// feed under the tunnel URL, else as requested by r, which may be nil
+func (s *Server) feedTopic(r *http.Request) string {
+	if s.websubTopic != "" {
+		return s.websubTopic
+	}
+	if tunnel, ok := s.tunnelURL.Load().(string); ok && tunnel != "" {
+		return strings.TrimSuffix(tunnel, "/") + s.basePath + "/feed.xml"
+	}
+	if r == nil {
+		return ""
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s%s", scheme, r.Host, s.basePath, r.URL.Path)
+}
+
+// publishFeed pings the hub websubDelay after new memes first arrive; calls
+// while a ping is pending join it
+func (s *Server) publishFeed() {
+	if !s.websubPending.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		time.Sleep(websubDelay)
+		s.websubPending.Store(false)
+
+		topic := s.feedTopic(nil)
+		if topic == "" {
+			s.logger.Printf("WebSub: no public feed URL to publish yet")
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), websubTimeout)
+		defer cancel()
+
+		if err := websub.Publish(ctx, s.websubClient, s.websubHub, topic); err != nil {
+			s.logger.Printf("WebSub publish failed: %v", err)
+			return
+		}
+		s.logger.Printf("Published %s to WebSub hub %s", topic, s.websubHub)
+	}()
+}
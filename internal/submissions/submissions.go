@@ -0,0 +1,291 @@
+// Package submissions keeps memes posted by users, with uploaded images
+// stored in a local directory next to the index of submissions.
+package submissions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	imagemeta "github.com/elt0nxale/meme-tunnel-streamer/internal/imagemeta"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+)
+
+// indexFile holds the submissions as JSON inside the store's directory
+const indexFile = "submissions.json"
+
+// imageTypes maps the accepted upload content types to file extensions
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Moderation states of a submission; only approved memes enter the pool
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+var (
+	// ErrNotFound is returned when moderating an unknown submission
+	ErrNotFound = errors.New("submission not found")
+
+	// ErrRejected is returned when moderating a submission that was already rejected
+	ErrRejected = errors.New("submission was already rejected")
+)
+
+// Submission is a meme posted by a user
+type Submission struct {
+	Meme        memeservice.Meme `json:"meme"`
+	Status      string           `json:"status"`
+	SubmittedBy string           `json:"submitted_by"` // Actor who posted it, e.g. key:<name>
+	SubmittedAt time.Time        `json:"submitted_at"`
+	Upload      string           `json:"upload,omitempty"` // File name of an uploaded image
+	ModeratedBy string           `json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time       `json:"moderated_at,omitempty"`
+	Reason      string           `json:"reason,omitempty"` // Moderator's note, e.g. why it was rejected
+}
+
+// Upload describes an image saved by SaveImage
+type Upload struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Width       int // Zero when the format cannot be decoded, e.g. WebP
+	Height      int
+}
+
+// Store persists submissions and their uploaded images in a directory
+type Store struct {
+	dir   string
+	strip bool // Remove metadata from uploaded images
+
+	mu          sync.Mutex
+	submissions []Submission // Oldest first
+	next        int          // Last submission number issued
+}
+
+// Open loads the submissions saved in dir, creating it if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create submissions directory: %v", err)
+	}
+
+	st := &Store{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submissions: %v", err)
+	}
+	if err := json.Unmarshal(data, &st.submissions); err != nil {
+		return nil, fmt.Errorf("failed to parse submissions: %v", err)
+	}
+	st.next = len(st.submissions)
+	for i, sub := range st.submissions {
+		// Submissions saved before moderation existed went straight to the pool
+		if sub.Status == "" {
+			st.submissions[i].Status = StatusApproved
+		}
+		var n int
+		if _, err := fmt.Sscanf(sub.Meme.ID, "sub_%d", &n); err == nil && n > st.next {
+			st.next = n
+		}
+	}
+	return st, nil
+}
+
+// Memes returns the memes of approved submissions, oldest first
+func (st *Store) Memes() []memeservice.Meme {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	memes := make([]memeservice.Meme, 0, len(st.submissions))
+	for _, sub := range st.submissions {
+		if sub.Status == StatusApproved {
+			memes = append(memes, sub.Meme)
+		}
+	}
+	return memes
+}
+
+// List returns the submissions in a status, or all of them for "", oldest first
+func (st *Store) List(status string) []Submission {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	subs := []Submission{}
+	for _, sub := range st.submissions {
+		if status == "" || sub.Status == status {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// Add records a submission as pending, giving its meme an ID, and saves the index
+func (st *Store) Add(sub Submission) (Submission, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.next++
+	sub.Meme.ID = fmt.Sprintf("sub_%d", st.next)
+	sub.Status = StatusPending
+	if sub.SubmittedAt.IsZero() {
+		sub.SubmittedAt = time.Now()
+	}
+
+	st.submissions = append(st.submissions, sub)
+	if err := st.save(); err != nil {
+		st.submissions = st.submissions[:len(st.submissions)-1]
+		return Submission{}, err
+	}
+	return sub, nil
+}
+
+// Moderate approves or rejects a submission. Approved submissions may still
+// be rejected later to take them down; rejection is final and deletes the upload
+func (st *Store) Moderate(id, status, moderator, reason string) (Submission, error) {
+	if status != StatusApproved && status != StatusRejected {
+		return Submission{}, fmt.Errorf("invalid moderation status %q", status)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	i := slices.IndexFunc(st.submissions, func(sub Submission) bool { return sub.Meme.ID == id })
+	if i < 0 {
+		return Submission{}, ErrNotFound
+	}
+	previous := st.submissions[i]
+	if previous.Status == StatusRejected {
+		return Submission{}, ErrRejected
+	}
+
+	now := time.Now()
+	sub := previous
+	sub.Status = status
+	sub.ModeratedBy = moderator
+	sub.ModeratedAt = &now
+	sub.Reason = reason
+	st.submissions[i] = sub
+	if err := st.save(); err != nil {
+		st.submissions[i] = previous
+		return Submission{}, err
+	}
+
+	if status == StatusRejected && sub.Upload != "" {
+		if err := os.Remove(filepath.Join(st.dir, sub.Upload)); err != nil && !os.IsNotExist(err) {
+			return sub, fmt.Errorf("failed to delete upload: %v", err)
+		}
+	}
+	return sub, nil
+}
+
+// save writes the index to disk; callers hold mu
+func (st *Store) save() error {
+	data, err := json.MarshalIndent(st.submissions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode submissions: %v", err)
+	}
+
+	path := filepath.Join(st.dir, indexFile)
+	tmp := filepath.Join(st.dir, "."+indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save submissions: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save submissions: %v", err)
+	}
+	return nil
+}
+
+// SaveImage stores an uploaded image of at most maxBytes under a random
+// name, rejecting anything that is not a PNG, JPEG, GIF or WebP image
+func (st *Store) SaveImage(r io.Reader, maxBytes int64) (Upload, error) {
+	// Read one byte past the limit to detect oversized uploads
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return Upload{}, fmt.Errorf("failed to read upload: %v", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return Upload{}, fmt.Errorf("image is larger than %d bytes", maxBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return Upload{}, fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	if st.strip {
+		if data, err = imagemeta.Strip(data); err != nil {
+			return Upload{}, fmt.Errorf("invalid image: %v", err)
+		}
+	}
+
+	upload := Upload{ContentType: contentType, Size: int64(len(data))}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		upload.Width = config.Width
+		upload.Height = config.Height
+	} else if ext != ".webp" {
+		return Upload{}, fmt.Errorf("invalid image: %v", err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Upload{}, fmt.Errorf("failed to name upload: %v", err)
+	}
+	upload.Name = hex.EncodeToString(id) + ext
+
+	if err := os.WriteFile(filepath.Join(st.dir, upload.Name), data, 0o644); err != nil {
+		return Upload{}, fmt.Errorf("failed to save upload: %v", err)
+	}
+	return upload, nil
+}
+
+// SetStripMetadata removes EXIF, XMP and text metadata, such as camera
+// details and locations, from images saved from now on
+func (st *Store) SetStripMetadata(strip bool) {
+	st.strip = strip
+}
+
+// ImagePath returns the file of an uploaded image, false for names that are
+// not uploads, such as the index or paths outside the directory
+func (st *Store) ImagePath(name string) (string, bool) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	known := false
+	for _, ext := range imageTypes {
+		known = known || strings.HasSuffix(name, ext)
+	}
+	if !known {
+		return "", false
+	}
+
+	path := filepath.Join(st.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,54 @@
+package submissions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestImagePath checks only uploaded images inside the store are served
+func TestImagePath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	for _, name := range []string{"1.png", "2.jpg", ".hidden.png", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "outside.png"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"1.png", true},
+		{"2.jpg", true},
+		{"3.gif", false},
+		{".hidden.png", false},
+		{"notes.txt", false},
+		{"../outside.png", false},
+		{"..", false},
+		{"sub/1.png", false},
+		{"/etc/passwd", false},
+		{"1.png/", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		path, ok := st.ImagePath(tt.name)
+		if ok != tt.want {
+			t.Errorf("ImagePath(%q) = %q, %v, want ok %v", tt.name, path, ok, tt.want)
+			continue
+		}
+		if ok && path != filepath.Join(dir, tt.name) {
+			t.Errorf("ImagePath(%q) = %q, want a file in %s", tt.name, path, dir)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package useragent extracts the browser, OS and device class from User-Agent
+// strings, well enough to group clients in debug output.
+package useragent
+
+import (
+	"strings"
+)
+
+// Device classes
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other" // Libraries and command-line tools
+)
+
+// Agent is a parsed User-Agent
+type Agent struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version,omitempty"` // Major version only
+	OS             string `json:"os,omitempty"`
+	OSVersion      string `json:"os_version,omitempty"`
+	Device         string `json:"device"`
+}
+
+// String describes the agent as e.g. "Safari 17 on iOS 17.1 (mobile)"
+func (a Agent) String() string {
+	s := a.Browser
+	if a.BrowserVersion != "" {
+		s += " " + a.BrowserVersion
+	}
+	if a.OS != "" {
+		s += " on " + a.OS
+		if a.OSVersion != "" {
+			s += " " + a.OSVersion
+		}
+	}
+	return s + " (" + a.Device + ")"
+}
+
+// browsers maps User-Agent tokens to browser names, checked in order since
+// most browsers also claim to be Safari or Chrome
+var browsers = []struct {
+	token, name string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"FxiOS/", "Firefox"},
+	{"Firefox/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chromium/", "Chromium"},
+	{"Chrome/", "Chrome"},
+	{"Version/", "Safari"}, // Safari carries its version here
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"Go-http-client/", "Go"},
+	{"okhttp/", "OkHttp"},
+	{"python-requests/", "Python Requests"},
+	{"node-fetch/", "node-fetch"},
+}
+
+// tools are browsers that are really HTTP libraries
+var tools = map[string]bool{
+	"curl": true, "Wget": true, "Go": true, "OkHttp": true, "Python Requests": true, "node-fetch": true,
+}
+
+// Parse parses a User-Agent header; unknown agents give Browser "Other"
+func Parse(ua string) Agent {
+	agent := Agent{Browser: "Other", Device: DeviceDesktop}
+
+	for _, b := range browsers {
+		if version, found := after(ua, b.token); found {
+			agent.Browser = b.name
+			agent.BrowserVersion = major(version)
+			break
+		}
+	}
+
+	agent.OS, agent.OSVersion = parseOS(ua)
+	agent.Device = device(ua, agent)
+	return agent
+}
+
+// parseOS finds the operating system and its version
+func parseOS(ua string) (string, string) {
+	switch {
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad") || strings.Contains(ua, "iPod"):
+		version, _ := after(ua, " OS ")
+		return "iOS", strings.ReplaceAll(upTo(version, " "), "_", ".")
+	case strings.Contains(ua, "Android"):
+		version, _ := after(ua, "Android ")
+		return "Android", upTo(version, ";)")
+	case strings.Contains(ua, "Windows NT"):
+		version, _ := after(ua, "Windows NT ")
+		return "Windows", windowsVersion(upTo(version, ";)"))
+	case strings.Contains(ua, "CrOS"):
+		return "ChromeOS", ""
+	case strings.Contains(ua, "Mac OS X"):
+		version, _ := after(ua, "Mac OS X ")
+		return "macOS", strings.ReplaceAll(upTo(version, ";)"), "_", ".")
+	case strings.Contains(ua, "Linux"):
+		return "Linux", ""
+	}
+	return "", ""
+}
+
+// windowsVersion names the marketing version of an NT version
+func windowsVersion(nt string) string {
+	switch nt {
+	case "10.0":
+		return "10" // Windows 11 reports 10.0 too
+	case "6.3":
+		return "8.1"
+	case "6.2":
+		return "8"
+	case "6.1":
+		return "7"
+	}
+	return nt
+}
+
+// device classifies the kind of client
+func device(ua string, agent Agent) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider"):
+		return DeviceBot
+	case tools[agent.Browser]:
+		return DeviceOther
+	case strings.Contains(ua, "iPad") || strings.Contains(lower, "tablet"):
+		return DeviceTablet
+	case agent.OS == "Android" && !strings.Contains(ua, "Mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "Mobi") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		return DeviceMobile
+	}
+	return DeviceDesktop
+}
+
+// after returns what follows token in s
+func after(s, token string) (string, bool) {
+	i := strings.Index(s, token)
+	if i < 0 {
+		return "", false
+	}
+	return s[i+len(token):], true
+}
+
+// upTo returns s up to the first of any of the stop characters
+func upTo(s, stops string) string {
+	if i := strings.IndexAny(s, stops); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// major returns the leading major version number of a version string
+func major(version string) string {
+	return upTo(version, ". ;)")
+}
@@ -0,0 +1,105 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// recordSize is the aes128gcm record size advertised in the header
+const recordSize = 4096
+
+// encrypt seals a payload for a subscription with the aes128gcm content coding
+func encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	clientPublic, err := decodeKey(sub.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	authSecret, err := decodeKey(sub.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %v", err)
+	}
+	if len(payload)+17 > recordSize {
+		return nil, fmt.Errorf("payload of %d bytes is too large", len(payload))
+	}
+
+	uaPublic, err := ecdh.P256().NewPublicKey(clientPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+
+	// A fresh key pair per message
+	local, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	shared, err := local.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %v", err)
+	}
+	asPublic := local.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	// Combine the shared secret with the subscription's auth secret
+	keyInfo := append(append([]byte("WebPush: info\x00"), clientPublic...), asPublic...)
+	ikm, err := derive(shared, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := derive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := derive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	// A single record, terminated by the last-record delimiter
+	plaintext := append(append([]byte(nil), payload...), 0x02)
+
+	header := make([]byte, 0, 86)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// derive runs HKDF-SHA256 with the given secret, salt and info
+func derive(secret, salt, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		return nil, fmt.Errorf("key derivation failed: %v", err)
+	}
+	return out, nil
+}
+
+// decodeKey accepts the base64url keys browsers report, padded or not
+func decodeKey(key string) ([]byte, error) {
+	if raw, err := base64.RawURLEncoding.DecodeString(key); err == nil {
+		return raw, nil
+	}
+	return base64.URLEncoding.DecodeString(key)
+}
@@ -0,0 +1,128 @@
+// Package webpush sends encrypted Web Push messages (RFC 8291) authenticated
+// with VAPID (RFC 8292), and stores the browser subscriptions to send them to.
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// vapidTokenTTL is the lifetime of the signed JWT sent with each push
+const vapidTokenTTL = 12 * time.Hour
+
+// Keys is a VAPID application server key pair
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  []byte // Uncompressed P-256 point
+}
+
+// GenerateKeys creates a new VAPID key pair
+func GenerateKeys() (*Keys, error) {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate VAPID key: %v", err)
+	}
+	return keysFrom(private)
+}
+
+// ParseKeys loads a key pair from the base64url private key printed by PrivateKey
+func ParseKeys(privateKey string) (*Keys, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %v", err)
+	}
+	private, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %v", err)
+	}
+	return keysFrom(private)
+}
+
+// LoadOrGenerateKeys reads the key pair saved at path, or generates one and
+// saves it there readable by the owner only, so subscriptions outlive restarts
+// without the private key ever reaching the log
+func LoadOrGenerateKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ParseKeys(strings.TrimSpace(string(data)))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read VAPID key: %v", err)
+	}
+
+	keys, err := GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(keys.PrivateKey()+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save VAPID key: %v", err)
+	}
+	return keys, nil
+}
+
+// keysFrom converts an ECDH key into the ECDSA form needed for signing
+func keysFrom(private *ecdh.PrivateKey) (*Keys, error) {
+	public := private.PublicKey().Bytes()
+	return &Keys{
+		private: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(private.Bytes()),
+		},
+		public: public,
+	}, nil
+}
+
+// PublicKey returns the base64url application server key browsers subscribe with
+func (k *Keys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.public)
+}
+
+// PrivateKey returns the base64url private key, for persisting generated keys
+func (k *Keys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.D.FillBytes(make([]byte, 32)))
+}
+
+// authorization builds the VAPID Authorization header for a push endpoint
+func (k *Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %v", err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenTTL).Unix(),
+		"sub": subject,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %v", err)
+	}
+
+	// JWS wants the raw 64-byte r||s form rather than ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey()), nil
+}
@@ -0,0 +1,328 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSubscriptions bounds the subscriptions stored in total
+	MaxSubscriptions = 10000
+
+	// MaxOwnerSubscriptions bounds the subscriptions stored for one owner
+	MaxOwnerSubscriptions = 10
+
+	// saveDelay batches the writes of subscription changes to disk
+	saveDelay = 5 * time.Second
+
+	// maxConcurrentSends bounds the pushes in flight during a broadcast
+	maxConcurrentSends = 16
+
+	// sendTimeout bounds one push, so a slow push service cannot hold up the rest
+	sendTimeout = 10 * time.Second
+)
+
+var (
+	// ErrSubscriptionLimit refuses subscriptions beyond MaxSubscriptions or
+	// MaxOwnerSubscriptions
+	ErrSubscriptionLimit = errors.New("too many push subscriptions")
+
+	// ErrNotOwner refuses changes to a subscription stored by someone else
+	ErrNotOwner = errors.New("push subscription belongs to another owner")
+)
+
+// Subscription is a browser's PushSubscription as serialised by toJSON()
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	Owner string `json:"owner,omitempty"` // Who subscribed, set by the server rather than the browser
+}
+
+// Validate checks that a subscription can be pushed to
+func (sub Subscription) Validate() error {
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint must be an https URL")
+	}
+	if key, err := decodeKey(sub.Keys.P256dh); err != nil || len(key) != 65 {
+		return fmt.Errorf("keys.p256dh must be a base64url P-256 public key")
+	}
+	if secret, err := decodeKey(sub.Keys.Auth); err != nil || len(secret) != 16 {
+		return fmt.Errorf("keys.auth must be a base64url 16-byte secret")
+	}
+	return nil
+}
+
+// Notification is the JSON payload the service worker turns into a notification
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Image string `json:"image,omitempty"`
+	URL   string `json:"url,omitempty"` // Opened when the notification is clicked
+	Tag   string `json:"tag,omitempty"` // Replaces an earlier notification with the same tag
+}
+
+// Pusher stores subscriptions and sends notifications to them
+type Pusher struct {
+	keys    *Keys
+	subject string // mailto: or https: contact for push services
+	client  *http.Client
+	path    string // File subscriptions are persisted to, empty to keep them in memory
+
+	mu        sync.Mutex
+	subs      map[string]Subscription // By endpoint
+	saveTimer *time.Timer             // Pending write of changes, nil when saved
+}
+
+// New creates a pusher, loading subscriptions saved at path if it is set
+func New(keys *Keys, subject, path string, client *http.Client) (*Pusher, error) {
+	p := &Pusher{
+		keys:    keys,
+		subject: subject,
+		client:  client,
+		path:    path,
+		subs:    make(map[string]Subscription),
+	}
+
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push subscriptions: %v", err)
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse push subscriptions: %v", err)
+	}
+	for _, sub := range subs {
+		p.subs[sub.Endpoint] = sub
+	}
+	return p, nil
+}
+
+// PublicKey returns the application server key for PushManager.subscribe
+func (p *Pusher) PublicKey() string {
+	return p.keys.PublicKey()
+}
+
+// Subscribe stores a subscription, replacing its owner's earlier one for the
+// same endpoint. Repeating a subscription changes nothing; new ones count
+// against the limits, and endpoints stored by another owner are refused
+func (p *Pusher) Subscribe(sub Subscription) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing, found := p.subs[sub.Endpoint]
+	if found && existing == sub {
+		return nil
+	}
+	if found && existing.Owner != "" && existing.Owner != sub.Owner {
+		return ErrNotOwner
+	}
+	if !found || existing.Owner != sub.Owner {
+		owned := 0
+		for _, s := range p.subs {
+			if s.Owner == sub.Owner {
+				owned++
+			}
+		}
+		if (!found && len(p.subs) >= MaxSubscriptions) || owned >= MaxOwnerSubscriptions {
+			return ErrSubscriptionLimit
+		}
+	}
+
+	p.subs[sub.Endpoint] = sub
+	p.scheduleSave()
+	return nil
+}
+
+// Unsubscribe forgets the subscription for an endpoint if owner stored it; an
+// empty owner removes any subscription. Unknown endpoints are not an error
+func (p *Pusher) Unsubscribe(endpoint, owner string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing, found := p.subs[endpoint]
+	if !found {
+		return nil
+	}
+	if owner != "" && existing.Owner != "" && existing.Owner != owner {
+		return ErrNotOwner
+	}
+	delete(p.subs, endpoint)
+	p.scheduleSave()
+	return nil
+}
+
+// Count returns the number of stored subscriptions
+func (p *Pusher) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.subs)
+}
+
+// Close writes any pending subscription changes to disk
+func (p *Pusher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.saveTimer == nil {
+		return nil
+	}
+	p.saveTimer.Stop()
+	p.saveTimer = nil
+	return p.save()
+}
+
+// scheduleSave writes the subscriptions after saveDelay, so a burst of
+// changes rewrites the file once; callers hold mu
+func (p *Pusher) scheduleSave() {
+	if p.path == "" || p.saveTimer != nil {
+		return
+	}
+	p.saveTimer = time.AfterFunc(saveDelay, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.saveTimer = nil
+		if err := p.save(); err != nil {
+			log.Printf("%v", err)
+		}
+	})
+}
+
+// save writes the subscriptions to disk; callers hold mu
+func (p *Pusher) save() error {
+	if p.path == "" {
+		return nil
+	}
+
+	subs := make([]Subscription, 0, len(p.subs))
+	for _, sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	data, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode push subscriptions: %v", err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(p.path), "."+filepath.Base(p.path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save push subscriptions: %v", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to save push subscriptions: %v", err)
+	}
+	return nil
+}
+
+// Broadcast pushes a notification to every subscription, maxConcurrentSends at
+// a time, and returns how many pushes were accepted and how many failed.
+// Subscriptions the push service reports as gone are dropped
+func (p *Pusher) Broadcast(ctx context.Context, n Notification, ttl time.Duration) (sent, failed int) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("Error encoding push notification: %v", err)
+		return 0, 0
+	}
+
+	p.mu.Lock()
+	subs := make([]Subscription, 0, len(p.subs))
+	for _, sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	p.mu.Unlock()
+
+	var (
+		wg       sync.WaitGroup
+		countsMu sync.Mutex
+		slots    = make(chan struct{}, maxConcurrentSends)
+	)
+	for _, sub := range subs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+
+			status, err := p.send(sendCtx, sub, payload, ttl)
+			switch {
+			case status == http.StatusNotFound || status == http.StatusGone:
+				p.Unsubscribe(sub.Endpoint, "")
+			case err != nil:
+				log.Printf("Push to %s failed: %v", sub.Endpoint, err)
+			}
+
+			countsMu.Lock()
+			defer countsMu.Unlock()
+			if err != nil {
+				failed++
+			} else {
+				sent++
+			}
+		}()
+	}
+	wg.Wait()
+
+	return sent, failed
+}
+
+// send delivers one encrypted message and returns the push service's status
+func (p *Pusher) send(ctx context.Context, sub Subscription, payload []byte, ttl time.Duration) (int, error) {
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := p.keys.authorization(sub.Endpoint, p.subject, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to push: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("push service returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp.StatusCode, nil
+}
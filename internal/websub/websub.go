@@ -0,0 +1,38 @@
+// Package websub pings WebSub (formerly PubSubHubbub) hubs when a feed
+// changes, so its subscribers are pushed updates instead of polling
+package websub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Publish tells hub that topic has new content with the hub.mode=publish
+// ping the common hubs accept; the hub then fetches topic and pushes it to
+// its subscribers
+func Publish(ctx context.Context, client *http.Client, hub, topic string) error {
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {topic}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "MemeSSEDebugger/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping hub %s: %v", hub, err)
+	}
+	defer resp.Body.Close()
+
+	// Hubs answer 204 No Content, some 200 or 202
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub %s returned %s: %s", hub, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -1,66 +1,1181 @@
 package main
 
 import (
-	"embed"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
-	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/cors"
 	"github.com/urfave/cli/v2"
 	"golang.ngrok.com/ngrok"
 	"golang.ngrok.com/ngrok/config"
 
-	"meme-fetcher/internal/server"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/bench"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/blocklist"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/cache"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/clientip"
+	appconfig "github.com/elt0nxale/meme-tunnel-streamer/internal/config"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/doctor"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/imageproxy"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/journal"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/kafkasink"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/listener"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/loadtest"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/logfile"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/middleware"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/netguard"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/redditmock"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/requestid"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/s3archive"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/server"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/submissions"
+	"github.com/elt0nxale/meme-tunnel-streamer/internal/webpush"
+	"github.com/elt0nxale/meme-tunnel-streamer/web"
 )
 
-//go:embed web/*
-var content embed.FS
-
 func main() {
 
-	// Load environment variables from the .env file
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	// Load environment variables from the .env file when there is one; settings
+	// may come from the environment instead, and doctor reports what is missing
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Ignoring .env file: %v", err)
 	}
 
 	app := &cli.App{
 		Name:  "meme-feetcher",
 		Usage: "Server-Sent Events Meme Debugger with Ngrok Tunneling",
+		Commands: []*cli.Command{
+			loadtestCommand(),
+			benchCommand(),
+			doctorCommand(),
+			blocklistHashCommand(),
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "YAML config file with reloadable settings (reloaded on SIGHUP)",
+			},
+			&cli.BoolFlag{
+				Name:  "dev",
+				Usage: "Serve templates and assets from ./web on disk and live-reload clients on changes",
+			},
+			&cli.StringFlag{
+				Name:  "templates-dir",
+				Usage: "Directory of templates and assets overriding the embedded client page; missing files fall back to the embedded ones",
+			},
+			&cli.BoolFlag{
+				Name:  "mock-upstream",
+				Usage: "Fetch memes from a built-in fake Reddit instead of reddit.com",
+			},
+			&cli.DurationFlag{
+				Name:  "mock-latency",
+				Usage: "Latency added to every mock upstream response",
+			},
+			&cli.Float64Flag{
+				Name:  "mock-error-rate",
+				Usage: "Fraction of mock listing requests that fail with 503 (0-1)",
+			},
+			&cli.StringFlag{
+				Name:  "mock-fixture",
+				Usage: "Reddit listing JSON file served by the mock for every subreddit",
+			},
 			&cli.IntFlag{
 				Name:  "port",
 				Value: 8080,
 				Usage: "Local server port",
 			},
+			&cli.StringSliceFlag{
+				Name:  "listen",
+				Usage: "Listen address as addr[=all|public|admin], may be repeated (overrides --port)",
+			},
+			&cli.StringFlag{
+				Name:  "base-path",
+				Usage: "Serve all routes under this path prefix, e.g. /memes-app",
+			},
+			&cli.StringFlag{
+				Name:  "api-sunset",
+				Usage: "Date (YYYY-MM-DD) announced in a Sunset header on the deprecated unversioned /api routes",
+			},
+			&cli.StringFlag{
+				Name:  "debug-path",
+				Value: server.DefaultDebugPath,
+				Usage: "Path of the connection log dump; the state dump is served under <path>/state",
+			},
+			&cli.BoolFlag{
+				Name:  "disable-debug",
+				Usage: "Do not serve the connection log and state dumps",
+			},
+			&cli.DurationFlag{
+				Name:  "log-retention",
+				Usage: "Purge ended connection logs and per-client send history older than this, e.g. 24h (0 keeps them until evicted)",
+			},
+			&cli.StringFlag{
+				Name:  "log-file",
+				Usage: "Also write the process log to this file, rotated per --log-max-size and --log-max-age",
+			},
+			&cli.StringFlag{
+				Name:  "connection-log",
+				Usage: "Append the log of every ended connection to this file as JSON lines, rotated like --log-file",
+			},
+			&cli.StringFlag{
+				Name:  "log-max-size",
+				Value: "100MB",
+				Usage: "Rotate log files before they grow past this size (e.g. 500KB, 100MB; 0 for no limit)",
+			},
+			&cli.DurationFlag{
+				Name:  "log-max-age",
+				Usage: "Rotate log files after writing to them for this long, e.g. 24h (0 for no limit)",
+			},
+			&cli.IntFlag{
+				Name:  "log-keep",
+				Value: 7,
+				Usage: "Rotated log files kept per log, oldest deleted first (0 keeps all)",
+			},
+			&cli.BoolFlag{
+				Name:  "log-compress",
+				Usage: "Gzip rotated log files",
+			},
+			&cli.IntFlag{
+				Name:  "capture-frames",
+				Usage: "Record the last N writes to every stream, byte for byte, for /debug/connections/{id}/frames (0 disables)",
+			},
+			&cli.StringFlag{
+				Name:  "capture-bytes",
+				Value: "64KB",
+				Usage: "Most captured bytes kept per stream with --capture-frames",
+			},
+			&cli.Float64Flag{
+				Name:  "debug-sample-rate",
+				Value: 1,
+				Usage: "Fraction of connections, 0 to 1, whose headers and events are logged; the rest are only counted",
+			},
+			&cli.StringFlag{
+				Name:  "submissions-dir",
+				Usage: "Enable POST /api/memes, storing submitted memes and uploaded images in this directory",
+			},
+			&cli.StringFlag{
+				Name:  "local-dir",
+				Usage: "Also stream the images and videos in this directory, served from /local/, picking up new files as they appear",
+			},
+			&cli.BoolFlag{
+				Name:  "captions",
+				Usage: "Enable POST /api/caption, which draws top and bottom text over an image and can broadcast the result",
+			},
+			&cli.StringFlag{
+				Name:  "caption-templates",
+				Usage: "Directory of blank meme images that POST /api/caption accepts by file name (implies --captions)",
+			},
+			&cli.StringFlag{
+				Name:  "meme-dataset",
+				Usage: "JSON file of meme formats (name, url, origin, year, about, aliases) whose background is attached to memes whose titles name them",
+			},
+			&cli.StringFlag{
+				Name:  "blocklist",
+				Usage: "File of sha256:<hex> and dhash:<hex> image hashes kept out of the pool and submissions, reloaded on change",
+			},
+			&cli.IntFlag{
+				Name:  "blocklist-distance",
+				Value: 8,
+				Usage: "Differing bits at which a dhash blocklist entry still matches an image",
+			},
+			&cli.StringFlag{
+				Name:  "websub-hub",
+				Usage: "WebSub hub pinged when memes enter the pool and advertised in /feed.xml, e.g. https://pubsubhubbub.appspot.com/",
+			},
+			&cli.StringFlag{
+				Name:  "websub-topic",
+				Usage: "Public URL of /feed.xml published to --websub-hub (defaults to the feed under the tunnel URL)",
+			},
+			&cli.StringFlag{
+				Name:  "kafka-brokers",
+				Usage: "Comma-separated Kafka brokers as host:port to mirror every broadcast event to, keyed by meme ID",
+			},
+			&cli.StringFlag{
+				Name:  "kafka-topic",
+				Value: "memes",
+				Usage: "Kafka topic receiving the events mirrored by --kafka-brokers",
+			},
+			&cli.StringFlag{
+				Name:  "s3-bucket",
+				Usage: "S3-compatible bucket to archive meme images and daily pool manifests to",
+			},
+			&cli.StringFlag{
+				Name:  "s3-prefix",
+				Usage: "Prefix for archived object keys, e.g. memes/",
+			},
+			&cli.StringFlag{
+				Name:  "s3-endpoint",
+				Usage: "Object storage URL for --s3-bucket, e.g. http://localhost:9000 for MinIO (defaults to AWS S3 in --s3-region)",
+			},
+			&cli.StringFlag{
+				Name:  "s3-region",
+				Value: "us-east-1",
+				Usage: "Region requests to --s3-endpoint are signed for",
+			},
+			&cli.StringFlag{
+				Name:    "s3-access-key",
+				Usage:   "Access key ID for --s3-bucket",
+				EnvVars: []string{"AWS_ACCESS_KEY_ID"},
+			},
+			&cli.StringFlag{
+				Name:    "s3-secret-key",
+				Usage:   "Secret access key for --s3-bucket",
+				EnvVars: []string{"AWS_SECRET_ACCESS_KEY"},
+			},
+			&cli.DurationFlag{
+				Name:  "archive-interval",
+				Value: time.Hour,
+				Usage: "How often new memes are archived to --s3-bucket",
+			},
+			&cli.StringSliceFlag{
+				Name:  "cohort",
+				Usage: "Experiment cohort as name:weight[:ranker[:interval]], may be repeated; clients are split between cohorts by their client token",
+			},
+			&cli.StringFlag{
+				Name:  "max-upload-size",
+				Value: "5MB",
+				Usage: "Largest image accepted by POST /api/memes",
+			},
+			&cli.StringFlag{
+				Name:  "payload-template",
+				Usage: "Go text/template rendering each meme event's data, or @file to read it from a file",
+			},
+			&cli.StringSliceFlag{
+				Name:  "redact-header",
+				Usage: "Request header whose values are masked in connection logs, on top of Authorization, Proxy-Authorization, Cookie, X-Api-Key and X-Auth-Token (repeatable)",
+			},
 			&cli.BoolFlag{
 				Name:  "tunnel",
 				Usage: "Enable Ngrok tunneling",
 			},
+			&cli.StringSliceFlag{
+				Name:  "allowed-host",
+				Usage: "Allowed Host header (exact or *.example.com), may be repeated; all hosts allowed when unset",
+			},
+			&cli.StringSliceFlag{
+				Name:  "allowed-origin",
+				Usage: "Allowed Origin (https://app.example.com or *.example.com), may be repeated; all origins allowed when unset",
+			},
+			&cli.StringFlag{
+				Name:    "admin-token",
+				Usage:   "Bearer token required by sensitive admin endpoints such as /debug/state",
+				EnvVars: []string{"ADMIN_TOKEN"},
+			},
+			&cli.StringSliceFlag{
+				Name:  "api-key",
+				Usage: "API key as name:key[:max_streams[:events_per_day[:role]]] with role viewer, debugger or admin, may be repeated; routes require a key when set",
+			},
+			&cli.StringFlag{
+				Name:  "oidc-issuer",
+				Usage: "OpenID Connect issuer URL; enables login for /debug and /admin routes",
+			},
+			&cli.StringFlag{
+				Name:    "oidc-client-id",
+				Usage:   "OpenID Connect client ID",
+				EnvVars: []string{"OIDC_CLIENT_ID"},
+			},
+			&cli.StringFlag{
+				Name:    "oidc-client-secret",
+				Usage:   "OpenID Connect client secret",
+				EnvVars: []string{"OIDC_CLIENT_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:  "oidc-redirect-url",
+				Usage: "OpenID Connect callback URL (defaults to /auth/callback on the requested host)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "oidc-allowed-email",
+				Usage: "Verified email allowed to log in, may be repeated; required unless --oidc-role-claim is set",
+			},
+			&cli.StringFlag{
+				Name:  "oidc-role-claim",
+				Usage: "ID token claim holding the user's role (viewer, debugger or admin); users without it are viewers. Without it, allowed emails are admins",
+			},
+			&cli.StringFlag{
+				Name:    "session-secret",
+				Usage:   "Secret signing login session cookies (random per run when unset, logging everyone out on restart)",
+				EnvVars: []string{"SESSION_SECRET"},
+			},
+			&cli.StringSliceFlag{
+				Name:  "trusted-proxies",
+				Usage: "CIDRs or IPs of reverse proxies whose X-Forwarded-For/Forwarded headers are trusted, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "TLS certificate file; serves HTTPS when set together with --tls-key",
+			},
+			&cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "TLS private key file",
+			},
+			&cli.BoolFlag{
+				Name:  "http3",
+				Usage: "Experimental: also serve HTTP/3 over QUIC on each listen address, with its route set (requires --tls-cert and --tls-key)",
+			},
+			&cli.BoolFlag{
+				Name:  "compress",
+				Value: true,
+				Usage: "Compress responses with brotli or gzip when the client accepts it",
+			},
+			&cli.BoolFlag{
+				Name:  "compress-sse",
+				Usage: "Also compress the SSE stream (flushed after every event)",
+			},
+			&cli.BoolFlag{
+				Name:  "security-headers",
+				Value: true,
+				Usage: "Set Content-Security-Policy, X-Content-Type-Options, Referrer-Policy and X-Frame-Options",
+			},
+			&cli.StringSliceFlag{
+				Name:  "csp-image-host",
+				Value: cli.NewStringSlice("https://i.redd.it", "https://preview.redd.it", "https://v.redd.it", "https://i.imgur.com"),
+				Usage: "Image host allowed by the Content-Security-Policy, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "frame-options",
+				Value: "DENY",
+				Usage: "X-Frame-Options for the client page: DENY, SAMEORIGIN, or empty to allow embedding",
+			},
+			&cli.StringFlag{
+				Name:  "referrer-policy",
+				Value: "strict-origin-when-cross-origin",
+				Usage: "Referrer-Policy for the client page",
+			},
+			&cli.BoolFlag{
+				Name:  "image-proxy",
+				Usage: "Serve meme images through signed, expiring /img URLs (proxy_url in meme events)",
+			},
+			&cli.StringFlag{
+				Name:    "proxy-secret",
+				Usage:   "Secret signing image proxy URLs (random per run when unset)",
+				EnvVars: []string{"PROXY_SECRET"},
+			},
+			&cli.DurationFlag{
+				Name:  "proxy-url-ttl",
+				Usage: "How long signed image proxy URLs stay valid",
+				Value: time.Hour,
+			},
+			&cli.StringFlag{
+				Name:  "watermark-text",
+				Usage: "Text drawn over PNG and JPEG images served by --image-proxy, e.g. @yourname",
+			},
+			&cli.StringFlag{
+				Name:  "watermark-image",
+				Usage: "PNG or JPEG logo drawn over images served by --image-proxy, instead of --watermark-text",
+			},
+			&cli.StringFlag{
+				Name:  "watermark-corner",
+				Value: "bottom-right",
+				Usage: "Corner of the watermark: top-left, top-right, bottom-left or bottom-right",
+			},
+			&cli.Float64Flag{
+				Name:  "watermark-opacity",
+				Value: 0.6,
+				Usage: "Opacity of the watermark, above 0 and up to 1",
+			},
+			&cli.BoolFlag{
+				Name:  "strip-metadata",
+				Value: true,
+				Usage: "Remove EXIF and other metadata from images served by --image-proxy and from uploads",
+			},
+			&cli.StringFlag{
+				Name:    "redis-url",
+				Usage:   "Redis URL for a shared cache (in-memory when unset)",
+				EnvVars: []string{"REDIS_URL"},
+			},
+			&cli.DurationFlag{
+				Name:  "http-timeout",
+				Value: 10 * time.Second,
+				Usage: "Overall timeout for upstream requests",
+			},
+			&cli.DurationFlag{
+				Name:  "http-dial-timeout",
+				Value: 5 * time.Second,
+				Usage: "Timeout for establishing upstream connections",
+			},
+			&cli.DurationFlag{
+				Name:  "http-tls-timeout",
+				Value: 5 * time.Second,
+				Usage: "Timeout for upstream TLS handshakes",
+			},
+			&cli.DurationFlag{
+				Name:  "http-response-timeout",
+				Value: 10 * time.Second,
+				Usage: "Timeout waiting for upstream response headers",
+			},
+			&cli.DurationFlag{
+				Name:  "http-idle-timeout",
+				Value: 90 * time.Second,
+				Usage: "How long idle upstream keep-alive connections are kept",
+			},
+			&cli.BoolFlag{
+				Name:  "http2",
+				Value: true,
+				Usage: "Negotiate HTTP/2 for upstream requests",
+			},
+			&cli.Int64Flag{
+				Name:  "seed",
+				Usage: "Seed meme selection for reproducible streams",
+			},
+			&cli.StringSliceFlag{
+				Name:  "provider-rate",
+				Usage: "Request limit for a provider as provider=rps[:burst], e.g. reddit=0.5:4 (the default), may be repeated",
+			},
+			&cli.BoolFlag{
+				Name:  "web-push",
+				Usage: "Let the client page subscribe to Web Push notifications for --push-event memes",
+			},
+			&cli.StringFlag{
+				Name:    "vapid-private-key",
+				Usage:   "Base64url VAPID private key; when empty, one is generated and saved to <push-store>.vapid-key, or kept for this run only without --push-store",
+				EnvVars: []string{"VAPID_PRIVATE_KEY"},
+			},
+			&cli.StringFlag{
+				Name:  "vapid-subject",
+				Value: "mailto:admin@localhost",
+				Usage: "Contact URL (mailto: or https:) sent to push services",
+			},
+			&cli.StringFlag{
+				Name:  "push-store",
+				Usage: "File to persist push subscriptions in, default memory only",
+			},
+			&cli.StringSliceFlag{
+				Name:  "push-event",
+				Value: cli.NewStringSlice("trending"),
+				Usage: "Event pushed as a notification: trending or motd, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "journal",
+				Usage: "File to journal every broadcast event to, enabling replay via /api/events",
+			},
+			&cli.IntFlag{
+				Name:  "journal-size",
+				Value: 10000,
+				Usage: "Number of journaled events to retain",
+			},
+			&cli.StringFlag{
+				Name:  "media",
+				Usage: "Comma-separated media types to stream (image, video, gif), default all",
+			},
+			&cli.StringFlag{
+				Name:  "max-content-size",
+				Usage: "Skip memes whose image or video is larger than this, e.g. 5MB (sizes come from HEAD probes), default unlimited",
+			},
+			&cli.BoolFlag{
+				Name:  "gif-only",
+				Usage: "Only stream animated GIFs, shorthand for --media gif",
+			},
+			&cli.StringFlag{
+				Name:  "ranker",
+				Value: "random",
+				Usage: "Meme selection strategy: random (uniform, skipping recently sent) or composite (weighted by score and freshness, penalising recently sent)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "reddit-mirror",
+				Usage: "Reddit-compatible host to fall back to when reddit.com fails, tried in order, may be repeated (default https://old.reddit.com)",
+			},
+			&cli.StringFlag{
+				Name:  "max-response-size",
+				Value: "8MB",
+				Usage: "Largest upstream listing response read; bigger responses fail the fetch. 0 for no limit",
+			},
+			&cli.IntFlag{
+				Name:  "max-pool-size",
+				Usage: "Most memes kept in the pool, evicting by --pool-rotation beyond it; 0 for no limit",
+			},
+			&cli.StringFlag{
+				Name:  "pool-rotation",
+				Value: string(memeservice.RotateFIFO),
+				Usage: "Memes evicted from a full pool: fifo (longest in the pool first) or lowest-score",
+			},
+			&cli.StringFlag{
+				Name:  "motd-timezone",
+				Value: "UTC",
+				Usage: "IANA timezone whose midnight starts a new meme of the day, e.g. Europe/Berlin",
+			},
+			&cli.StringFlag{
+				Name:  "quiet-hours",
+				Usage: "Daily window such as 22:00-07:00 during which streams pause (or slow) and upstream refreshes stop",
+			},
+			&cli.StringFlag{
+				Name:  "quiet-timezone",
+				Value: "UTC",
+				Usage: "IANA timezone of --quiet-hours",
+			},
+			&cli.DurationFlag{
+				Name:  "quiet-slow-interval",
+				Usage: "Slow streams to one meme per interval during quiet hours instead of pausing them",
+			},
+			&cli.DurationFlag{
+				Name:  "sse-retry",
+				Value: server.DefaultSSERetry,
+				Usage: "Reconnection delay advised to SSE clients in the retry field, lengthened under load, for saturated keys and during quiet hours; 0 leaves it to the browser",
+			},
+			&cli.DurationFlag{
+				Name:  "sse-retry-max",
+				Value: server.DefaultSSERetryMax,
+				Usage: "Longest reconnection delay advised to SSE clients",
+			},
+			&cli.IntFlag{
+				Name:  "sse-retry-load",
+				Value: server.DefaultSSERetryLoad,
+				Usage: "Active streams above which the advised reconnection delay grows by --sse-retry per multiple; 0 ignores load",
+			},
+			&cli.IntFlag{
+				Name:  "recent-size",
+				Value: 10,
+				Usage: "Number of recently sent memes to avoid repeating per subscriber",
+			},
+			&cli.DurationFlag{
+				Name:  "recent-window",
+				Value: 30 * time.Minute,
+				Usage: "How long recently sent memes are suppressed",
+			},
+			&cli.StringSliceFlag{
+				Name:  "source",
+				Usage: "Meme source as subreddit[:sort[:time[:ttl]]], may be repeated (default memes:hot unless another provider is configured)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "lemmy",
+				Usage: "Lemmy community to pull memes from as https://instance/c/community[?sort=Hot&ttl=5m], may be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "mastodon",
+				Usage: "Mastodon hashtag timeline to pull media posts from as https://instance/tags/hashtag[?ttl=5m], may be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "bluesky",
+				Usage: "Bluesky custom feed or profile to pull image posts from, as linked from bsky.app or an at:// feed URI, may be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "imgur",
+				Usage: "Imgur gallery to pull memes from as section[:sort[:window[:ttl]]], section being hot, top, user or r/<subreddit>, may be repeated; needs --imgur-client-id",
+			},
+			&cli.StringFlag{
+				Name:    "imgur-client-id",
+				Usage:   "Imgur API client ID for --imgur",
+				EnvVars: []string{"IMGUR_CLIENT_ID"},
+			},
+			&cli.StringFlag{
+				Name:  "imgur-api-url",
+				Value: memeservice.DefaultImgurAPIURL,
+				Usage: "Imgur API base URL for --imgur",
+			},
+			&cli.StringSliceFlag{
+				Name:  "giphy",
+				Usage: "Giphy GIFs to pull as trending[:ttl] or search:<query>[:ttl], may be repeated; needs --giphy-api-key",
+			},
+			&cli.StringFlag{
+				Name:    "giphy-api-key",
+				Usage:   "Giphy API key for --giphy",
+				EnvVars: []string{"GIPHY_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:  "giphy-api-url",
+				Value: memeservice.DefaultGiphyAPIURL,
+				Usage: "Giphy API base URL for --giphy",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tenor",
+				Usage: "Tenor GIFs to pull as trending[:ttl] (Tenor's featured list) or search:<query>[:ttl], may be repeated; needs --tenor-api-key",
+			},
+			&cli.StringFlag{
+				Name:    "tenor-api-key",
+				Usage:   "Tenor API key for --tenor",
+				EnvVars: []string{"TENOR_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:  "tenor-api-url",
+				Value: memeservice.DefaultTenorAPIURL,
+				Usage: "Tenor API base URL for --tenor",
+			},
+			&cli.StringFlag{
+				Name:  "bluesky-appview",
+				Value: memeservice.DefaultBlueskyAppView,
+				Usage: "AT Protocol AppView that Bluesky feeds are read from",
+			},
 		},
 		Action: func(ctx *cli.Context) error {
-			// Seed random number generator
-			rand.Seed(time.Now().UnixNano())
+			// Persisted logs share one rotation policy
+			maxLogSize, err := memeservice.ParseByteSize(ctx.String("log-max-size"))
+			if err != nil {
+				return fmt.Errorf("invalid --log-max-size: %v", err)
+			}
+			rotation := logfile.Options{
+				MaxSize:  maxLogSize,
+				MaxAge:   ctx.Duration("log-max-age"),
+				Keep:     ctx.Int("log-keep"),
+				Compress: ctx.Bool("log-compress"),
+			}
+			if path := ctx.String("log-file"); path != "" {
+				logFile, err := logfile.Open(path, rotation)
+				if err != nil {
+					return err
+				}
+				defer logFile.Close()
+				log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+			}
+
+			// Select cache backend
+			var responseCache cache.Cache = cache.NewMemoryCache()
+			if redisURL := ctx.String("redis-url"); redisURL != "" {
+				redisCache, err := cache.NewRedisCache(redisURL)
+				if err != nil {
+					return err
+				}
+				defer redisCache.Close()
+				responseCache = redisCache
+			}
+
+			// Parse meme sources
+			var sources []memeservice.Source
+			for _, spec := range ctx.StringSlice("source") {
+				source, err := memeservice.ParseSource(spec)
+				if err != nil {
+					return err
+				}
+				sources = append(sources, source)
+			}
+			var lemmySources []memeservice.LemmySource
+			for _, spec := range ctx.StringSlice("lemmy") {
+				source, err := memeservice.ParseLemmySource(spec)
+				if err != nil {
+					return err
+				}
+				lemmySources = append(lemmySources, source)
+			}
+			var mastodonSources []memeservice.MastodonSource
+			for _, spec := range ctx.StringSlice("mastodon") {
+				source, err := memeservice.ParseMastodonSource(spec)
+				if err != nil {
+					return err
+				}
+				mastodonSources = append(mastodonSources, source)
+			}
+			var blueskySources []memeservice.BlueskySource
+			for _, spec := range ctx.StringSlice("bluesky") {
+				source, err := memeservice.ParseBlueskySource(spec)
+				if err != nil {
+					return err
+				}
+				blueskySources = append(blueskySources, source)
+			}
+			var imgurSources []memeservice.ImgurSource
+			for _, spec := range ctx.StringSlice("imgur") {
+				source, err := memeservice.ParseImgurSource(spec)
+				if err != nil {
+					return err
+				}
+				imgurSources = append(imgurSources, source)
+			}
+			if len(imgurSources) > 0 && ctx.String("imgur-client-id") == "" {
+				return fmt.Errorf("--imgur requires --imgur-client-id or IMGUR_CLIENT_ID")
+			}
+			gifSources := make(map[string][]memeservice.GIFSource)
+			for _, provider := range []string{"giphy", "tenor"} {
+				for _, spec := range ctx.StringSlice(provider) {
+					source, err := memeservice.ParseGIFSource(spec)
+					if err != nil {
+						return err
+					}
+					gifSources[provider] = append(gifSources[provider], source)
+				}
+				if len(gifSources[provider]) > 0 && ctx.String(provider+"-api-key") == "" {
+					return fmt.Errorf("--%s requires --%s-api-key or %s_API_KEY", provider, provider, strings.ToUpper(provider))
+				}
+			}
+
+			// Shared outbound HTTP client
+			clientConfig := memeservice.DefaultClientConfig()
+			clientConfig.RequestTimeout = ctx.Duration("http-timeout")
+			clientConfig.DialTimeout = ctx.Duration("http-dial-timeout")
+			clientConfig.TLSHandshakeTimeout = ctx.Duration("http-tls-timeout")
+			clientConfig.ResponseHeaderTimeout = ctx.Duration("http-response-timeout")
+			clientConfig.IdleConnTimeout = ctx.Duration("http-idle-timeout")
+			clientConfig.HTTP2 = ctx.Bool("http2")
+			client := memeservice.NewHTTPClient(clientConfig)
+
+			// Meme media URLs come from remote parties, so size probes, screening
+			// and archiving only fetch them from public addresses, except the
+			// mock upstream's own images
+			mediaConfig := clientConfig
+			mediaConfig.PublicOnly = !ctx.Bool("mock-upstream")
+			mediaClient := memeservice.NewHTTPClient(mediaConfig)
+
+			// Serve assets from disk in dev mode so edits show up without rebuilding
+			var assets fs.FS = web.Files
+			if ctx.Bool("dev") {
+				assets = os.DirFS("web")
+			}
+			if dir := ctx.String("templates-dir"); dir != "" {
+				assets = web.Overlay(dir, assets)
+			}
 
 			// Create server
-			srv := server.NewServer(content)
+			memeService := memeservice.NewService(responseCache, sources, client)
+			memeService.SetMediaClient(mediaClient)
 
-			// Setup routes
-			mux := srv.SetupRoutes()
+			ranker, err := memeservice.ParseRanker(ctx.String("ranker"))
+			if err != nil {
+				return err
+			}
+			memeService.SetRanker(ranker)
 
-			// CORS middleware
-			handler := cors.Default().Handler(mux)
+			poolRotation, err := memeservice.ParseRotation(ctx.String("pool-rotation"))
+			if err != nil {
+				return err
+			}
+			memeService.SetLemmySources(lemmySources)
+			memeService.SetMastodonSources(mastodonSources)
+			memeService.SetBlueskySources(ctx.String("bluesky-appview"), blueskySources)
+			memeService.SetImgurSources(ctx.String("imgur-api-url"), ctx.String("imgur-client-id"), imgurSources)
+			memeService.SetGiphySources(ctx.String("giphy-api-url"), ctx.String("giphy-api-key"), gifSources["giphy"])
+			memeService.SetTenorSources(ctx.String("tenor-api-url"), ctx.String("tenor-api-key"), gifSources["tenor"])
+			memeService.SetPoolLimit(ctx.Int("max-pool-size"), poolRotation)
+			if path := ctx.String("meme-dataset"); path != "" {
+				dataset, err := memeservice.LoadDataset(path)
+				if err != nil {
+					return err
+				}
+				memeService.SetDataset(dataset)
+				log.Printf("Loaded %d meme formats from %s", dataset.Len(), path)
+			}
 
-			// Port configuration
-			port := fmt.Sprintf(":%d", ctx.Int("port"))
+			maxResponse, err := memeservice.ParseByteSize(ctx.String("max-response-size"))
+			if err != nil {
+				return fmt.Errorf("invalid --max-response-size: %v", err)
+			}
+			memeService.SetMaxResponseSize(maxResponse)
 
-			// Optional Ngrok tunneling
+			// Per-provider token buckets on top of the defaults
+			rates := make(map[string]memeservice.ProviderRate)
+			for _, spec := range ctx.StringSlice("provider-rate") {
+				provider, rate, err := memeservice.ParseProviderRate(spec)
+				if err != nil {
+					return err
+				}
+				rates[provider] = rate
+			}
+			memeService.SetProviderRates(rates)
+			srv := server.NewServer(assets, server.WithMemeService(memeService))
+
+			// Hermetic mode: fetch from a local fake Reddit
+			imageHosts := ctx.StringSlice("csp-image-host")
+			if ctx.Bool("mock-upstream") {
+				mock, err := redditmock.Start(redditmock.Options{
+					Latency:   ctx.Duration("mock-latency"),
+					ErrorRate: ctx.Float64("mock-error-rate"),
+					Fixture:   ctx.String("mock-fixture"),
+					Seed:      ctx.Int64("seed"),
+				})
+				if err != nil {
+					return err
+				}
+				defer mock.Close()
+
+				memeService.SetBaseURL(mock.URL())
+				imageHosts = append(imageHosts, mock.URL())
+				log.Printf("Mock upstream serving on %s", mock.URL())
+			}
+
+			// Fall back to mirrors when the Reddit host fails; the mock has none unless given
+			mirrors := memeservice.DefaultMirrors
+			if ctx.IsSet("reddit-mirror") {
+				mirrors = ctx.StringSlice("reddit-mirror")
+			} else if ctx.Bool("mock-upstream") {
+				mirrors = nil
+			}
+			if err := memeService.SetMirrors(mirrors); err != nil {
+				return err
+			}
+
+			if ctx.Bool("dev") {
+				if err := srv.WatchAssets(ctx.Context, "web"); err != nil {
+					return err
+				}
+				log.Printf("Dev mode: serving web/ from disk with live reload")
+
+				if dir := ctx.String("templates-dir"); dir != "" {
+					if err := srv.WatchAssets(ctx.Context, dir); err != nil {
+						return err
+					}
+				}
+			}
+
+			// Deterministic stream mode
+			if ctx.IsSet("seed") {
+				srv.SetSeed(ctx.Int64("seed"))
+			}
+
+			// Signed image proxy
+			if ctx.Bool("image-proxy") {
+				// Submitted and custom-source URLs are proxied too, so only public
+				// addresses are fetched, except the mock upstream's own images
+				proxyConfig := clientConfig
+				proxyConfig.PublicOnly = !ctx.Bool("mock-upstream")
+				proxy, err := imageproxy.New([]byte(ctx.String("proxy-secret")), ctx.Duration("proxy-url-ttl"), memeservice.NewHTTPClient(proxyConfig))
+				if err != nil {
+					return err
+				}
+				proxy.SetStripMetadata(ctx.Bool("strip-metadata"))
+				proxy.SetCache(responseCache)
+				srv.SetImageProxy(proxy)
+
+				if ctx.String("watermark-text") != "" || ctx.String("watermark-image") != "" {
+					wm := &imageproxy.Watermark{
+						Text:    ctx.String("watermark-text"),
+						Corner:  ctx.String("watermark-corner"),
+						Opacity: ctx.Float64("watermark-opacity"),
+					}
+					if path := ctx.String("watermark-image"); path != "" {
+						if wm.Image, err = imageproxy.LoadWatermarkImage(path); err != nil {
+							return err
+						}
+					}
+					if err := proxy.SetWatermark(wm); err != nil {
+						return err
+					}
+				}
+			} else if ctx.String("watermark-text") != "" || ctx.String("watermark-image") != "" {
+				return fmt.Errorf("--watermark-text and --watermark-image need --image-proxy")
+			}
+
+			// Mount under a sub-path when behind a shared reverse proxy
+			srv.SetBasePath(ctx.String("base-path"))
+			if raw := ctx.String("api-sunset"); raw != "" {
+				sunset, err := time.Parse(time.DateOnly, raw)
+				if err != nil {
+					return fmt.Errorf("invalid --api-sunset: %v", err)
+				}
+				srv.SetAPISunset(sunset)
+			}
+			if ctx.Bool("disable-debug") {
+				srv.SetDebugPath("")
+			} else {
+				srv.SetDebugPath(ctx.String("debug-path"))
+			}
+			srv.RedactHeaders(ctx.StringSlice("redact-header")...)
+			if path := ctx.String("connection-log"); path != "" {
+				connectionLog, err := logfile.Open(path, rotation)
+				if err != nil {
+					return err
+				}
+				defer connectionLog.Close()
+				srv.ArchiveConnections(connectionLog)
+			}
+			captureBytes, err := memeservice.ParseByteSize(ctx.String("capture-bytes"))
+			if err != nil {
+				return fmt.Errorf("invalid --capture-bytes: %v", err)
+			}
+			srv.CaptureFrames(ctx.Int("capture-frames"), captureBytes)
+			if err := srv.SetDebugSampleRate(ctx.Float64("debug-sample-rate")); err != nil {
+				return fmt.Errorf("invalid --debug-sample-rate: %v", err)
+			}
+			if text := ctx.String("payload-template"); text != "" {
+				if path, ok := strings.CutPrefix(text, "@"); ok {
+					contents, err := os.ReadFile(path)
+					if err != nil {
+						return fmt.Errorf("failed to read payload template: %v", err)
+					}
+					text = string(contents)
+				}
+				tmpl, err := server.ParsePayloadTemplate(text)
+				if err != nil {
+					return err
+				}
+				srv.SetPayloadTemplate(tmpl)
+			}
+			if dir := ctx.String("submissions-dir"); dir != "" {
+				store, err := submissions.Open(dir)
+				if err != nil {
+					return err
+				}
+				store.SetStripMetadata(ctx.Bool("strip-metadata"))
+				maxUpload, err := memeservice.ParseByteSize(ctx.String("max-upload-size"))
+				if err != nil {
+					return fmt.Errorf("invalid --max-upload-size: %v", err)
+				}
+				srv.SetSubmissions(store, maxUpload)
+			}
+			if ctx.Bool("captions") || ctx.String("caption-templates") != "" {
+				srv.SetCaptions(ctx.String("caption-templates"))
+			}
+			if dir := ctx.String("local-dir"); dir != "" {
+				if err := srv.SetLocalDir(dir); err != nil {
+					return err
+				}
+				if err := memeService.WatchLocalDir(ctx.Context); err != nil {
+					return err
+				}
+				log.Printf("Streaming memes from %s", dir)
+			}
+			if path := ctx.String("blocklist"); path != "" {
+				bl, err := blocklist.Load(path, ctx.Int("blocklist-distance"))
+				if err != nil {
+					return err
+				}
+				if err := bl.Watch(ctx.Context); err != nil {
+					return err
+				}
+				srv.SetBlocklist(bl)
+				log.Printf("Loaded %d blocklist hashes from %s", bl.Len(), path)
+			}
+			if hub := ctx.String("websub-hub"); hub != "" {
+				if err := srv.SetWebSub(hub, ctx.String("websub-topic")); err != nil {
+					return err
+				}
+			} else if ctx.String("websub-topic") != "" {
+				return fmt.Errorf("--websub-topic needs --websub-hub")
+			}
+			srv.SetLogRetention(ctx.Duration("log-retention"))
+			srv.RunLogRetention(ctx.Context)
+
+			// Diagnostics
+			srv.SetAdminToken(ctx.String("admin-token"))
+			srv.DumpStateOnSignal(ctx.Context)
+
+			// Meme of the day, announced at each midnight
+			motdLocation, err := time.LoadLocation(ctx.String("motd-timezone"))
+			if err != nil {
+				return fmt.Errorf("invalid --motd-timezone: %v", err)
+			}
+			srv.SetMotdLocation(motdLocation)
+			srv.BroadcastMotd(ctx.Context)
+
+			// Quiet hours
+			if spec := ctx.String("quiet-hours"); spec != "" {
+				quiet, err := server.ParseQuietHours(spec)
+				if err != nil {
+					return err
+				}
+				if quiet.Location, err = time.LoadLocation(ctx.String("quiet-timezone")); err != nil {
+					return fmt.Errorf("invalid --quiet-timezone: %v", err)
+				}
+				if interval := ctx.Duration("quiet-slow-interval"); interval > 0 {
+					quiet.Slow, quiet.SlowInterval = true, interval
+				}
+				srv.SetQuietHours(quiet)
+				srv.RunQuietHours(ctx.Context)
+			}
+			if err := srv.SetSSERetry(ctx.Duration("sse-retry"), ctx.Duration("sse-retry-max"), ctx.Int("sse-retry-load")); err != nil {
+				return fmt.Errorf("invalid --sse-retry settings: %v", err)
+			}
+
+			// Server-wide media and size filter, narrowed further by ?media= and ?max_size=
+			media, err := memeservice.ParseMediaTypes(ctx.String("media"))
+			if err != nil {
+				return fmt.Errorf("invalid --media: %v", err)
+			}
+			if ctx.Bool("gif-only") {
+				if len(media) > 0 {
+					return fmt.Errorf("--gif-only cannot be combined with --media")
+				}
+				media = []string{memeservice.MediaGIF}
+			}
+			maxBytes, err := memeservice.ParseByteSize(ctx.String("max-content-size"))
+			if err != nil {
+				return fmt.Errorf("invalid --max-content-size: %v", err)
+			}
+			srv.SetDefaultFilter(memeservice.Filter{MediaTypes: media, MaxBytes: maxBytes})
+
+			// Replayable journal of broadcast events
+			if path := ctx.String("journal"); path != "" {
+				j, err := journal.Open(path, ctx.Int("journal-size"))
+				if err != nil {
+					return err
+				}
+				defer j.Close()
+				srv.SetJournal(j)
+			}
+
+			// Durable mirror of broadcast events for data pipelines
+			if raw := ctx.String("kafka-brokers"); raw != "" {
+				brokers, err := kafkasink.ParseBrokers(raw)
+				if err != nil {
+					return fmt.Errorf("invalid --kafka-brokers: %v", err)
+				}
+				sink, err := kafkasink.New(brokers, ctx.String("kafka-topic"))
+				if err != nil {
+					return err
+				}
+				defer sink.Close()
+				srv.SetKafkaSink(sink)
+				log.Printf("Mirroring broadcast events to Kafka topic %s", ctx.String("kafka-topic"))
+			}
+
+			// Long-term archive of the pool in object storage
+			if bucket := ctx.String("s3-bucket"); bucket != "" {
+				endpoint := ctx.String("s3-endpoint")
+				if endpoint == "" {
+					endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", ctx.String("s3-region"))
+				}
+				if ctx.Duration("archive-interval") <= 0 {
+					return fmt.Errorf("--archive-interval must be positive")
+				}
+				store, err := s3archive.NewClient(s3archive.Config{
+					Endpoint:  endpoint,
+					Region:    ctx.String("s3-region"),
+					Bucket:    bucket,
+					Prefix:    ctx.String("s3-prefix"),
+					AccessKey: ctx.String("s3-access-key"),
+					SecretKey: ctx.String("s3-secret-key"),
+				}, &http.Client{Timeout: time.Minute})
+				if err != nil {
+					return fmt.Errorf("invalid --s3-bucket settings: %v", err)
+				}
+				srv.SetArchiver(s3archive.NewArchiver(store, mediaClient), ctx.Duration("archive-interval"))
+				srv.RunArchiver(ctx.Context)
+			}
+
+			// Web Push notifications
+			if ctx.Bool("web-push") {
+				var keys *webpush.Keys
+				switch store := ctx.String("push-store"); {
+				case ctx.String("vapid-private-key") != "":
+					keys, err = webpush.ParseKeys(ctx.String("vapid-private-key"))
+				case store != "":
+					// Kept next to the subscriptions, which are useless without it
+					keys, err = webpush.LoadOrGenerateKeys(store + ".vapid-key")
+				default:
+					keys, err = webpush.GenerateKeys()
+				}
+				if err != nil {
+					return err
+				}
+				log.Printf("Web Push enabled with VAPID public key %s", keys.PublicKey())
+
+				events := ctx.StringSlice("push-event")
+				for _, event := range events {
+					if !slices.Contains(server.PushEvents, event) {
+						return fmt.Errorf("invalid --push-event %q, expected one of %s", event, strings.Join(server.PushEvents, ", "))
+					}
+				}
+
+				// Endpoints come from browsers, so pushes only go to public addresses
+				pusher, err := webpush.New(keys, ctx.String("vapid-subject"), ctx.String("push-store"), netguard.NewClient(30*time.Second))
+				if err != nil {
+					return err
+				}
+				defer pusher.Close()
+				srv.SetPusher(pusher, events)
+			}
+
+			// API keys with per-key quotas
+			var keys []auth.APIKey
+			for _, spec := range ctx.StringSlice("api-key") {
+				key, err := auth.ParseAPIKey(spec)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, key)
+			}
+			srv.SetAPIKeys(keys)
+
+			// A/B experiment cohorts
+			var cohorts []server.Cohort
+			for _, spec := range ctx.StringSlice("cohort") {
+				cohort, err := server.ParseCohort(spec)
+				if err != nil {
+					return err
+				}
+				cohorts = append(cohorts, cohort)
+			}
+			if err := srv.SetCohorts(cohorts); err != nil {
+				return err
+			}
+
+			// Identity provider login for the admin surface
+			if issuer := ctx.String("oidc-issuer"); issuer != "" {
+				oidc, err := auth.NewOIDC(ctx.Context, auth.OIDCOptions{
+					Issuer:        issuer,
+					ClientID:      ctx.String("oidc-client-id"),
+					ClientSecret:  ctx.String("oidc-client-secret"),
+					RedirectURL:   ctx.String("oidc-redirect-url"),
+					AllowedEmails: ctx.StringSlice("oidc-allowed-email"),
+					RoleClaim:     ctx.String("oidc-role-claim"),
+					SessionSecret: []byte(ctx.String("session-secret")),
+				})
+				if err != nil {
+					return err
+				}
+				srv.SetOIDC(oidc)
+			}
+
+			// Recently-sent suppression
+			srv.SetRecentWindow(ctx.Int("recent-size"), ctx.Duration("recent-window"))
+
+			// Optional config file, reloadable on SIGHUP and POST /admin/reload
+			if configPath := ctx.String("config"); configPath != "" {
+				reload := func() error {
+					cfg, err := appconfig.Load(configPath)
+					if err != nil {
+						return err
+					}
+					if err := srv.ApplyConfig(cfg); err != nil {
+						return err
+					}
+					log.Printf("Loaded config from %s", configPath)
+					return nil
+				}
+				if err := reload(); err != nil {
+					return err
+				}
+				srv.SetReloader(reload)
+
+				hup := make(chan os.Signal, 1)
+				signal.Notify(hup, syscall.SIGHUP)
+				go func() {
+					for {
+						select {
+						case <-ctx.Context.Done():
+							signal.Stop(hup)
+							return
+						case <-hup:
+							err := reload()
+							srv.RecordAudit("signal:SIGHUP", "config.reload", map[string]string{"path": configPath}, err)
+							if err != nil {
+								log.Printf("Config reload failed: %v", err)
+							}
+						}
+					}
+				}()
+			}
+
+			// Client IP resolution behind trusted proxies
+			resolver, err := clientip.NewResolver(ctx.StringSlice("trusted-proxies"))
+			if err != nil {
+				return err
+			}
+
+			allowedHosts := ctx.StringSlice("allowed-host")
+			allowedOrigins := ctx.StringSlice("allowed-origin")
+
+			// Optional Ngrok tunneling, opened before host validation is set up
+			// so the tunnel's own host can be allowed
+			var tun ngrok.Tunnel
 			if ctx.Bool("tunnel") {
-				tun, err := ngrok.Listen(ctx.Context,
+				if ctx.Bool("http3") {
+					return fmt.Errorf("--http3 cannot be used with --tunnel")
+				}
+
+				tun, err = ngrok.Listen(ctx.Context,
 					config.HTTPEndpoint(),
 					ngrok.WithAuthtokenFromEnv(),
 				)
@@ -69,17 +1184,384 @@ func main() {
 				}
 
 				log.Printf("Tunnel available at: %s", tun.URL())
-				return http.Serve(tun, handler)
+				srv.SetTunnelURL(tun.URL())
+
+				// The tunnel's own host is always allowed once hosts are restricted
+				if tunnelURL, err := url.Parse(tun.URL()); err == nil && len(allowedHosts) > 0 {
+					allowedHosts = append(allowedHosts, tunnelURL.Hostname())
+				}
+			}
+
+			// Host and origin validation runs first, after resolving the client IP
+			srv.Use(middleware.ValidateHosts(allowedHosts, allowedOrigins))
+			srv.SetAllowedOrigins(allowedOrigins)
+
+			// Security headers for the publicly reachable page
+			if ctx.Bool("security-headers") {
+				srv.Use(middleware.SecurityHeaders(middleware.SecurityOptions{
+					ImageHosts:     imageHosts,
+					FrameOptions:   ctx.String("frame-options"),
+					ReferrerPolicy: ctx.String("referrer-policy"),
+				}))
+			}
+
+			// Response compression
+			if ctx.Bool("compress") {
+				srv.Use(middleware.Compress(ctx.Bool("compress-sse")))
+			}
+
+			// CORS middleware
+			if len(allowedOrigins) > 0 {
+				srv.Use(cors.New(cors.Options{AllowedOrigins: allowedOrigins}).Handler)
+			} else {
+				srv.Use(cors.Default().Handler)
+			}
+
+			// Wrap a route set with the per-listener middleware
+			wrapWith := func(resolver *clientip.Resolver, handler http.Handler) http.Handler {
+				handler = resolver.Middleware(handler)
+
+				// Tag every request so error responses can be correlated with logs
+				return requestid.Middleware(handler)
+			}
+
+			wrap := func(handler http.Handler) http.Handler {
+				return wrapWith(resolver, handler)
+			}
+
+			// Port configuration
+			port := fmt.Sprintf(":%d", ctx.Int("port"))
+
+			certFile, keyFile := ctx.String("tls-cert"), ctx.String("tls-key")
+			if (certFile == "") != (keyFile == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be set together")
+			}
+
+			// HTTP/3 shares the address and route set of each TCP listen address
+			serveHTTP3 := ctx.Bool("http3")
+			if serveHTTP3 && certFile == "" {
+				return fmt.Errorf("--http3 requires --tls-cert and --tls-key")
+			}
+
+			// Collect the listeners to serve along with their route sets
+			var bindings []binding
+
+			if tun != nil {
+				// Only ngrok can reach the tunnel listener, so the address it appends is the client's
+				bindings = append(bindings, binding{ln: tun, handler: wrapWith(clientip.TrustHops(1), srv.SetupRoutes())})
+			}
+
+			if len(bindings) == 0 {
+				// Prefer sockets handed over by systemd, routed by their FileDescriptorName
+				activated, err := listener.Activated()
+				if err != nil {
+					return err
+				}
+				if serveHTTP3 && len(activated) > 0 {
+					return fmt.Errorf("--http3 cannot be used with socket activation")
+				}
+				for _, socket := range activated {
+					set, err := server.ParseRouteSet(socket.Name)
+					if err != nil {
+						set = server.RoutesAll
+					}
+					log.Printf("Serving %s routes on activated socket %s", set, socket.Name)
+					bindings = append(bindings, binding{ln: socket.Listener, handler: wrap(srv.SetupRouteSet(set))})
+				}
+			}
+
+			if len(bindings) == 0 {
+				// Explicit listen addresses, falling back to the configured port
+				specs := ctx.StringSlice("listen")
+				if len(specs) == 0 {
+					specs = []string{port}
+				}
+
+				for _, spec := range specs {
+					addr, set, err := parseListen(spec)
+					if err != nil {
+						return err
+					}
+
+					ln, err := net.Listen("tcp", addr)
+					if err != nil {
+						return fmt.Errorf("listen on %s failed: %v", addr, err)
+					}
+					log.Printf("Serving %s routes on %s", set, ln.Addr())
+					b := binding{ln: ln, handler: wrap(srv.SetupRouteSet(set))}
+					if serveHTTP3 {
+						b.h3 = &http3.Server{Addr: ln.Addr().String(), Handler: b.handler}
+					}
+					bindings = append(bindings, b)
+				}
+			}
+
+			// Experimental HTTP/3 listeners alongside the TCP ones
+			for _, b := range bindings {
+				if b.h3 == nil {
+					continue
+				}
+				go func(h3Server *http3.Server) {
+					log.Printf("HTTP/3 server starting on %s (udp)", h3Server.Addr)
+					if err := h3Server.ListenAndServeTLS(certFile, keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						log.Printf("HTTP/3 server error: %v", err)
+					}
+				}(b.h3)
+			}
+
+			// One HTTP server per listener, with request contexts derived from the lifecycle context
+			httpServers := make([]*http.Server, len(bindings))
+			for i, b := range bindings {
+				h := b.handler
+				if b.h3 != nil {
+					// Advertise HTTP/3 to clients connecting over TCP
+					h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						b.h3.SetQUICHeaders(w.Header())
+						b.handler.ServeHTTP(w, r)
+					})
+				}
+
+				httpServers[i] = &http.Server{
+					Handler: h,
+					BaseContext: func(net.Listener) context.Context {
+						return ctx.Context
+					},
+				}
 			}
 
-			// Standard local server
-			log.Printf("Server starting on %s", port)
-			return http.ListenAndServe(port, handler)
+			// Shut down gracefully once the lifecycle context is cancelled
+			go func() {
+				<-ctx.Context.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				for _, b := range bindings {
+					if b.h3 != nil {
+						b.h3.Close()
+					}
+				}
+				for _, httpServer := range httpServers {
+					if err := httpServer.Shutdown(shutdownCtx); err != nil {
+						log.Printf("Shutdown error: %v", err)
+					}
+				}
+			}()
+
+			// Serve every listener until one fails or the server shuts down
+			errCh := make(chan error, len(bindings))
+			for i, b := range bindings {
+				go func(httpServer *http.Server, ln net.Listener) {
+					// TLS server (HTTP/1.1 and HTTP/2)
+					if certFile != "" && !ctx.Bool("tunnel") {
+						log.Printf("Server starting on %s (tls)", ln.Addr())
+						errCh <- httpServer.ServeTLS(ln, certFile, keyFile)
+						return
+					}
+
+					// Standard local server
+					log.Printf("Server starting on %s", ln.Addr())
+					errCh <- httpServer.Serve(ln)
+				}(httpServers[i], b.ln)
+			}
+			return ignoreServerClosed(<-errCh)
 		},
 	}
 
+	// Cancel the lifecycle context on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Run the CLI app
-	if err := app.Run(os.Args); err != nil {
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// loadtestCommand spawns concurrent SSE clients against a running server
+func loadtestCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "loadtest",
+		Usage:     "Measure connect success, event throughput and inter-event latency of an SSE endpoint",
+		ArgsUsage: "[url]",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "clients",
+				Value: 50,
+				Usage: "Number of concurrent SSE clients",
+			},
+			&cli.DurationFlag{
+				Name:  "duration",
+				Value: time.Minute,
+				Usage: "How long each client stays connected",
+			},
+			&cli.DurationFlag{
+				Name:  "ramp-up",
+				Value: 5 * time.Second,
+				Usage: "Time over which clients are started",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			target := ctx.Args().First()
+			if target == "" {
+				target = "http://localhost:8080/memes"
+			}
+
+			log.Printf("Load testing %s with %d clients for %s", target, ctx.Int("clients"), ctx.Duration("duration"))
+			report, err := loadtest.Run(ctx.Context, loadtest.Options{
+				URL:      target,
+				Clients:  ctx.Int("clients"),
+				Duration: ctx.Duration("duration"),
+				RampUp:   ctx.Duration("ramp-up"),
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(report)
+			return nil
+		},
+	}
+}
+
+// benchCommand measures the in-process streaming path
+func benchCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "bench",
+		Usage: "Broadcast memes from an in-memory pool to synthetic subscribers and report events/sec, allocations and GC pressure",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "subscribers",
+				Value: 100,
+				Usage: "Number of synthetic subscribers",
+			},
+			&cli.DurationFlag{
+				Name:  "duration",
+				Value: 10 * time.Second,
+				Usage: "How long to keep broadcasting",
+			},
+			&cli.IntFlag{
+				Name:  "pool-size",
+				Value: 100,
+				Usage: "Number of memes in the in-memory pool",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the report as JSON, for comparing runs",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			report, err := bench.Run(ctx.Context, bench.Options{
+				Subscribers: ctx.Int("subscribers"),
+				Duration:    ctx.Duration("duration"),
+				PoolSize:    ctx.Int("pool-size"),
+			})
+			if err != nil {
+				return err
+			}
+
+			if ctx.Bool("json") {
+				return json.NewEncoder(os.Stdout).Encode(report)
+			}
+			fmt.Print(report)
+			return nil
+		},
+	}
+}
+
+// doctorCommand validates the environment before a demo
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check ngrok auth, Reddit reachability, port availability, templates and clock",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "port",
+				Value: 8080,
+				Usage: "Local server port to check",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-ngrok",
+				Usage: "Skip the ngrok auth token check",
+			},
+			&cli.StringFlag{
+				Name:  "templates-dir",
+				Usage: "Template override directory to check along with the embedded templates",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			client := memeservice.NewHTTPClient(memeservice.DefaultClientConfig())
+			redditURL := memeservice.DefaultSource.URL(memeservice.DefaultBaseURL)
+
+			var templates fs.FS = web.Files
+			if dir := ctx.String("templates-dir"); dir != "" {
+				templates = web.Overlay(dir, templates)
+			}
+
+			checks := []doctor.Check{
+				doctor.RedditCheck(client, redditURL),
+				doctor.PortCheck(fmt.Sprintf(":%d", ctx.Int("port"))),
+				doctor.TemplateCheck(templates, "index.html", "app.js", "sw.js", "manifest.webmanifest"),
+				doctor.ClockCheck(client, memeservice.DefaultBaseURL),
+			}
+			if !ctx.Bool("skip-ngrok") {
+				checks = append([]doctor.Check{doctor.NgrokCheck()}, checks...)
+			}
+
+			if !doctor.Print(os.Stdout, doctor.Run(ctx.Context, checks, 15*time.Second)) {
+				return cli.Exit("doctor found problems", 1)
+			}
+			return nil
+		},
+	}
+}
+
+// blocklistHashCommand prints the blocklist lines matching image files
+func blocklistHashCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "blocklist-hash",
+		Usage:     "Print the sha256 and dhash blocklist lines of image files",
+		ArgsUsage: "FILE...",
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("blocklist-hash needs at least one image file", 1)
+			}
+			for _, path := range ctx.Args().Slice() {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("# %s\n%s\n", path, blocklist.Compute(data))
+			}
+			return nil
+		},
+	}
+}
+
+// binding pairs a listener with the handler serving it
+type binding struct {
+	ln      net.Listener
+	handler http.Handler
+	h3      *http3.Server // HTTP/3 on the same address and routes, nil without --http3
+}
+
+// parseListen parses an "addr[=routes]" listen specification
+func parseListen(spec string) (string, server.RouteSet, error) {
+	addr, routes, found := strings.Cut(spec, "=")
+	if !found {
+		return addr, server.RoutesAll, nil
+	}
+
+	set, err := server.ParseRouteSet(routes)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid listen address %q: %v", spec, err)
+	}
+	return addr, set, nil
+}
+
+// ignoreServerClosed treats a graceful shutdown as a clean exit
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
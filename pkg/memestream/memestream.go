@@ -0,0 +1,239 @@
+// Package memestream embeds the meme stream server in other Go programs, so
+// it can be mounted into an existing mux instead of running the binary:
+//
+//	stream, err := memestream.New(
+//		memestream.WithSources("memes:hot", "dankmemes:top:day"),
+//		memestream.WithBasePath("/memes-app"),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go stream.Run(ctx)
+//	mux.Handle("/memes-app/", stream.Handler())
+package memestream
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	auth "github.com/elt0nxale/meme-tunnel-streamer/internal/auth"
+	broadcaster "github.com/elt0nxale/meme-tunnel-streamer/internal/broadcaster"
+	cache "github.com/elt0nxale/meme-tunnel-streamer/internal/cache"
+	config "github.com/elt0nxale/meme-tunnel-streamer/internal/config"
+	memeservice "github.com/elt0nxale/meme-tunnel-streamer/internal/memeservice"
+	requestid "github.com/elt0nxale/meme-tunnel-streamer/internal/requestid"
+	server "github.com/elt0nxale/meme-tunnel-streamer/internal/server"
+	web "github.com/elt0nxale/meme-tunnel-streamer/web"
+)
+
+// Option configures a Server
+type Option func(*options)
+
+// Event is a stream event; Data holds its default JSON payload
+type Event = broadcaster.Event
+
+// Serializer controls the wire format of a stream's events
+type Serializer = broadcaster.Serializer
+
+// SerializerFunc adapts a function to a Serializer
+type SerializerFunc = broadcaster.SerializerFunc
+
+// options collects the settings applied by New
+type options struct {
+	sources      []string
+	client       *http.Client
+	upstreamURL  string
+	redisURL     string
+	assets       fs.FS
+	basePath     string
+	debugPath    string
+	memeInterval time.Duration
+	recentSize   int
+	recentWindow time.Duration
+	seed         *int64
+	adminToken   string
+	apiKeys      []string
+	logger       *log.Logger
+	serializers  map[string]Serializer
+}
+
+// WithSources sets the subreddits to stream, as subreddit[:sort[:time[:ttl]]] (default memes:hot)
+func WithSources(sources ...string) Option {
+	return func(o *options) { o.sources = append(o.sources, sources...) }
+}
+
+// WithHTTPClient sets the client used for upstream requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.client = client }
+}
+
+// WithUpstreamURL points the server at a Reddit-compatible host, e.g. a test mock
+func WithUpstreamURL(url string) Option {
+	return func(o *options) { o.upstreamURL = url }
+}
+
+// WithRedis shares the response cache through Redis instead of process memory
+func WithRedis(url string) Option {
+	return func(o *options) { o.redisURL = url }
+}
+
+// WithAssets replaces the embedded client page and assets
+func WithAssets(assets fs.FS) Option {
+	return func(o *options) { o.assets = assets }
+}
+
+// WithBasePath serves every route, the client page and the URLs it links to
+// under a sub-path. Handler strips the prefix itself, so mount it at the
+// sub-path without http.StripPrefix
+func WithBasePath(basePath string) Option {
+	return func(o *options) { o.basePath = basePath }
+}
+
+// WithDebugPath moves the connection log dump from /debug, or removes it
+// (and the state dump) when path is empty
+func WithDebugPath(path string) Option {
+	return func(o *options) { o.debugPath = path }
+}
+
+// WithMemeInterval sets the delay between memes sent to a stream
+func WithMemeInterval(interval time.Duration) Option {
+	return func(o *options) { o.memeInterval = interval }
+}
+
+// WithRecentWindow avoids resending a subscriber's last size memes within window
+func WithRecentWindow(size int, window time.Duration) Option {
+	return func(o *options) { o.recentSize, o.recentWindow = size, window }
+}
+
+// WithSeed makes meme selection reproducible
+func WithSeed(seed int64) Option {
+	return func(o *options) { o.seed = &seed }
+}
+
+// WithAdminToken sets the bearer token that always has the admin role
+func WithAdminToken(token string) Option {
+	return func(o *options) { o.adminToken = token }
+}
+
+// WithAPIKeys requires API keys given as name:key[:max_streams[:events_per_day[:role]]]
+func WithAPIKeys(keys ...string) Option {
+	return func(o *options) { o.apiKeys = append(o.apiKeys, keys...) }
+}
+
+// WithLogger sends the server's logs to logger instead of the standard logger
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithSerializer writes the events of streams opened on endpoint (a path such
+// as /memes, relative to the base path) through ser, e.g. to wrap them in an envelope
+func WithSerializer(endpoint string, ser Serializer) Option {
+	return func(o *options) {
+		if o.serializers == nil {
+			o.serializers = make(map[string]Serializer)
+		}
+		o.serializers[endpoint] = ser
+	}
+}
+
+// Server is an embeddable meme stream server
+type Server struct {
+	srv   *server.Server
+	close func() error
+}
+
+// New creates a server; it fetches memes lazily on the first request
+func New(opts ...Option) (*Server, error) {
+	o := options{assets: web.Files, debugPath: server.DefaultDebugPath, logger: log.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var sources []memeservice.Source
+	for _, spec := range o.sources {
+		source, err := memeservice.ParseSource(spec)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+
+	var keys []auth.APIKey
+	for _, spec := range o.apiKeys {
+		key, err := auth.ParseAPIKey(spec)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	s := &Server{close: func() error { return nil }}
+
+	var responseCache cache.Cache = cache.NewMemoryCache()
+	if o.redisURL != "" {
+		redisCache, err := cache.NewRedisCache(o.redisURL)
+		if err != nil {
+			return nil, err
+		}
+		responseCache = redisCache
+		s.close = redisCache.Close
+	}
+
+	if o.client == nil {
+		o.client = memeservice.NewHTTPClient(memeservice.DefaultClientConfig())
+	}
+
+	memeService := memeservice.NewService(responseCache, sources, o.client)
+	if o.upstreamURL != "" {
+		memeService.SetBaseURL(o.upstreamURL)
+	}
+
+	s.srv = server.NewServer(o.assets, server.WithMemeService(memeService), server.WithLogger(o.logger))
+	s.srv.SetBasePath(o.basePath)
+	s.srv.SetDebugPath(o.debugPath)
+	for endpoint, ser := range o.serializers {
+		s.srv.SetSerializer(endpoint, ser)
+	}
+	s.srv.SetAdminToken(o.adminToken)
+	s.srv.SetAPIKeys(keys)
+	if o.seed != nil {
+		s.srv.SetSeed(*o.seed)
+	}
+	if err := s.srv.ApplyConfig(&config.Config{
+		MemeInterval: o.memeInterval,
+		RecentSize:   o.recentSize,
+		RecentWindow: o.recentWindow,
+	}); err != nil {
+		return nil, fmt.Errorf("invalid options: %v", err)
+	}
+
+	return s, nil
+}
+
+// Use wraps every route in middleware such as auth or logging; the first
+// registered runs first
+func (s *Server) Use(mw ...func(http.Handler) http.Handler) {
+	for _, m := range mw {
+		s.srv.Use(m)
+	}
+}
+
+// Handler returns every route: the stream, client page, API and debug endpoints
+func (s *Server) Handler() http.Handler {
+	return requestid.Middleware(s.srv.SetupRoutes())
+}
+
+// Run announces the meme of the day at each midnight until ctx is done
+func (s *Server) Run(ctx context.Context) {
+	s.srv.BroadcastMotd(ctx)
+	<-ctx.Done()
+}
+
+// Close releases the server's connections, such as to Redis
+func (s *Server) Close() error {
+	return s.close()
+}
@@ -0,0 +1,316 @@
+// Client page: shows the meme stream and the connection debug logs. Every
+// value from the server is set as text, never parsed as HTML
+const basePath = '{{js .BasePath}}';
+const debugPath = '{{js .DebugPath}}';
+
+// Forward ?api_key= from the page URL to every API call
+const apiKey = new URLSearchParams(window.location.search).get('api_key');
+const keyQuery = apiKey ? `?api_key=${encodeURIComponent(apiKey)}` : '';
+
+const connectionStatusEl = document.getElementById('connectionStatus');
+const eventSource = new EventSource(basePath + '/memes' + keyQuery);
+const titleEl = document.getElementById('memeTitle');
+const imageEl = document.getElementById('memeImage');
+const videoEl = document.getElementById('memeVideo');
+const connectionIDEl = document.getElementById('connectionID');
+const debugLogsEl = document.getElementById('debugLogs');
+const connectionListEl = document.getElementById('connectionList');
+
+const pauseButtonEl = document.getElementById('pauseButton');
+
+let connectionLogs = [];
+let currentConnID = null;
+let paused = false;
+
+// Pause or resume this connection's stream
+pauseButtonEl.addEventListener('click', () => {
+    if (!currentConnID) {
+        return;
+    }
+    const action = paused ? 'resume' : 'pause';
+    fetch(`${basePath}/api/v1/streams/${currentConnID}/${action}${keyQuery}`, { method: 'POST' })
+        .catch(error => console.error(`Failed to ${action} stream:`, error));
+});
+
+// Installable app with offline access to recently streamed memes
+if ('serviceWorker' in navigator) {
+    navigator.serviceWorker.register(basePath + '/sw.js')
+        .catch(error => console.error('Service worker registration failed:', error));
+}
+
+// Hand each shown meme to the service worker so it is available offline
+function rememberMeme(meme) {
+    if (navigator.serviceWorker && navigator.serviceWorker.controller && meme.id) {
+        navigator.serviceWorker.controller.postMessage({ type: 'meme', meme });
+    }
+}
+
+// While offline, cycle through the memes the service worker kept
+let offlineTimer = null;
+function showOfflineMemes() {
+    if (offlineTimer || !navigator.serviceWorker || !navigator.serviceWorker.controller) {
+        return;
+    }
+    fetch(basePath + '/offline/recent-memes.json')
+        .then(response => response.json())
+        .then(memes => {
+            if (memes.length === 0) {
+                return;
+            }
+            let index = 0;
+            const show = () => {
+                const meme = memes[index++ % memes.length];
+                titleEl.textContent = `${meme.title} (offline)`;
+                showMedia(meme);
+            };
+            show();
+            offlineTimer = setInterval(show, 10000);
+        })
+        .catch(error => console.error('No offline memes:', error));
+}
+window.addEventListener('online', () => window.location.reload());
+
+// Web Push: offer notifications when the server has them enabled
+const notifyButtonEl = document.getElementById('notifyButton');
+if ('serviceWorker' in navigator && 'PushManager' in window) {
+    fetch(basePath + '/api/v1/push/key' + keyQuery)
+        .then(response => response.ok ? response.json() : null)
+        .then(push => {
+            if (!push) {
+                return;
+            }
+            notifyButtonEl.hidden = false;
+            notifyButtonEl.addEventListener('click', () => {
+                subscribePush(push.public_key).catch(error => console.error('Failed to subscribe to push:', error));
+            });
+        });
+}
+
+async function subscribePush(publicKey) {
+    const registration = await navigator.serviceWorker.ready;
+    if (await Notification.requestPermission() !== 'granted') {
+        return;
+    }
+    const subscription = await registration.pushManager.subscribe({
+        userVisibleOnly: true,
+        applicationServerKey: base64URLToBytes(publicKey)
+    });
+    const response = await fetch(basePath + '/api/v1/push/subscribe' + keyQuery, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(subscription)
+    });
+    // Anonymous visitors and owners at their limit are refused
+    if (!response.ok) {
+        await subscription.unsubscribe();
+        throw new Error(`server refused the subscription with ${response.status}`);
+    }
+    notifyButtonEl.textContent = 'Notifications on';
+    notifyButtonEl.disabled = true;
+}
+
+function base64URLToBytes(value) {
+    const base64 = (value + '='.repeat((4 - value.length % 4) % 4)).replace(/-/g, '+').replace(/_/g, '/');
+    return Uint8Array.from(atob(base64), c => c.charCodeAt(0));
+}
+
+// Update connection status
+function updateConnectionStatus(isConnected) {
+    connectionStatusEl.textContent = isConnected ? 'Connected' : 'Disconnected';
+    connectionStatusEl.className = `connection-status ${isConnected ? 'connection-online' : 'connection-offline'}`;
+}
+
+// Fetch connection logs and populate the sidebar
+function fetchConnectionLogs() {
+    if (!debugPath) {
+        return;
+    }
+    fetch(basePath + debugPath + keyQuery)
+        .then(response => response.json())
+        .then(logs => {
+            connectionLogs = logs;
+            connectionListEl.replaceChildren(...logs.map(log => {
+                const item = element('div', 'sidebar-item', log.id);
+                item.dataset.id = log.id;
+                return item;
+            }));
+        })
+        .catch(error => {
+            console.error('Failed to fetch debug logs:', error);
+        });
+}
+
+// element creates an element with a class and text content
+function element(tag, className, text) {
+    const el = document.createElement(tag);
+    el.className = className;
+    if (text !== undefined) {
+        el.textContent = text;
+    }
+    return el;
+}
+
+// logDetail is a labelled line of a connection's debug log
+function logDetail(label, value) {
+    const detail = element('div', 'debug-log-detail');
+    detail.append(element('span', 'debug-log-label', label), element('span', '', value));
+    return detail;
+}
+
+// Display selected connection's debug logs
+function displayConnectionLog(connID) {
+    const log = connectionLogs.find(l => l.id === connID);
+    if (log) {
+        const latency = log.latency
+            ? `${log.latency.last_ms.toFixed(1)} ms (avg ${log.latency.avg_ms.toFixed(1)} ms over ${log.latency.samples})`
+            : 'N/A';
+        const entry = element('div', 'debug-log');
+        entry.append(
+            element('div', 'debug-log-header', `Connection ${log.id}`),
+            logDetail('Remote:', log.client_ip || log.remote_addr),
+            logDetail('Client:', log.client || 'N/A'),
+            logDetail('Timestamp:', new Date(log.timestamp).toLocaleString()),
+            logDetail('Events:', log.events.join(' → ')),
+            logDetail('Latency:', latency),
+            logDetail('Request Path:', log.request_path)
+        );
+        debugLogsEl.replaceChildren(entry);
+    }
+}
+
+// Set the selected connection in the sidebar
+connectionListEl.addEventListener('click', (e) => {
+    if (e.target.classList.contains('sidebar-item')) {
+        const connID = e.target.dataset.id;
+        const selectedItem = document.querySelector('.sidebar-item.selected');
+        if (selectedItem) {
+            selectedItem.classList.remove('selected');
+        }
+        e.target.classList.add('selected');
+        displayConnectionLog(connID);
+    }
+});
+
+eventSource.onopen = function() {
+    updateConnectionStatus(true);
+};
+
+eventSource.onmessage = function(event) {
+    const meme = JSON.parse(event.data);
+    titleEl.textContent = meme.title;
+    // Reserve layout space when dimensions are known
+    if (meme.width && meme.height) {
+        imageEl.width = meme.width;
+        imageEl.height = meme.height;
+    } else {
+        imageEl.removeAttribute('width');
+        imageEl.removeAttribute('height');
+    }
+    showMedia(meme);
+    showGallery(meme);
+    rememberMeme(meme);
+    connectionIDEl.textContent = 'Connection ID: ' + meme.connID;
+    currentConnID = meme.connID;
+    pauseButtonEl.disabled = false;
+    fetchConnectionLogs();
+};
+
+// Video memes play in a <video> element instead of the image
+function showMedia(meme) {
+    const isVideo = meme.media_type === 'video';
+    imageEl.hidden = isVideo;
+    videoEl.hidden = !isVideo;
+    if (isVideo) {
+        imageEl.removeAttribute('src');
+        videoEl.src = meme.proxy_url || meme.url;
+        videoEl.play().catch(() => {});
+    } else {
+        videoEl.pause();
+        videoEl.removeAttribute('src');
+        imageEl.src = meme.proxy_url || meme.url;
+    }
+}
+
+// Galleries: click the image to step through their pictures
+let galleryImages = [];
+let galleryIndex = 0;
+
+function showGallery(meme) {
+    galleryImages = meme.images || [];
+    galleryIndex = 0;
+    if (galleryImages.length > 1) {
+        titleEl.textContent = `${meme.title} (1/${galleryImages.length})`;
+    }
+}
+
+imageEl.addEventListener('click', () => {
+    if (galleryImages.length < 2) {
+        return;
+    }
+    galleryIndex = (galleryIndex + 1) % galleryImages.length;
+    const image = galleryImages[galleryIndex];
+    imageEl.src = image.proxy_url || image.url;
+    titleEl.textContent = titleEl.textContent.replace(/\(\d+\/\d+\)$/, `(${galleryIndex + 1}/${galleryImages.length})`);
+});
+
+// A caption just made with POST /api/caption interrupts the stream
+eventSource.addEventListener('caption', function(event) {
+    const meme = JSON.parse(event.data);
+    titleEl.textContent = meme.title;
+    imageEl.removeAttribute('width');
+    imageEl.removeAttribute('height');
+    showMedia(meme);
+    showGallery(meme);
+});
+
+eventSource.addEventListener('trending', function(event) {
+    const jumps = JSON.parse(event.data);
+    jumps.forEach(t => console.log(`Trending: "${t.title}" climbed from #${t.previous_rank} to #${t.rank}`));
+});
+
+eventSource.addEventListener('status', function(event) {
+    const status = JSON.parse(event.data);
+    currentConnID = status.connID || currentConnID;
+    paused = status.state === 'paused';
+    pauseButtonEl.textContent = paused ? 'Resume' : 'Pause';
+    if (status.reason === 'quiet_hours') {
+        connectionStatusEl.textContent = status.state === 'streaming' ? 'Connected' : `Quiet hours (${status.state})`;
+    }
+    fetchConnectionLogs();
+});
+
+// Acknowledge pings so the server can measure round-trip latency
+eventSource.addEventListener('ping', function(event) {
+    if (!currentConnID) {
+        return;
+    }
+    fetch(`${basePath}/api/v1/streams/${currentConnID}/pong${keyQuery}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: event.data
+    }).catch(error => console.error('Failed to send pong:', error));
+});
+
+// The key's daily event quota ran out; the server's retry directive
+// makes EventSource reconnect once it resets
+eventSource.addEventListener('quota', function(event) {
+    console.warn('Quota exhausted:', event.data);
+    updateConnectionStatus(false);
+});
+
+// Dev mode: reload the page when templates change on disk
+eventSource.addEventListener('reload', function() {
+    window.location.reload();
+});
+
+eventSource.onerror = function(error) {
+    console.error('EventSource failed:', error);
+    updateConnectionStatus(false);
+    eventSource.close();
+    if (!navigator.onLine) {
+        showOfflineMemes();
+    }
+};
+
+// Initial log fetch
+fetchConnectionLogs();
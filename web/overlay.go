@@ -0,0 +1,32 @@
+package web
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// overlayFS serves files from a directory on disk, falling back to base for
+// files the directory does not have
+type overlayFS struct {
+	dir  fs.FS
+	base fs.FS
+}
+
+// Overlay returns base with the files in dir laid over it, so a client page
+// can be reskinned by overriding only some of its templates
+func Overlay(dir string, base fs.FS) fs.FS {
+	return overlayFS{dir: os.DirFS(dir), base: base}
+}
+
+// Open opens name from the overlay directory, else from base
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.dir.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
@@ -0,0 +1,110 @@
+// Service worker: makes the viewer installable and usable offline, and shows
+// pushed memes as notifications while no tab is open
+const basePath = '{{js .BasePath}}';
+const shellCache = 'meme-fetcher-shell-v2';
+const memeCache = 'meme-fetcher-memes-v1';
+const recentMemesURL = basePath + '/offline/recent-memes.json';
+const maxRecentMemes = 30;
+const shellAssets = [basePath + '/', basePath + '/app.js', basePath + '/manifest.webmanifest', basePath + '/icon.svg'];
+
+// Keep the page and its assets for offline starts
+self.addEventListener('install', event => {
+    event.waitUntil(caches.open(shellCache)
+        .then(cache => cache.addAll(shellAssets))
+        .then(() => self.skipWaiting()));
+});
+
+self.addEventListener('activate', event => {
+    event.waitUntil(caches.keys()
+        .then(names => Promise.all(names
+            .filter(name => name.startsWith('meme-fetcher-') && name !== shellCache && name !== memeCache)
+            .map(name => caches.delete(name))))
+        .then(() => self.clients.claim()));
+});
+
+self.addEventListener('fetch', event => {
+    const request = event.request;
+    if (request.method !== 'GET') {
+        return;
+    }
+
+    // The page: network first, cached shell when offline
+    if (request.mode === 'navigate') {
+        event.respondWith(fetch(request).catch(() => caches.match(basePath + '/', { ignoreSearch: true })));
+        return;
+    }
+
+    // Recently streamed memes, recorded by rememberMeme
+    if (new URL(request.url).pathname === recentMemesURL) {
+        event.respondWith(caches.open(memeCache)
+            .then(cache => cache.match(recentMemesURL))
+            .then(response => response || new Response('[]', { headers: { 'Content-Type': 'application/json' } })));
+        return;
+    }
+
+    // Meme images: network first, falling back to the copy cached when it was streamed
+    if (request.destination === 'image') {
+        event.respondWith(fetch(request).catch(() => caches.match(request)
+            .then(response => response || Response.error())));
+        return;
+    }
+
+    // Shell assets: network first so page script updates apply, cached when
+    // offline; streams and API calls go straight to the network
+    if (shellAssets.includes(new URL(request.url).pathname)) {
+        event.respondWith(fetch(request).catch(() => caches.match(request)
+            .then(response => response || Response.error())));
+    }
+});
+
+// The page posts every meme it shows; keep the last few with their images
+self.addEventListener('message', event => {
+    if (!event.data || event.data.type !== 'meme') {
+        return;
+    }
+    event.waitUntil(rememberMeme(event.data.meme));
+});
+
+async function rememberMeme(meme) {
+    const cache = await caches.open(memeCache);
+    const stored = await cache.match(recentMemesURL);
+    let memes = stored ? await stored.json() : [];
+
+    memes = [meme, ...memes.filter(m => m.id !== meme.id)];
+    const evicted = memes.slice(maxRecentMemes);
+    memes = memes.slice(0, maxRecentMemes);
+
+    const src = meme.proxy_url || meme.url;
+    if (src) {
+        try {
+            // Opaque responses are fine: the page only needs to display them
+            await cache.put(src, await fetch(src, { mode: 'no-cors' }));
+        } catch (error) {
+            console.warn('Failed to cache meme image:', error);
+        }
+    }
+    await Promise.all(evicted.map(m => cache.delete(m.proxy_url || m.url)));
+    await cache.put(recentMemesURL, new Response(JSON.stringify(memes), { headers: { 'Content-Type': 'application/json' } }));
+}
+
+// Show pushed memes as notifications
+self.addEventListener('push', event => {
+    const data = event.data ? event.data.json() : { title: 'Meme Fetcher' };
+    event.waitUntil(self.registration.showNotification(data.title, {
+        body: data.body,
+        image: data.image,
+        icon: data.image || basePath + '/icon.svg',
+        tag: data.tag,
+        data: { url: data.url || self.registration.scope },
+    }));
+});
+
+// Focus an open viewer, or open one, when a notification is clicked
+self.addEventListener('notificationclick', event => {
+    event.notification.close();
+    const url = new URL(event.notification.data.url, self.registration.scope).href;
+    event.waitUntil(clients.matchAll({ type: 'window', includeUncontrolled: true }).then(windows => {
+        const open = windows.find(w => w.url.startsWith(self.registration.scope));
+        return open ? open.focus() : clients.openWindow(url);
+    }));
+});
@@ -0,0 +1,9 @@
+// Package web embeds the client page and its assets.
+package web
+
+import "embed"
+
+// Files holds index.html, its script, the service worker, manifest and icon at its root
+//
+//go:embed index.html app.js sw.js manifest.webmanifest icon.svg
+var Files embed.FS